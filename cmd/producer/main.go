@@ -2,12 +2,14 @@ package main
 
 import (
 	"context"
-	"encoding/json"
+	"flag"
 	"fmt"
 	"log"
 	"time"
 
+	"order-service-wbtech/internal/codec"
 	"order-service-wbtech/internal/config"
+	orderkafka "order-service-wbtech/internal/kafka"
 	"order-service-wbtech/internal/model"
 
 	"github.com/joho/godotenv"
@@ -23,10 +25,34 @@ func main() {
 
 	topic := cfg.Kafkacfg.Topic
 
+	formatName := flag.String("format", cfg.Kafkacfg.MessageFormat, "payload format: json, avro or protobuf")
+	subject := flag.String("subject", "", "schema registry subject (default: <topic>-value-<format>)")
+	flag.Parse()
+
+	format, err := codec.ParseFormat(*formatName)
+	if err != nil {
+		log.Fatalf("Invalid -format: %v", err)
+	}
+	if *subject == "" {
+		*subject = fmt.Sprintf("%s-value-%s", topic, format)
+	}
+
+	registry, err := codec.RegistryFromConfig(cfg.Kafkacfg)
+	if err != nil {
+		log.Fatalf("Failed to load schema registry: %v", err)
+	}
+	encoder := codec.NewEncoder(registry)
+
+	cluster, err := orderkafka.ClusterFromConfig(cfg.Kafkacfg)
+	if err != nil {
+		log.Fatalf("Invalid Kafka security settings: %v", err)
+	}
+
 	w := &kafka.Writer{
-		Addr:     kafka.TCP([]string{cfg.Kafkacfg.Brokers}...),
-		Topic:    topic,
-		Balancer: &kafka.LeastBytes{},
+		Addr:      kafka.TCP(cluster.Brokers...),
+		Topic:     topic,
+		Balancer:  &kafka.LeastBytes{},
+		Transport: cluster.Transport(),
 	}
 	defer func() {
 		if err := w.Close(); err != nil {
@@ -34,7 +60,7 @@ func main() {
 		}
 	}()
 
-	log.Printf("Kafka producer started, brokers=%v, topic=%s", cfg.Kafkacfg.Brokers, topic)
+	log.Printf("Kafka producer started, brokers=%v, topic=%s, format=%s", cfg.Kafkacfg.Brokers, topic, format)
 
 	ticker := time.NewTicker(30 * time.Second)
 	defer ticker.Stop()
@@ -42,19 +68,21 @@ func main() {
 	for range ticker.C {
 		order := generateTestOrder()
 
-		b, err := json.Marshal(order)
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		b, err := encoder.Encode(ctx, format, *subject, order)
 		if err != nil {
-			log.Printf("failed to marshal order: %v", err)
+			cancel()
+			log.Printf("failed to encode order: %v", err)
 			continue
 		}
 
 		msg := kafka.Message{
-			Key:   []byte(order.OrderUID),
-			Value: b,
-			Time:  time.Now(),
+			Key:     []byte(order.OrderUID),
+			Value:   b,
+			Headers: []kafka.Header{{Key: "message_format", Value: []byte(format)}},
+			Time:    time.Now(),
 		}
 
-		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 		err = w.WriteMessages(ctx, msg)
 		cancel()
 
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"order-service-wbtech/internal/cache"
+	"order-service-wbtech/internal/codec"
+	"order-service-wbtech/internal/config"
+	"order-service-wbtech/internal/kafka"
+	"order-service-wbtech/internal/service"
+	"order-service-wbtech/internal/storage"
+	"order-service-wbtech/internal/tracing"
+)
+
+// replay — reprocesses a topic from an offset or a timestamp through the same
+// pipeline as the server, e.g. after a validation bug dropped valid orders:
+//
+//	go run ./cmd/replay -partitions 0,1 -from-offset 1200
+//	go run ./cmd/replay -from-offsets 0:1200,1:950
+//	go run ./cmd/replay -from-time 2025-01-15T10:00:00Z
+func main() {
+	cfg := config.LoadConfig()
+
+	topic := flag.String("topic", cfg.Kafkacfg.Topic, "topic to replay")
+	partitionList := flag.String("partitions", "", "comma-separated partitions to replay (default: all)")
+	fromOffset := flag.Int64("from-offset", -1, "first offset to replay in every partition (default: oldest retained)")
+	fromOffsets := flag.String("from-offsets", "", "per-partition first offsets as partition:offset pairs, e.g. 0:1200,1:950")
+	fromTime := flag.String("from-time", "", "replay messages written at or after this RFC 3339 time")
+	flag.Parse()
+
+	cluster, err := kafka.ClusterFromConfig(cfg.Kafkacfg)
+	if err != nil {
+		log.Fatalf("Invalid Kafka security settings: %v", err)
+	}
+
+	replayCfg := kafka.ReplayConfig{
+		Cluster:    cluster,
+		Topic:      *topic,
+		FromOffset: *fromOffset,
+	}
+
+	if *partitionList != "" {
+		for _, p := range strings.Split(*partitionList, ",") {
+			partition, err := strconv.Atoi(strings.TrimSpace(p))
+			if err != nil {
+				log.Fatalf("Invalid partition %q: %v", p, err)
+			}
+			replayCfg.Partitions = append(replayCfg.Partitions, partition)
+		}
+	}
+
+	if *fromOffsets != "" {
+		offsets, err := kafka.ParsePartitionOffsets(*fromOffsets)
+		if err != nil {
+			log.Fatalf("Invalid -from-offsets: %v", err)
+		}
+		replayCfg.PartitionOffsets = offsets
+	}
+
+	if *fromTime != "" {
+		t, err := time.Parse(time.RFC3339, *fromTime)
+		if err != nil {
+			log.Fatalf("Invalid -from-time: %v", err)
+		}
+		replayCfg.FromTime = t
+	}
+
+	shutdownTracing, err := tracing.Setup(context.Background(), cfg.Tracingcfg.Exporter, cfg.Tracingcfg.ServiceName+"-replay")
+	if err != nil {
+		log.Fatalf("failed to initialize tracing: %v", err)
+	}
+	defer func() {
+		if err := shutdownTracing(context.Background()); err != nil {
+			log.Printf("Tracing shutdown error: %v", err)
+		}
+	}()
+
+	orderCache, err := cache.New()
+	if err != nil {
+		log.Fatalf("failed to initialize cache: %v", err)
+	}
+
+	pg, err := storage.NewPostgres(cfg)
+	if err != nil {
+		log.Fatalf("Failed to connect to PostgreSQL: %v", err)
+	}
+	defer pg.Pool.Close()
+
+	srvc := service.New(orderCache, pg)
+
+	// rejects of the replay are stored for review like those of the server
+	quarantine := kafka.NewQuarantine(pg)
+
+	var dlqWriter kafka.DeadLetterWriter
+	if cfg.Kafkacfg.DLQTopic != "" {
+		writer := kafka.NewKafkaDeadLetterWriter(replayCfg.Cluster, cfg.Kafkacfg.DLQTopic)
+		defer writer.Close()
+		dlqWriter = writer
+	}
+	dlq := kafka.MultiDeadLetterWriter(quarantine, dlqWriter)
+
+	var parking kafka.DeadLetterWriter
+	if cfg.Kafkacfg.ParkingTopic != "" {
+		parkingWriter := kafka.NewKafkaDeadLetterWriter(replayCfg.Cluster, cfg.Kafkacfg.ParkingTopic)
+		defer parkingWriter.Close()
+		parking = kafka.MultiDeadLetterWriter(quarantine, parkingWriter)
+	}
+
+	// the topic is replayed with the handler and retry policy of its subscription
+	sub := subscriptionFor(cfg, replayCfg.Topic)
+	proc := kafka.NewProcessor(srvc, dlq, parking, kafka.RetryPolicyFromConfig(sub))
+	decoder, err := codec.DecoderFromConfig(cfg.Kafkacfg)
+	if err != nil {
+		log.Fatalf("Failed to create order decoder: %v", err)
+	}
+	proc.SetDecoder(decoder)
+	if err := proc.SetDefaultEvent(sub.Handler); err != nil {
+		log.Fatalf("Invalid handler for topic %s: %v", sub.Topic, err)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	log.Printf("Replay started | topic=%s partitions=%v from-offset=%d from-offsets=%v from-time=%s",
+		replayCfg.Topic, replayCfg.Partitions, replayCfg.FromOffset, replayCfg.PartitionOffsets, *fromTime)
+
+	stats, err := kafka.Replay(ctx, replayCfg, proc)
+	log.Printf("Replay finished | inserted=%d updated=%d skipped=%d failed=%d",
+		stats.Inserted, stats.Updated, stats.Skipped, stats.Failed)
+	if err != nil {
+		log.Fatalf("Replay stopped early: %v", err)
+	}
+}
+
+// subscriptionFor returns the configured subscription of topic; topics that are
+// not subscribed are handled like the orders topic
+func subscriptionFor(cfg *config.Config, topic string) config.Subscription {
+	for _, sub := range cfg.Kafkacfg.Subscriptions {
+		if sub.Topic == topic {
+			return sub
+		}
+	}
+	sub := cfg.Kafkacfg.Subscriptions[0]
+	sub.Topic = topic
+	return sub
+}
@@ -8,23 +8,35 @@ import (
 	"net/http"
 	"os"
 	"os/signal"
-	"strings"
 	"sync"
 	"syscall"
 	"time"
 
 	"order-service-wbtech/internal/api"
 	"order-service-wbtech/internal/cache"
+	"order-service-wbtech/internal/codec"
 	"order-service-wbtech/internal/config"
 	"order-service-wbtech/internal/kafka"
 	"order-service-wbtech/internal/service"
 	"order-service-wbtech/internal/storage"
+	"order-service-wbtech/internal/tracing"
 )
 
 func main() {
 	// cfg
 	cfg := config.LoadConfig()
-	log.Printf("cfg = %+v", cfg)
+	log.Printf("cfg = %s", cfg)
+
+	// tracing
+	shutdownTracing, err := tracing.Setup(context.Background(), cfg.Tracingcfg.Exporter, cfg.Tracingcfg.ServiceName)
+	if err != nil {
+		log.Fatalf("failed to initialize tracing: %v", err)
+	}
+	defer func() {
+		if err := shutdownTracing(context.Background()); err != nil {
+			log.Printf("Tracing shutdown error: %v", err)
+		}
+	}()
 
 	// cache
 	orderCache, err := cache.New()
@@ -47,8 +59,47 @@ func main() {
 	}
 	fmt.Println("Cache loaded from DB")
 
+	// consumer control, shared by the consumer and the admin API
+	consumerControl := kafka.NewControl()
+
+	cluster, err := kafka.ClusterFromConfig(cfg.Kafkacfg)
+	if err != nil {
+		log.Fatalf("Invalid Kafka security settings: %v", err)
+	}
+
+	// rejected messages go to the rejected_messages table, where they can be
+	// reviewed and replayed, and to the Kafka DLQ (if configured)
+	quarantine := kafka.NewQuarantine(pg)
+
+	var dlqWriter kafka.DeadLetterWriter
+	if cfg.Kafkacfg.DLQTopic != "" {
+		writer := kafka.NewKafkaDeadLetterWriter(cluster, cfg.Kafkacfg.DLQTopic)
+		defer writer.Close()
+		dlqWriter = writer
+	} else {
+		log.Println("KAFKA_DLQ_TOPIC is not set, invalid messages are only stored in rejected_messages")
+	}
+	dlq := kafka.MultiDeadLetterWriter(quarantine, dlqWriter)
+
+	var parking kafka.DeadLetterWriter
+	if cfg.Kafkacfg.ParkingTopic != "" {
+		parkingWriter := kafka.NewKafkaDeadLetterWriter(cluster, cfg.Kafkacfg.ParkingTopic)
+		defer parkingWriter.Close()
+		parking = kafka.MultiDeadLetterWriter(quarantine, parkingWriter)
+	}
+
+	registry := newRegistry(cfg, srvc, dlq, parking)
+	quarantine.SetRegistry(registry)
+
 	// HTTP
 	srv := api.New(srvc)
+	srv.EnableIdempotency(pg)
+	if cfg.AdminToken != "" {
+		srv.EnableAdmin(consumerControl, cfg.AdminToken)
+		srv.EnableQuarantine(quarantine)
+	} else {
+		log.Println("ADMIN_TOKEN is not set, admin endpoints are disabled")
+	}
 	httpServer := &http.Server{
 		Addr:    ":" + cfg.HTTPPort,
 		Handler: srv.Router(),
@@ -66,20 +117,63 @@ func main() {
 		}
 	}()
 
-	// Starting Kafka consumer
-	wg.Add(1)
 	ctxKafka, cancel := context.WithCancel(context.Background())
 	defer cancel()
+
+	// Starting the outbox relay
+	if cfg.Kafkacfg.OutboxTopic != "" {
+		outboxWriter := kafka.NewOutboxWriter(cluster, cfg.Kafkacfg.OutboxTopic)
+		defer outboxWriter.Close()
+
+		relay := kafka.NewOutboxRelay(pg, outboxWriter, cfg.Kafkacfg.OutboxInterval, cfg.Kafkacfg.OutboxBatchSize)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			relay.Run(ctxKafka)
+		}()
+	} else {
+		log.Println("KAFKA_OUTBOX_TOPIC is not set, order_accepted events stay in the outbox")
+	}
+
+	// Starting backpressure: ingestion slows down or pauses while PostgreSQL is overloaded
+	throttle := kafka.NewThrottle(kafka.ThrottleConfig{
+		Rate:             float64(cfg.Kafkacfg.RateLimit),
+		Burst:            cfg.Kafkacfg.RateBurst,
+		SlowRate:         float64(cfg.Kafkacfg.SlowRate),
+		SlowSaveLatency:  cfg.Kafkacfg.SlowSaveLatency,
+		SlowPoolWait:     cfg.Kafkacfg.SlowPoolWait,
+		PauseSaveLatency: cfg.Kafkacfg.PauseSaveLatency,
+		PausePoolWait:    cfg.Kafkacfg.PausePoolWait,
+		Interval:         cfg.Kafkacfg.BackpressureInterval,
+	}, pg)
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		throttle.Run(ctxKafka)
+	}()
+
+	// Starting Kafka consumer
+	wg.Add(1)
 	go func() {
 		defer wg.Done()
-		brokers := strings.Split(cfg.Kafkacfg.Brokers, ",")
-		kafka.StartConsumerWithWorkerPool(
+		kafka.StartConsumers(
 			ctxKafka,
-			brokers,
-			cfg.Kafkacfg.Topic,
+			cluster,
 			cfg.Kafkacfg.GroupID,
-			srvc,
-			10,
+			registry,
+			cfg.Kafkacfg.BatchSize,
+			cfg.Kafkacfg.BatchTimeout,
+			consumerControl,
+			throttle,
+			kafka.Tuning{
+				MinBytes:         cfg.Kafkacfg.MinBytes,
+				MaxBytes:         cfg.Kafkacfg.MaxBytes,
+				MaxWait:          cfg.Kafkacfg.MaxWait,
+				ReadBatchTimeout: cfg.Kafkacfg.ReadBatchTimeout,
+				QueueCapacity:    cfg.Kafkacfg.QueueCapacity,
+				WorkerQueueSize:  cfg.Kafkacfg.WorkerQueueSize,
+				FetchRetryDelay:  cfg.Kafkacfg.FetchRetryDelay,
+			},
 		)
 	}()
 
@@ -102,3 +196,27 @@ func main() {
 	wg.Wait()
 	log.Println("Service stopped cleanly")
 }
+
+// newRegistry creates a processor with its own retry policy for every configured
+// subscription; all of them share the order decoder and the DLQ and parking writers
+func newRegistry(cfg *config.Config, svc kafka.OrderIngestor, dlq, parking kafka.DeadLetterWriter) *kafka.Registry {
+	decoder, err := codec.DecoderFromConfig(cfg.Kafkacfg)
+	if err != nil {
+		log.Fatalf("Failed to create order decoder: %v", err)
+	}
+	registry := kafka.NewRegistry()
+
+	for _, sub := range cfg.Kafkacfg.Subscriptions {
+		proc := kafka.NewProcessor(svc, dlq, parking, kafka.RetryPolicyFromConfig(sub))
+		proc.SetDecoder(decoder)
+		if err := proc.SetDefaultEvent(sub.Handler); err != nil {
+			log.Fatalf("Invalid handler for topic %s: %v", sub.Topic, err)
+		}
+
+		if err := registry.Register(kafka.Route{Topic: sub.Topic, Processor: proc, Workers: sub.Workers}); err != nil {
+			log.Fatalf("Failed to subscribe: %v", err)
+		}
+		log.Printf("Subscribed to %s | handler=%s workers=%d retries=%d", sub.Topic, sub.Handler, sub.Workers, sub.RetryMaxAttempts)
+	}
+	return registry
+}
@@ -0,0 +1,126 @@
+package api
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"order-service-wbtech/internal/kafka"
+)
+
+// defaultDrainTimeout — how long POST /admin/consumer/drain waits without ?timeout=
+const defaultDrainTimeout = 30 * time.Second
+
+// ConsumerControl pauses, resumes and drains the Kafka consumer, implemented by *kafka.Control
+type ConsumerControl interface {
+	Pause()
+	Resume()
+	Drain(ctx context.Context) error
+	Status() kafka.ConsumerStatus
+}
+
+// EnableAdmin registers the consumer admin endpoints, protected by a bearer
+// token. They stay disabled while token is empty.
+func (s *Server) EnableAdmin(control ConsumerControl, token string) {
+	s.control = control
+	s.adminToken = token
+}
+
+// EnableQuarantine registers the rejected message endpoints next to the other
+// admin endpoints
+func (s *Server) EnableQuarantine(rejected RejectedMessages) {
+	s.rejected = rejected
+}
+
+func (s *Server) registerAdmin(mux *http.ServeMux) {
+	if s.adminToken == "" {
+		return
+	}
+
+	if s.control != nil {
+		mux.Handle("GET /admin/consumer/status", s.requireAdmin(s.handleConsumerStatus))
+		mux.Handle("POST /admin/consumer/pause", s.requireAdmin(s.handleConsumerPause))
+		mux.Handle("POST /admin/consumer/resume", s.requireAdmin(s.handleConsumerResume))
+		mux.Handle("POST /admin/consumer/drain", s.requireAdmin(s.handleConsumerDrain))
+	}
+
+	if s.rejected != nil {
+		mux.Handle("GET /admin/rejected-messages", s.requireAdmin(s.handleListRejected))
+		mux.Handle("GET /admin/rejected-messages/{id}", s.requireAdmin(s.handleGetRejected))
+		mux.Handle("POST /admin/rejected-messages/{id}/replay", s.requireAdmin(s.handleReplayRejected))
+	}
+}
+
+// requireAdmin rejects requests without "Authorization: Bearer <admin token>"
+func (s *Server) requireAdmin(next http.HandlerFunc) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(token), []byte(s.adminToken)) != 1 {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="admin"`)
+			writeError(w, r, http.StatusUnauthorized, codeUnauthorized, "unauthorized")
+			return
+		}
+		next(w, r)
+	})
+}
+
+func (s *Server) handleConsumerStatus(w http.ResponseWriter, _ *http.Request) {
+	s.writeConsumerStatus(w, http.StatusOK)
+}
+
+func (s *Server) handleConsumerPause(w http.ResponseWriter, _ *http.Request) {
+	log.Println("HTTP POST /admin/consumer/pause")
+	s.control.Pause()
+	s.writeConsumerStatus(w, http.StatusOK)
+}
+
+func (s *Server) handleConsumerResume(w http.ResponseWriter, _ *http.Request) {
+	log.Println("HTTP POST /admin/consumer/resume")
+	s.control.Resume()
+	s.writeConsumerStatus(w, http.StatusOK)
+}
+
+// handleConsumerDrain stops fetching and waits until every in-flight message is
+// committed. A drain that times out keeps going; poll the status endpoint.
+func (s *Server) handleConsumerDrain(w http.ResponseWriter, r *http.Request) {
+	timeout := defaultDrainTimeout
+	if v := r.URL.Query().Get("timeout"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d <= 0 {
+			writeError(w, r, http.StatusBadRequest, codeBadRequest, "invalid timeout")
+			return
+		}
+		timeout = d
+	}
+
+	log.Printf("HTTP POST /admin/consumer/drain timeout=%s", timeout)
+
+	ctx, cancel := context.WithTimeout(r.Context(), timeout)
+	defer cancel()
+
+	err := s.control.Drain(ctx)
+	switch {
+	case err == nil:
+		s.writeConsumerStatus(w, http.StatusOK)
+	case errors.Is(err, kafka.ErrDrainInterrupted):
+		s.writeConsumerStatus(w, http.StatusConflict)
+	case errors.Is(err, context.DeadlineExceeded):
+		s.writeConsumerStatus(w, http.StatusGatewayTimeout)
+	default:
+		log.Printf("Drain error: %v", err)
+		s.writeConsumerStatus(w, http.StatusServiceUnavailable)
+	}
+}
+
+func (s *Server) writeConsumerStatus(w http.ResponseWriter, code int) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	if err := json.NewEncoder(w).Encode(s.control.Status()); err != nil {
+		log.Printf("json encode error: %v", err)
+	}
+}
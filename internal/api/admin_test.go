@@ -0,0 +1,115 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"order-service-wbtech/internal/kafka"
+)
+
+// fakeControl — ConsumerControl that records state changes
+type fakeControl struct {
+	state    kafka.ConsumerState
+	drainErr error
+}
+
+func (f *fakeControl) Pause()  { f.state = kafka.StatePaused }
+func (f *fakeControl) Resume() { f.state = kafka.StateRunning }
+
+func (f *fakeControl) Drain(_ context.Context) error {
+	if f.drainErr != nil {
+		f.state = kafka.StateDraining
+		return f.drainErr
+	}
+	f.state = kafka.StateDrained
+	return nil
+}
+
+func (f *fakeControl) Status() kafka.ConsumerStatus {
+	return kafka.ConsumerStatus{State: f.state}
+}
+
+func adminRequest(method, target, token string) *http.Request {
+	r := httptest.NewRequest(method, target, nil)
+	if token != "" {
+		r.Header.Set("Authorization", "Bearer "+token)
+	}
+	return r
+}
+
+func TestAdmin_RequiresToken(t *testing.T) {
+	control := &fakeControl{state: kafka.StateRunning}
+	srv := New(&fakeService{})
+	srv.EnableAdmin(control, "secret")
+
+	for _, token := range []string{"", "wrong"} {
+		rec := httptest.NewRecorder()
+		srv.Router().ServeHTTP(rec, adminRequest(http.MethodPost, "/admin/consumer/pause", token))
+		assert.Equal(t, http.StatusUnauthorized, rec.Code)
+	}
+	assert.Equal(t, kafka.StateRunning, control.state)
+}
+
+func TestAdmin_DisabledWithoutToken(t *testing.T) {
+	srv := New(&fakeService{})
+	srv.EnableAdmin(&fakeControl{}, "")
+
+	rec := httptest.NewRecorder()
+	srv.Router().ServeHTTP(rec, adminRequest(http.MethodGet, "/admin/consumer/status", ""))
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+}
+
+func TestAdmin_ConsumerLifecycle(t *testing.T) {
+	control := &fakeControl{state: kafka.StateRunning}
+	srv := New(&fakeService{})
+	srv.EnableAdmin(control, "secret")
+
+	steps := []struct {
+		method, target string
+		want           kafka.ConsumerState
+	}{
+		{http.MethodGet, "/admin/consumer/status", kafka.StateRunning},
+		{http.MethodPost, "/admin/consumer/pause", kafka.StatePaused},
+		{http.MethodPost, "/admin/consumer/resume", kafka.StateRunning},
+		{http.MethodPost, "/admin/consumer/drain?timeout=5s", kafka.StateDrained},
+		{http.MethodGet, "/admin/consumer/status", kafka.StateDrained},
+	}
+	for _, step := range steps {
+		rec := httptest.NewRecorder()
+		srv.Router().ServeHTTP(rec, adminRequest(step.method, step.target, "secret"))
+		require.Equal(t, http.StatusOK, rec.Code, step.target)
+
+		var got kafka.ConsumerStatus
+		require.NoError(t, json.NewDecoder(rec.Body).Decode(&got))
+		assert.Equal(t, step.want, got.State, step.target)
+	}
+}
+
+func TestAdmin_DrainErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		target string
+		err    error
+		code   int
+	}{
+		{"timeout", "/admin/consumer/drain", context.DeadlineExceeded, http.StatusGatewayTimeout},
+		{"interrupted", "/admin/consumer/drain", kafka.ErrDrainInterrupted, http.StatusConflict},
+		{"invalid timeout", "/admin/consumer/drain?timeout=soon", nil, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := New(&fakeService{})
+			srv.EnableAdmin(&fakeControl{drainErr: tt.err}, "secret")
+
+			rec := httptest.NewRecorder()
+			srv.Router().ServeHTTP(rec, adminRequest(http.MethodPost, tt.target, "secret"))
+			assert.Equal(t, tt.code, rec.Code)
+		})
+	}
+}
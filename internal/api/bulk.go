@@ -0,0 +1,203 @@
+package api
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+
+	"order-service-wbtech/internal/model"
+	"order-service-wbtech/internal/validator"
+)
+
+// bulkBatchSize — valid orders of POST /orders:bulk stored in one transaction
+const bulkBatchSize = 500
+
+// errLineTooLong — an NDJSON line is longer than maxOrderBody
+var errLineTooLong = errors.New("line too long")
+
+// Per-line results of POST /orders:bulk
+const (
+	BulkAccepted  = "accepted"
+	BulkDuplicate = "duplicate"
+	// BulkConflict — an order with the same order_uid but different content is stored
+	BulkConflict = "conflict"
+	BulkInvalid  = "invalid"
+	// BulkFailed — the order could not be saved, e.g. the database is down; the line can be resent
+	BulkFailed = "failed"
+)
+
+// bulkResult — outcome of one NDJSON line; empty lines are skipped
+type bulkResult struct {
+	Line     int                `json:"line"`
+	OrderUID string             `json:"order_uid,omitempty"`
+	Status   string             `json:"status"`
+	Error    string             `json:"error,omitempty"`
+	Fields   []model.FieldError `json:"fields,omitempty"`
+}
+
+// bulkReport — counts by status and the result of every line
+type bulkReport struct {
+	Accepted  int          `json:"accepted"`
+	Duplicate int          `json:"duplicate"`
+	Conflict  int          `json:"conflict"`
+	Invalid   int          `json:"invalid"`
+	Failed    int          `json:"failed"`
+	Results   []bulkResult `json:"results"`
+}
+
+func (r *bulkReport) add(res bulkResult) {
+	r.set(r.reserve(), res)
+}
+
+// reserve keeps the place of a line whose result is known once its batch is saved
+func (r *bulkReport) reserve() int {
+	r.Results = append(r.Results, bulkResult{})
+	return len(r.Results) - 1
+}
+
+func (r *bulkReport) set(i int, res bulkResult) {
+	switch res.Status {
+	case BulkAccepted:
+		r.Accepted++
+	case BulkDuplicate:
+		r.Duplicate++
+	case BulkConflict:
+		r.Conflict++
+	case BulkInvalid:
+		r.Invalid++
+	case BulkFailed:
+		r.Failed++
+	}
+	r.Results[i] = res
+}
+
+// bulkOrder — a valid order waiting for the next batch, its line and its place in the report
+type bulkOrder struct {
+	line   int
+	result int
+	order  *model.Order
+}
+
+// handleBulkOrders stores NDJSON orders (one model.Order per line) for partner
+// backfills. The body is read line by line; valid orders are saved in batches of
+// bulkBatchSize and the response reports the result of every line.
+func (s *Server) handleBulkOrders(w http.ResponseWriter, r *http.Request) {
+	log.Println("HTTP POST /orders:bulk")
+
+	ctx := r.Context()
+	reader := bufio.NewReader(r.Body)
+	report := &bulkReport{Results: []bulkResult{}}
+	pending := make([]bulkOrder, 0, bulkBatchSize)
+
+	for line := 1; ; line++ {
+		b, err := readLine(reader, maxOrderBody)
+		if errors.Is(err, errLineTooLong) {
+			report.add(bulkResult{Line: line, Status: BulkInvalid, Error: err.Error()})
+			continue
+		}
+		if err != nil && err != io.EOF {
+			log.Printf("Bulk upload aborted at line %d: %v", line, err)
+			writeError(w, r, http.StatusBadRequest, codeBadRequest, "failed to read request body")
+			return
+		}
+
+		if data := bytes.TrimSpace(b); len(data) > 0 {
+			if order := parseBulkLine(report, line, data); order != nil {
+				pending = append(pending, bulkOrder{line: line, result: report.reserve(), order: order})
+			}
+			if len(pending) == bulkBatchSize {
+				s.saveBulk(ctx, report, pending)
+				pending = pending[:0]
+			}
+		}
+		if err == io.EOF {
+			break
+		}
+	}
+	s.saveBulk(ctx, report, pending)
+
+	log.Printf("Bulk upload: %d accepted, %d duplicate, %d conflict, %d invalid, %d failed",
+		report.Accepted, report.Duplicate, report.Conflict, report.Invalid, report.Failed)
+	writeJSON(w, http.StatusOK, report)
+}
+
+// parseBulkLine decodes and validates a line, reporting it if it is invalid
+func parseBulkLine(report *bulkReport, line int, data []byte) *model.Order {
+	var order model.Order
+	if err := json.Unmarshal(data, &order); err != nil {
+		report.add(bulkResult{Line: line, Status: BulkInvalid, Error: "invalid JSON: " + err.Error()})
+		return nil
+	}
+	if err := validator.ValidateOrder(&order); err != nil {
+		report.add(bulkResult{Line: line, OrderUID: order.OrderUID, Status: BulkInvalid,
+			Error: "validation failed", Fields: validator.FieldErrors(err)})
+		return nil
+	}
+	return &order
+}
+
+// saveBulk stores orders in one transaction. If the batch fails, e.g. because an
+// order already exists, every order is saved on its own to find out which.
+func (s *Server) saveBulk(ctx context.Context, report *bulkReport, pending []bulkOrder) {
+	if len(pending) == 0 {
+		return
+	}
+	ctx = apiSource(ctx)
+
+	orders := make([]*model.Order, len(pending))
+	for i, p := range pending {
+		orders[i] = p.order
+	}
+
+	err := s.service.CreateOrders(ctx, orders)
+	if err == nil {
+		for _, p := range pending {
+			report.set(p.result, bulkResult{Line: p.line, OrderUID: p.order.OrderUID, Status: BulkAccepted})
+		}
+		return
+	}
+	log.Printf("Bulk batch of %d orders failed: %v → saving one by one", len(orders), err)
+
+	for _, p := range pending {
+		res := bulkResult{Line: p.line, OrderUID: p.order.OrderUID, Status: BulkAccepted}
+		err := s.service.CreateOrder(ctx, p.order)
+		switch {
+		case err == nil:
+		case errors.Is(err, model.ErrOrderExists), errors.Is(err, model.ErrDuplicateEvent):
+			res.Status, res.Error = BulkDuplicate, "order already exists"
+		case errors.Is(err, model.ErrOrderConflict):
+			res.Status, res.Error = BulkConflict, "order already exists with different content"
+		default:
+			log.Printf("CreateOrder %s error: %v", p.order.OrderUID, err)
+			res.Status, res.Error = BulkFailed, "failed to save order"
+		}
+		report.set(p.result, res)
+	}
+}
+
+// readLine reads one line of at most max bytes. A longer line is skipped and
+// reported as errLineTooLong; io.EOF comes with the last line, possibly empty.
+func readLine(r *bufio.Reader, max int) ([]byte, error) {
+	var line []byte
+	for {
+		chunk, err := r.ReadSlice('\n')
+		if len(line)+len(chunk) > max {
+			for errors.Is(err, bufio.ErrBufferFull) {
+				_, err = r.ReadSlice('\n')
+			}
+			if err != nil && err != io.EOF {
+				return nil, err
+			}
+			return nil, errLineTooLong
+		}
+		line = append(line, chunk...)
+		if !errors.Is(err, bufio.ErrBufferFull) {
+			return line, err
+		}
+	}
+}
@@ -0,0 +1,130 @@
+package api
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"order-service-wbtech/internal/model"
+)
+
+func postBulk(t *testing.T, srv *Server, body string) bulkReport {
+	t.Helper()
+
+	rec := httptest.NewRecorder()
+	srv.Router().ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/orders:bulk", strings.NewReader(body)))
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	var report bulkReport
+	require.NoError(t, json.NewDecoder(rec.Body).Decode(&report))
+	return report
+}
+
+func ndjson(t *testing.T, orders ...*model.Order) string {
+	t.Helper()
+
+	var buf bytes.Buffer
+	for _, o := range orders {
+		require.NoError(t, json.NewEncoder(&buf).Encode(o))
+	}
+	return buf.String()
+}
+
+func TestHandleBulkOrders_PerLineReport(t *testing.T) {
+	svc := &fakeService{orders: map[string]*model.Order{"old": validOrder("old")}}
+	srv := New(svc)
+
+	invalid := validOrder("invalid")
+	invalid.Payment.Currency = ""
+	changed := validOrder("old")
+	changed.TrackNumber = "OTHER"
+
+	body := ndjson(t, validOrder("a")) +
+		"{not json\n" +
+		"\n" +
+		ndjson(t, validOrder("old"), changed, invalid, validOrder("b"))
+	report := postBulk(t, srv, strings.TrimSuffix(body, "\n"))
+
+	assert.Equal(t, 2, report.Accepted)
+	assert.Equal(t, 1, report.Duplicate)
+	assert.Equal(t, 1, report.Conflict)
+	assert.Equal(t, 2, report.Invalid)
+	assert.Zero(t, report.Failed)
+
+	want := []struct {
+		line   int
+		uid    string
+		status string
+	}{
+		{1, "a", BulkAccepted},
+		{2, "", BulkInvalid},
+		{4, "old", BulkDuplicate},
+		{5, "old", BulkConflict},
+		{6, "invalid", BulkInvalid},
+		{7, "b", BulkAccepted},
+	}
+	require.Len(t, report.Results, len(want))
+	for i, w := range want {
+		got := report.Results[i]
+		assert.Equal(t, w.line, got.Line, "result %d", i)
+		assert.Equal(t, w.uid, got.OrderUID, "result %d", i)
+		assert.Equal(t, w.status, got.Status, "result %d", i)
+	}
+	assert.Equal(t, "payment.currency", report.Results[4].Fields[0].Field)
+	assert.Contains(t, svc.orders, "a")
+	assert.Contains(t, svc.orders, "b")
+	assert.Equal(t, model.SourceAPI, svc.sources["a"].Kind)
+	assert.Equal(t, model.SourceAPI, svc.sources["b"].Kind)
+}
+
+func TestHandleBulkOrders_SavesInBatches(t *testing.T) {
+	svc := &fakeService{}
+	srv := New(svc)
+
+	orders := make([]*model.Order, bulkBatchSize*2+1)
+	for i := range orders {
+		orders[i] = validOrder(fmt.Sprintf("order-%d", i))
+	}
+
+	report := postBulk(t, srv, ndjson(t, orders...))
+	assert.Equal(t, len(orders), report.Accepted)
+	assert.Equal(t, 3, svc.batches)
+	assert.Equal(t, len(orders), svc.created)
+}
+
+func TestHandleBulkOrders_LineTooLong(t *testing.T) {
+	srv := New(&fakeService{})
+
+	body := `{"order_uid":"` + strings.Repeat("x", maxOrderBody) + "\"}\n" + ndjson(t, validOrder("after"))
+	report := postBulk(t, srv, body)
+
+	require.Len(t, report.Results, 2)
+	assert.Equal(t, BulkInvalid, report.Results[0].Status)
+	assert.Equal(t, errLineTooLong.Error(), report.Results[0].Error)
+	assert.Equal(t, BulkAccepted, report.Results[1].Status)
+	assert.Equal(t, 2, report.Results[1].Line)
+}
+
+func TestReadLine(t *testing.T) {
+	r := bufio.NewReaderSize(strings.NewReader("short\n"+strings.Repeat("y", 40)+"\nlast"), 16)
+
+	line, err := readLine(r, 20)
+	require.NoError(t, err)
+	assert.Equal(t, "short\n", string(line))
+
+	_, err = readLine(r, 20)
+	assert.ErrorIs(t, err, errLineTooLong)
+
+	line, err = readLine(r, 20)
+	assert.Equal(t, "last", string(line))
+	assert.Equal(t, io.EOF, err)
+}
@@ -0,0 +1,71 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+
+	"order-service-wbtech/internal/model"
+)
+
+// Codes of errorResponse, stable for clients and monitors
+const (
+	codeBadRequest    = "bad_request"
+	codeValidation    = "validation_failed"
+	codeUnauthorized  = "unauthorized"
+	codeNotFound      = "not_found"
+	codeConflict      = "conflict"
+	codeTooLarge      = "payload_too_large"
+	codeUnprocessable = "unprocessable"
+	codeUnavailable   = "unavailable"
+	codeInternal      = "internal"
+)
+
+// unavailableRetryAfter — Retry-After of a 503, in seconds
+const unavailableRetryAfter = "5"
+
+// errorResponse — JSON body of every failed request. RequestID matches the
+// X-Request-ID response header and the log lines of the request; Fields lists
+// validation errors.
+type errorResponse struct {
+	Code      string             `json:"code"`
+	Message   string             `json:"message"`
+	RequestID string             `json:"request_id,omitempty"`
+	Fields    []model.FieldError `json:"fields,omitempty"`
+}
+
+func newError(ctx context.Context, code, message string) errorResponse {
+	return errorResponse{Code: code, Message: message, RequestID: requestID(ctx)}
+}
+
+func writeError(w http.ResponseWriter, r *http.Request, status int, code, message string) {
+	writeJSON(w, status, newError(r.Context(), code, message))
+}
+
+// serviceError maps a domain error to a status and response: ErrInvalidArgument
+// is 400, ErrNotFound 404 ("<what> not found"), ErrUnavailable 503 and anything
+// else 500. Server errors are logged under op; their details stay in the log.
+func serviceError(ctx context.Context, op, what string, err error) (int, errorResponse) {
+	switch {
+	case errors.Is(err, model.ErrInvalidArgument):
+		return http.StatusBadRequest, newError(ctx, codeBadRequest, err.Error())
+	case errors.Is(err, model.ErrNotFound):
+		return http.StatusNotFound, newError(ctx, codeNotFound, what+" not found")
+	case errors.Is(err, model.ErrUnavailable):
+		log.Printf("%s error (request %s): %v", op, requestID(ctx), err)
+		return http.StatusServiceUnavailable, newError(ctx, codeUnavailable, "service temporarily unavailable, retry later")
+	default:
+		log.Printf("%s error (request %s): %v", op, requestID(ctx), err)
+		return http.StatusInternalServerError, newError(ctx, codeInternal, "internal error")
+	}
+}
+
+// writeServiceError writes the response of serviceError
+func writeServiceError(w http.ResponseWriter, r *http.Request, op, what string, err error) {
+	code, resp := serviceError(r.Context(), op, what, err)
+	if code == http.StatusServiceUnavailable {
+		w.Header().Set("Retry-After", unavailableRetryAfter)
+	}
+	writeJSON(w, code, resp)
+}
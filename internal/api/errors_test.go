@@ -0,0 +1,76 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"order-service-wbtech/internal/model"
+)
+
+func TestHandleGetOrder_Errors(t *testing.T) {
+	tests := []struct {
+		name   string
+		err    error
+		status int
+		code   string
+	}{
+		{"not found", fmt.Errorf("get order x: %w", model.ErrNotFound), http.StatusNotFound, codeNotFound},
+		{"database down", fmt.Errorf("get order x: %w", model.ErrUnavailable), http.StatusServiceUnavailable, codeUnavailable},
+		{"unexpected", errors.New("scan: unexpected column"), http.StatusInternalServerError, codeInternal},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := New(&fakeService{getErr: tt.err})
+
+			rec := httptest.NewRecorder()
+			srv.Router().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/order/x", nil))
+			require.Equal(t, tt.status, rec.Code)
+			assert.Equal(t, "application/json", rec.Header().Get("Content-Type"))
+
+			var resp errorResponse
+			require.NoError(t, json.NewDecoder(rec.Body).Decode(&resp))
+			assert.Equal(t, tt.code, resp.Code)
+			assert.NotEmpty(t, resp.Message)
+			assert.NotContains(t, resp.Message, "unexpected column")
+			assert.Equal(t, rec.Header().Get(requestIDHeader), resp.RequestID)
+			assert.NotEmpty(t, resp.RequestID)
+		})
+	}
+}
+
+func TestHandleGetOrder_UnavailableRetryAfter(t *testing.T) {
+	srv := New(&fakeService{getErr: model.ErrUnavailable})
+
+	rec := httptest.NewRecorder()
+	srv.Router().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/order/x", nil))
+	assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+	assert.Equal(t, unavailableRetryAfter, rec.Header().Get("Retry-After"))
+}
+
+func TestRequestID(t *testing.T) {
+	srv := New(&fakeService{})
+
+	r := httptest.NewRequest(http.MethodGet, "/order/missing", nil)
+	r.Header.Set(requestIDHeader, "lb-42")
+	rec := httptest.NewRecorder()
+	srv.Router().ServeHTTP(rec, r)
+
+	assert.Equal(t, "lb-42", rec.Header().Get(requestIDHeader))
+	var resp errorResponse
+	require.NoError(t, json.NewDecoder(rec.Body).Decode(&resp))
+	assert.Equal(t, "lb-42", resp.RequestID)
+
+	// every request gets its own ID
+	first, second := httptest.NewRecorder(), httptest.NewRecorder()
+	srv.Router().ServeHTTP(first, httptest.NewRequest(http.MethodGet, "/order/missing", nil))
+	srv.Router().ServeHTTP(second, httptest.NewRequest(http.MethodGet, "/order/missing", nil))
+	assert.NotEqual(t, first.Header().Get(requestIDHeader), second.Header().Get(requestIDHeader))
+}
@@ -7,15 +7,31 @@ import (
 	"net/http"
 	"strings"
 
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+
 	"order-service-wbtech/internal/model"
+	"order-service-wbtech/internal/tracing"
 )
 
+// truncatedHeader marks a response listing only part of the matching orders
+const truncatedHeader = "X-Truncated"
+
 type Service interface {
+	CreateOrder(ctx context.Context, order *model.Order) error
+	CreateOrders(ctx context.Context, orders []*model.Order) error
 	GetOrder(ctx context.Context, orderUID string) (*model.Order, error)
+	GetOrderHistory(ctx context.Context, orderUID string) ([]model.OrderEvent, error)
+	ListOrders(ctx context.Context, filter model.OrderFilter) (*model.OrderPage, error)
+	GetOrdersByTrack(ctx context.Context, trackNumber string) (*model.TrackOrders, error)
 }
 
 type Server struct {
-	service Service
+	service     Service
+	idempotency IdempotencyStore
+
+	control    ConsumerControl
+	rejected   RejectedMessages
+	adminToken string
 }
 
 func New(s Service) *Server {
@@ -29,8 +45,15 @@ func (s *Server) Router() http.Handler {
 
 	mux.Handle("/", http.FileServer(http.Dir("frontend")))
 	mux.HandleFunc("/order/", s.handleGetOrder)
+	mux.HandleFunc("GET /order/{uid}/history", s.handleGetOrderHistory)
+	mux.HandleFunc("GET /orders", s.handleListOrders)
+	mux.HandleFunc("GET /orders/by-track/{track_number}", s.handleGetOrdersByTrack)
+	mux.HandleFunc("POST /orders", s.handleCreateOrder)
+	mux.HandleFunc("POST /orders:bulk", s.handleBulkOrders)
+	mux.Handle("GET /metrics", promhttp.Handler())
+	s.registerAdmin(mux)
 
-	return mux
+	return tracing.Middleware(withRequestID(mux))
 }
 
 func (s *Server) handleGetOrder(w http.ResponseWriter, r *http.Request) {
@@ -38,7 +61,7 @@ func (s *Server) handleGetOrder(w http.ResponseWriter, r *http.Request) {
 	orderUID = strings.Trim(orderUID, "/")
 
 	if orderUID == "" {
-		http.Error(w, "order_uid is required", http.StatusBadRequest)
+		writeError(w, r, http.StatusBadRequest, codeBadRequest, "order_uid is required")
 		return
 	}
 
@@ -46,8 +69,7 @@ func (s *Server) handleGetOrder(w http.ResponseWriter, r *http.Request) {
 
 	order, err := s.service.GetOrder(r.Context(), orderUID)
 	if err != nil {
-		log.Printf("GetOrder error: %v", err)
-		http.Error(w, "Order not found", http.StatusNotFound)
+		writeServiceError(w, r, "GetOrder", "order", err)
 		return
 	}
 
@@ -56,3 +78,39 @@ func (s *Server) handleGetOrder(w http.ResponseWriter, r *http.Request) {
 		log.Printf("json encode error: %v", err)
 	}
 }
+
+func (s *Server) handleGetOrderHistory(w http.ResponseWriter, r *http.Request) {
+	orderUID := r.PathValue("uid")
+
+	log.Printf("HTTP GET /order/%s/history", orderUID)
+
+	events, err := s.service.GetOrderHistory(r.Context(), orderUID)
+	if err != nil {
+		writeServiceError(w, r, "GetOrderHistory", "order", err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(events); err != nil {
+		log.Printf("json encode error: %v", err)
+	}
+}
+
+// handleGetOrdersByTrack returns the orders carrying a track number, on the
+// order or on one of its items, newest first. When the track has more orders
+// than are listed, the response carries X-Truncated: true.
+func (s *Server) handleGetOrdersByTrack(w http.ResponseWriter, r *http.Request) {
+	trackNumber := r.PathValue("track_number")
+
+	log.Printf("HTTP GET /orders/by-track/%s", trackNumber)
+
+	found, err := s.service.GetOrdersByTrack(r.Context(), trackNumber)
+	if err != nil {
+		writeServiceError(w, r, "GetOrdersByTrack", "order", err)
+		return
+	}
+	if found.Truncated {
+		w.Header().Set(truncatedHeader, "true")
+	}
+	writeJSON(w, http.StatusOK, found.Orders)
+}
@@ -0,0 +1,188 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"order-service-wbtech/internal/model"
+)
+
+// fakeService — in-memory Service for handler tests
+type fakeService struct {
+	orders  map[string]*model.Order
+	history map[string][]model.OrderEvent
+
+	created   int
+	batches   int
+	createErr error
+	getErr    error
+	sources   map[string]model.EventSource
+	filter    model.OrderFilter
+	// truncated marks every track lookup as truncated
+	truncated bool
+}
+
+func (f *fakeService) CreateOrder(ctx context.Context, order *model.Order) error {
+	if f.createErr != nil {
+		return f.createErr
+	}
+	if src, ok := model.EventSourceFrom(ctx, order.OrderUID); ok {
+		if f.sources == nil {
+			f.sources = make(map[string]model.EventSource)
+		}
+		f.sources[order.OrderUID] = src
+	}
+	if stored, ok := f.orders[order.OrderUID]; ok {
+		if stored.TrackNumber == order.TrackNumber {
+			return model.ErrOrderExists
+		}
+		return model.ErrOrderConflict
+	}
+	if f.orders == nil {
+		f.orders = make(map[string]*model.Order)
+	}
+	order.Status = model.StatusCreated
+	f.orders[order.OrderUID] = order
+	f.created++
+	return nil
+}
+
+// CreateOrders fails the whole batch on an existing order, like storage.SaveOrders
+func (f *fakeService) CreateOrders(ctx context.Context, orders []*model.Order) error {
+	f.batches++
+	if f.createErr != nil {
+		return f.createErr
+	}
+	seen := make(map[string]bool)
+	for _, order := range orders {
+		if _, ok := f.orders[order.OrderUID]; ok || seen[order.OrderUID] {
+			return errors.New("duplicate key value violates unique constraint")
+		}
+		seen[order.OrderUID] = true
+	}
+	for _, order := range orders {
+		_ = f.CreateOrder(ctx, order)
+	}
+	return nil
+}
+
+func (f *fakeService) ListOrders(_ context.Context, filter model.OrderFilter) (*model.OrderPage, error) {
+	f.filter = filter
+	page := &model.OrderPage{Orders: []*model.Order{}}
+	for _, o := range f.orders {
+		page.Orders = append(page.Orders, o)
+	}
+	return page, nil
+}
+
+func (f *fakeService) GetOrder(_ context.Context, orderUID string) (*model.Order, error) {
+	if f.getErr != nil {
+		return nil, f.getErr
+	}
+	if o, ok := f.orders[orderUID]; ok {
+		return o, nil
+	}
+	return nil, model.ErrNotFound
+}
+
+func (f *fakeService) GetOrdersByTrack(_ context.Context, trackNumber string) (*model.TrackOrders, error) {
+	var orders []*model.Order
+	for _, o := range f.orders {
+		if o.TrackNumber == trackNumber {
+			orders = append(orders, o)
+		}
+	}
+	if len(orders) == 0 {
+		return nil, model.ErrNotFound
+	}
+	return &model.TrackOrders{Orders: orders, Truncated: f.truncated}, nil
+}
+
+func (f *fakeService) GetOrderHistory(_ context.Context, orderUID string) ([]model.OrderEvent, error) {
+	if h, ok := f.history[orderUID]; ok {
+		return h, nil
+	}
+	return nil, model.ErrNotFound
+}
+
+func TestHandleGetOrder(t *testing.T) {
+	srv := New(&fakeService{orders: map[string]*model.Order{"123": {OrderUID: "123"}}})
+
+	rec := httptest.NewRecorder()
+	srv.Router().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/order/123", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	var got model.Order
+	require.NoError(t, json.NewDecoder(rec.Body).Decode(&got))
+	assert.Equal(t, "123", got.OrderUID)
+}
+
+func TestHandleGetOrderHistory(t *testing.T) {
+	srv := New(&fakeService{history: map[string][]model.OrderEvent{
+		"123": {
+			{ID: 1, OrderUID: "123", EventType: model.EventCreated, Status: model.StatusCreated},
+			{ID: 2, OrderUID: "123", EventType: model.EventStatusChanged, Status: model.StatusPaid},
+		},
+	}})
+
+	rec := httptest.NewRecorder()
+	srv.Router().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/order/123/history", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	var got []model.OrderEvent
+	require.NoError(t, json.NewDecoder(rec.Body).Decode(&got))
+	require.Len(t, got, 2)
+	assert.Equal(t, model.StatusPaid, got[1].Status)
+
+	rec = httptest.NewRecorder()
+	srv.Router().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/order/404/history", nil))
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+}
+
+func TestHandleGetOrdersByTrack(t *testing.T) {
+	srv := New(&fakeService{orders: map[string]*model.Order{
+		"a": {OrderUID: "a", TrackNumber: "WBILMTESTTRACK"},
+		"b": {OrderUID: "b", TrackNumber: "OTHER"},
+	}})
+
+	rec := httptest.NewRecorder()
+	srv.Router().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/orders/by-track/WBILMTESTTRACK", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	var got []model.Order
+	require.NoError(t, json.NewDecoder(rec.Body).Decode(&got))
+	require.Len(t, got, 1)
+	assert.Equal(t, "a", got[0].OrderUID)
+	assert.Empty(t, rec.Header().Get(truncatedHeader))
+
+	rec = httptest.NewRecorder()
+	srv.Router().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/orders/by-track/UNKNOWN", nil))
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+}
+
+func TestHandleGetOrdersByTrack_Truncated(t *testing.T) {
+	srv := New(&fakeService{truncated: true, orders: map[string]*model.Order{
+		"a": {OrderUID: "a", TrackNumber: "WBILMTESTTRACK"},
+	}})
+
+	rec := httptest.NewRecorder()
+	srv.Router().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/orders/by-track/WBILMTESTTRACK", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "true", rec.Header().Get(truncatedHeader))
+}
+
+func TestMetricsEndpoint(t *testing.T) {
+	srv := New(&fakeService{})
+
+	rec := httptest.NewRecorder()
+	srv.Router().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), "go_goroutines")
+}
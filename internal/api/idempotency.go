@@ -0,0 +1,102 @@
+package api
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"log"
+	"net/http"
+	"time"
+
+	"order-service-wbtech/internal/model"
+)
+
+const (
+	// idempotencyTTL — how long a response is replayed for retries with the same key
+	idempotencyTTL = 24 * time.Hour
+	// maxIdempotencyKey caps the length of an Idempotency-Key
+	maxIdempotencyKey = 255
+)
+
+// IdempotencyStore keeps the responses of requests made with an Idempotency-Key,
+// implemented by *storage.Postgres
+type IdempotencyStore interface {
+	ClaimIdempotencyKey(ctx context.Context, key, requestHash string, ttl time.Duration) (*model.IdempotencyRecord, error)
+	CompleteIdempotencyKey(ctx context.Context, key string, statusCode int, response []byte) error
+	ReleaseIdempotencyKey(ctx context.Context, key string) error
+}
+
+// EnableIdempotency makes write endpoints honour the Idempotency-Key header;
+// without a store the header is ignored
+func (s *Server) EnableIdempotency(store IdempotencyStore) {
+	s.idempotency = store
+}
+
+// idempotent runs handle and writes its JSON response. With an Idempotency-Key the
+// response is stored, and a retry with the same key and body gets it again
+// without handle running twice. A key reused with another body gets 422, a key
+// whose first request is still running gets 409. Server errors are not stored,
+// so the retry is handled again.
+func (s *Server) idempotent(w http.ResponseWriter, r *http.Request, body []byte, handle func(ctx context.Context) (int, any)) {
+	key := r.Header.Get("Idempotency-Key")
+	if key == "" || s.idempotency == nil {
+		code, resp := handle(r.Context())
+		writeJSON(w, code, resp)
+		return
+	}
+	if len(key) > maxIdempotencyKey {
+		writeError(w, r, http.StatusBadRequest, codeBadRequest, "Idempotency-Key is too long")
+		return
+	}
+
+	sum := sha256.Sum256(body)
+	hash := hex.EncodeToString(sum[:])
+
+	rec, err := s.idempotency.ClaimIdempotencyKey(r.Context(), key, hash, idempotencyTTL)
+	if err != nil {
+		writeServiceError(w, r, "ClaimIdempotencyKey", "Idempotency-Key", err)
+		return
+	}
+	if rec != nil {
+		replayIdempotent(w, r, rec, hash)
+		return
+	}
+
+	code, resp := handle(r.Context())
+	b, err := json.Marshal(resp)
+	if err != nil {
+		log.Printf("json encode error: %v", err)
+		code, resp = http.StatusInternalServerError, newError(r.Context(), codeInternal, "internal error")
+		b, _ = json.Marshal(resp)
+	}
+
+	// record the outcome even if the client has gone away, it will retry
+	ctx := context.WithoutCancel(r.Context())
+	if code >= http.StatusInternalServerError {
+		err = s.idempotency.ReleaseIdempotencyKey(ctx, key)
+	} else {
+		err = s.idempotency.CompleteIdempotencyKey(ctx, key, code, b)
+	}
+	if err != nil {
+		log.Printf("Idempotency-Key %q: %v", key, err)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	w.Write(append(b, '\n'))
+}
+
+func replayIdempotent(w http.ResponseWriter, r *http.Request, rec *model.IdempotencyRecord, hash string) {
+	switch {
+	case rec.RequestHash != hash:
+		writeError(w, r, http.StatusUnprocessableEntity, codeUnprocessable, "Idempotency-Key was already used with a different request")
+	case !rec.Completed:
+		writeError(w, r, http.StatusConflict, codeConflict, "a request with this Idempotency-Key is in progress")
+	default:
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Idempotent-Replayed", "true")
+		w.WriteHeader(rec.StatusCode)
+		w.Write(rec.Response)
+	}
+}
@@ -0,0 +1,69 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+
+	"order-service-wbtech/internal/model"
+	"order-service-wbtech/internal/validator"
+)
+
+// maxOrderBody caps the body of POST /orders
+const maxOrderBody = 1 << 20
+
+// handleCreateOrder stores an order sent over HTTP by partners that cannot
+// publish to Kafka: 201 with the order, 409 if the order_uid is taken, 422 with
+// the invalid fields. Retries with the same Idempotency-Key get the first response.
+func (s *Server) handleCreateOrder(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxOrderBody))
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			writeError(w, r, http.StatusRequestEntityTooLarge, codeTooLarge, "request body too large")
+			return
+		}
+		writeError(w, r, http.StatusBadRequest, codeBadRequest, "failed to read request body")
+		return
+	}
+
+	log.Println("HTTP POST /orders")
+
+	s.idempotent(w, r, body, func(ctx context.Context) (int, any) {
+		return s.createOrder(ctx, body)
+	})
+}
+
+func (s *Server) createOrder(ctx context.Context, body []byte) (int, any) {
+	var order model.Order
+	if err := json.Unmarshal(body, &order); err != nil {
+		return http.StatusBadRequest, newError(ctx, codeBadRequest, "invalid JSON: "+err.Error())
+	}
+
+	if err := validator.ValidateOrder(&order); err != nil {
+		resp := newError(ctx, codeValidation, "validation failed")
+		resp.Fields = validator.FieldErrors(err)
+		return http.StatusUnprocessableEntity, resp
+	}
+
+	err := s.service.CreateOrder(apiSource(ctx), &order)
+	switch {
+	case err == nil:
+		return http.StatusCreated, &order
+	case errors.Is(err, model.ErrOrderExists), errors.Is(err, model.ErrDuplicateEvent):
+		return http.StatusConflict, newError(ctx, codeConflict, "order "+order.OrderUID+" already exists")
+	case errors.Is(err, model.ErrOrderConflict):
+		return http.StatusConflict, newError(ctx, codeConflict, "order "+order.OrderUID+" already exists with different content")
+	default:
+		return serviceError(ctx, "CreateOrder", "order", err)
+	}
+}
+
+// apiSource records orders stored by an API request as coming from the API,
+// referenced by the request ID
+func apiSource(ctx context.Context) context.Context {
+	return model.WithEventSource(ctx, model.EventSource{Kind: model.SourceAPI, Reference: requestID(ctx)})
+}
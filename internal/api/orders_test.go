@@ -0,0 +1,228 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"order-service-wbtech/internal/model"
+)
+
+func validOrder(uid string) *model.Order {
+	return &model.Order{
+		OrderUID:        uid,
+		TrackNumber:     "WBILMTESTTRACK",
+		Entry:           "WBIL",
+		Locale:          "en",
+		CustomerID:      "test",
+		DeliveryService: "meest",
+		ShardKey:        "9",
+		SMID:            99,
+		DateCreated:     time.Date(2021, 11, 26, 6, 22, 19, 0, time.UTC),
+		OOFShard:        "1",
+		Delivery: model.Delivery{
+			Name:    "Test Testov",
+			Phone:   "+9720000000",
+			Zip:     "2639809",
+			City:    "Kiryat Mozkin",
+			Address: "Ploshad Mira 15",
+			Region:  "Kraiot",
+			Email:   "test@gmail.com",
+		},
+		Payment: model.Payment{
+			Transaction:  uid,
+			Currency:     "USD",
+			Provider:     "wbpay",
+			Amount:       1817,
+			PaymentDt:    1637907727,
+			Bank:         "alpha",
+			DeliveryCost: 1500,
+			GoodsTotal:   317,
+		},
+		Items: []model.Item{{
+			ChrtID:      9934930,
+			TrackNumber: "WBILMTESTTRACK",
+			Price:       453,
+			Rid:         "ab4219087a764ae0btest",
+			Name:        "Mascaras",
+			Sale:        30,
+			Size:        "0",
+			TotalPrice:  317,
+			NmID:        2389212,
+			Brand:       "Vivienne Sabo",
+			Status:      202,
+		}},
+	}
+}
+
+// memoryIdempotency — IdempotencyStore backed by a map
+type memoryIdempotency struct {
+	mu   sync.Mutex
+	keys map[string]*model.IdempotencyRecord
+}
+
+func newMemoryIdempotency() *memoryIdempotency {
+	return &memoryIdempotency{keys: make(map[string]*model.IdempotencyRecord)}
+}
+
+func (m *memoryIdempotency) ClaimIdempotencyKey(_ context.Context, key, requestHash string, _ time.Duration) (*model.IdempotencyRecord, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if rec, ok := m.keys[key]; ok {
+		cp := *rec
+		return &cp, nil
+	}
+	m.keys[key] = &model.IdempotencyRecord{Key: key, RequestHash: requestHash}
+	return nil, nil
+}
+
+func (m *memoryIdempotency) CompleteIdempotencyKey(_ context.Context, key string, statusCode int, response []byte) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	rec := m.keys[key]
+	rec.Completed, rec.StatusCode, rec.Response = true, statusCode, response
+	return nil
+}
+
+func (m *memoryIdempotency) ReleaseIdempotencyKey(_ context.Context, key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.keys, key)
+	return nil
+}
+
+func postOrder(t *testing.T, srv *Server, body any, key string) *httptest.ResponseRecorder {
+	t.Helper()
+
+	b, ok := body.([]byte)
+	if !ok {
+		var err error
+		b, err = json.Marshal(body)
+		require.NoError(t, err)
+	}
+	r := httptest.NewRequest(http.MethodPost, "/orders", bytes.NewReader(b))
+	if key != "" {
+		r.Header.Set("Idempotency-Key", key)
+	}
+	rec := httptest.NewRecorder()
+	srv.Router().ServeHTTP(rec, r)
+	return rec
+}
+
+func TestHandleCreateOrder(t *testing.T) {
+	svc := &fakeService{}
+	srv := New(svc)
+
+	rec := postOrder(t, srv, validOrder("new"), "")
+	require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+	var created model.Order
+	require.NoError(t, json.NewDecoder(rec.Body).Decode(&created))
+	assert.Equal(t, "new", created.OrderUID)
+	assert.Equal(t, model.StatusCreated, created.Status)
+
+	rec = postOrder(t, srv, validOrder("new"), "")
+	assert.Equal(t, http.StatusConflict, rec.Code)
+
+	changed := validOrder("new")
+	changed.TrackNumber = "OTHER"
+	rec = postOrder(t, srv, changed, "")
+	assert.Equal(t, http.StatusConflict, rec.Code)
+	assert.Contains(t, rec.Body.String(), "different content")
+
+	rec = postOrder(t, srv, []byte("{not json"), "")
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+	svc.createErr = errors.New("connection refused")
+	rec = postOrder(t, srv, validOrder("down"), "")
+	assert.Equal(t, http.StatusInternalServerError, rec.Code)
+	assert.NotContains(t, rec.Body.String(), "connection refused")
+
+	svc.createErr = fmt.Errorf("failed to save order to db: %w", model.ErrUnavailable)
+	rec = postOrder(t, srv, validOrder("down"), "")
+	assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+}
+
+func TestHandleCreateOrder_ValidationErrors(t *testing.T) {
+	srv := New(&fakeService{})
+
+	order := validOrder("invalid")
+	order.Payment.Currency = ""
+	order.Delivery.Email = "not-an-email"
+
+	rec := postOrder(t, srv, order, "")
+	require.Equal(t, http.StatusUnprocessableEntity, rec.Code)
+
+	var resp errorResponse
+	require.NoError(t, json.NewDecoder(rec.Body).Decode(&resp))
+	fields := make(map[string]bool)
+	for _, f := range resp.Fields {
+		fields[f.Field] = true
+	}
+	assert.True(t, fields["payment.currency"], resp.Fields)
+	assert.True(t, fields["delivery.email"], resp.Fields)
+}
+
+func TestHandleCreateOrder_IdempotencyKey(t *testing.T) {
+	svc := &fakeService{}
+	store := newMemoryIdempotency()
+	srv := New(svc)
+	srv.EnableIdempotency(store)
+
+	first := postOrder(t, srv, validOrder("retry"), "key-1")
+	require.Equal(t, http.StatusCreated, first.Code)
+
+	// a retry gets the stored response instead of 409
+	retry := postOrder(t, srv, validOrder("retry"), "key-1")
+	assert.Equal(t, http.StatusCreated, retry.Code)
+	assert.Equal(t, "true", retry.Header().Get("Idempotent-Replayed"))
+	assert.JSONEq(t, first.Body.String(), retry.Body.String())
+	assert.Equal(t, 1, svc.created)
+
+	// the same key with another body
+	rec := postOrder(t, srv, validOrder("other"), "key-1")
+	assert.Equal(t, http.StatusUnprocessableEntity, rec.Code)
+
+	// a first request still running
+	busy, err := json.Marshal(validOrder("busy"))
+	require.NoError(t, err)
+	sum := sha256.Sum256(busy)
+	_, _ = store.ClaimIdempotencyKey(context.Background(), "key-2", hex.EncodeToString(sum[:]), 0)
+	rec = postOrder(t, srv, busy, "key-2")
+	assert.Equal(t, http.StatusConflict, rec.Code)
+
+	// server errors are not stored, the retry is handled again
+	svc.createErr = errors.New("connection refused")
+	rec = postOrder(t, srv, validOrder("flaky"), "key-3")
+	assert.Equal(t, http.StatusInternalServerError, rec.Code)
+	svc.createErr = nil
+	rec = postOrder(t, srv, validOrder("flaky"), "key-3")
+	assert.Equal(t, http.StatusCreated, rec.Code)
+	assert.Empty(t, rec.Header().Get("Idempotent-Replayed"))
+}
+
+func TestHandleCreateOrder_RecordsAPISource(t *testing.T) {
+	svc := &fakeService{}
+	srv := New(svc)
+
+	b, err := json.Marshal(validOrder("audited"))
+	require.NoError(t, err)
+	r := httptest.NewRequest(http.MethodPost, "/orders", bytes.NewReader(b))
+	r.Header.Set(requestIDHeader, "req-7")
+	rec := httptest.NewRecorder()
+	srv.Router().ServeHTTP(rec, r)
+	require.Equal(t, http.StatusCreated, rec.Code)
+
+	assert.Equal(t, model.EventSource{Kind: model.SourceAPI, Reference: "req-7"}, svc.sources["audited"])
+}
@@ -0,0 +1,108 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+
+	"order-service-wbtech/internal/kafka"
+	"order-service-wbtech/internal/model"
+)
+
+// maxRejectedLimit caps the page size of GET /admin/rejected-messages
+const maxRejectedLimit = 500
+
+// RejectedMessages lists and replays quarantined messages, implemented by *kafka.Quarantine
+type RejectedMessages interface {
+	List(ctx context.Context, filter model.RejectedFilter) ([]model.RejectedMessage, error)
+	Get(ctx context.Context, id int64) (*model.RejectedMessage, error)
+	Replay(ctx context.Context, id int64) (*model.RejectedMessage, error)
+}
+
+// handleListRejected lists rejected messages newest first. Filters: topic, stage;
+// paging: limit and before (the last id of the previous page).
+func (s *Server) handleListRejected(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	filter := model.RejectedFilter{Topic: q.Get("topic"), Stage: q.Get("stage")}
+
+	var err error
+	if v := q.Get("limit"); v != "" {
+		if filter.Limit, err = strconv.Atoi(v); err != nil || filter.Limit <= 0 || filter.Limit > maxRejectedLimit {
+			writeError(w, r, http.StatusBadRequest, codeBadRequest, "invalid limit")
+			return
+		}
+	}
+	if v := q.Get("before"); v != "" {
+		if filter.BeforeID, err = strconv.ParseInt(v, 10, 64); err != nil {
+			writeError(w, r, http.StatusBadRequest, codeBadRequest, "invalid before")
+			return
+		}
+	}
+
+	msgs, err := s.rejected.List(r.Context(), filter)
+	if err != nil {
+		writeServiceError(w, r, "ListRejectedMessages", "rejected message", err)
+		return
+	}
+	writeJSON(w, http.StatusOK, msgs)
+}
+
+func (s *Server) handleGetRejected(w http.ResponseWriter, r *http.Request) {
+	id, ok := rejectedID(w, r)
+	if !ok {
+		return
+	}
+
+	msg, err := s.rejected.Get(r.Context(), id)
+	if err != nil {
+		writeRejectedError(w, r, "GetRejectedMessage", err)
+		return
+	}
+	writeJSON(w, http.StatusOK, msg)
+}
+
+// handleReplayRejected resubmits a rejected message through the pipeline and
+// returns it with the replay outcome
+func (s *Server) handleReplayRejected(w http.ResponseWriter, r *http.Request) {
+	id, ok := rejectedID(w, r)
+	if !ok {
+		return
+	}
+
+	log.Printf("HTTP POST /admin/rejected-messages/%d/replay", id)
+
+	msg, err := s.rejected.Replay(r.Context(), id)
+	if err != nil {
+		writeRejectedError(w, r, "ReplayRejectedMessage", err)
+		return
+	}
+	writeJSON(w, http.StatusOK, msg)
+}
+
+func rejectedID(w http.ResponseWriter, r *http.Request) (int64, bool) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, codeBadRequest, "invalid id")
+		return 0, false
+	}
+	return id, true
+}
+
+func writeRejectedError(w http.ResponseWriter, r *http.Request, op string, err error) {
+	if errors.Is(err, kafka.ErrTopicNotSubscribed) {
+		writeError(w, r, http.StatusUnprocessableEntity, codeUnprocessable, err.Error())
+		return
+	}
+	writeServiceError(w, r, op, "rejected message", err)
+}
+
+func writeJSON(w http.ResponseWriter, code int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Printf("json encode error: %v", err)
+	}
+}
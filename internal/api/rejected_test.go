@@ -0,0 +1,132 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"order-service-wbtech/internal/kafka"
+	"order-service-wbtech/internal/model"
+)
+
+// fakeRejected — RejectedMessages backed by a map
+type fakeRejected struct {
+	msgs   map[int64]model.RejectedMessage
+	filter model.RejectedFilter
+}
+
+func (f *fakeRejected) List(_ context.Context, filter model.RejectedFilter) ([]model.RejectedMessage, error) {
+	f.filter = filter
+	var out []model.RejectedMessage
+	for _, msg := range f.msgs {
+		out = append(out, msg)
+	}
+	return out, nil
+}
+
+func (f *fakeRejected) Get(_ context.Context, id int64) (*model.RejectedMessage, error) {
+	msg, ok := f.msgs[id]
+	if !ok {
+		return nil, fmt.Errorf("rejected message %d: %w", id, model.ErrNotFound)
+	}
+	return &msg, nil
+}
+
+func (f *fakeRejected) Replay(ctx context.Context, id int64) (*model.RejectedMessage, error) {
+	msg, err := f.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if msg.Topic != "orders" {
+		return nil, kafka.ErrTopicNotSubscribed
+	}
+	now := time.Now()
+	msg.ReplayedAt, msg.ReplayOutcome = &now, string(kafka.OutcomeInserted)
+	return msg, nil
+}
+
+func rejectedServer() (*Server, *fakeRejected) {
+	rejected := &fakeRejected{msgs: map[int64]model.RejectedMessage{
+		1: {ID: 1, Topic: "orders", Stage: kafka.StageValidate, Payload: []byte(`{"order_uid":"1"}`),
+			FieldErrors: []model.FieldError{{Field: "payment.currency", Message: "is required"}}},
+		2: {ID: 2, Topic: "legacy", Stage: kafka.StageDecode},
+	}}
+	srv := New(&fakeService{})
+	srv.EnableAdmin(nil, "secret")
+	srv.EnableQuarantine(rejected)
+	return srv, rejected
+}
+
+func TestRejected_List(t *testing.T) {
+	srv, rejected := rejectedServer()
+
+	rec := httptest.NewRecorder()
+	srv.Router().ServeHTTP(rec, adminRequest(http.MethodGet, "/admin/rejected-messages?topic=orders&stage=validate&before=10&limit=5", "secret"))
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	var msgs []model.RejectedMessage
+	require.NoError(t, json.NewDecoder(rec.Body).Decode(&msgs))
+	assert.Len(t, msgs, 2)
+	assert.Equal(t, model.RejectedFilter{Topic: "orders", Stage: "validate", BeforeID: 10, Limit: 5}, rejected.filter)
+
+	for _, target := range []string{"/admin/rejected-messages?limit=0", "/admin/rejected-messages?limit=1000", "/admin/rejected-messages?before=x"} {
+		rec := httptest.NewRecorder()
+		srv.Router().ServeHTTP(rec, adminRequest(http.MethodGet, target, "secret"))
+		assert.Equal(t, http.StatusBadRequest, rec.Code, target)
+	}
+}
+
+func TestRejected_Get(t *testing.T) {
+	srv, _ := rejectedServer()
+
+	rec := httptest.NewRecorder()
+	srv.Router().ServeHTTP(rec, adminRequest(http.MethodGet, "/admin/rejected-messages/1", "secret"))
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	var msg model.RejectedMessage
+	require.NoError(t, json.NewDecoder(rec.Body).Decode(&msg))
+	assert.Equal(t, `{"order_uid":"1"}`, string(msg.Payload))
+	assert.Equal(t, "payment.currency", msg.FieldErrors[0].Field)
+
+	for target, want := range map[string]int{
+		"/admin/rejected-messages/404": http.StatusNotFound,
+		"/admin/rejected-messages/abc": http.StatusBadRequest,
+	} {
+		rec := httptest.NewRecorder()
+		srv.Router().ServeHTTP(rec, adminRequest(http.MethodGet, target, "secret"))
+		assert.Equal(t, want, rec.Code, target)
+	}
+
+	rec = httptest.NewRecorder()
+	srv.Router().ServeHTTP(rec, adminRequest(http.MethodGet, "/admin/rejected-messages/1", ""))
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+}
+
+func TestRejected_Replay(t *testing.T) {
+	srv, _ := rejectedServer()
+
+	rec := httptest.NewRecorder()
+	srv.Router().ServeHTTP(rec, adminRequest(http.MethodPost, "/admin/rejected-messages/1/replay", "secret"))
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	var msg model.RejectedMessage
+	require.NoError(t, json.NewDecoder(rec.Body).Decode(&msg))
+	assert.Equal(t, string(kafka.OutcomeInserted), msg.ReplayOutcome)
+	assert.NotNil(t, msg.ReplayedAt)
+
+	for target, want := range map[string]int{
+		"/admin/rejected-messages/2/replay":   http.StatusUnprocessableEntity,
+		"/admin/rejected-messages/404/replay": http.StatusNotFound,
+	} {
+		rec := httptest.NewRecorder()
+		srv.Router().ServeHTTP(rec, adminRequest(http.MethodPost, target, "secret"))
+		assert.Equal(t, want, rec.Code, target)
+	}
+}
@@ -0,0 +1,78 @@
+package api
+
+import (
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"order-service-wbtech/internal/model"
+)
+
+// handleListOrders searches orders, newest first. Filters: customer_id,
+// track_number, delivery_service, locale, date_from, date_to (RFC 3339),
+// payment_provider, currency, brand; paging: limit and cursor (next_cursor of
+// the previous page).
+func (s *Server) handleListOrders(w http.ResponseWriter, r *http.Request) {
+	filter, err := orderFilter(r.URL.Query())
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, codeBadRequest, err.Error())
+		return
+	}
+
+	log.Printf("HTTP GET /orders?%s", r.URL.RawQuery)
+
+	page, err := s.service.ListOrders(r.Context(), filter)
+	if err != nil {
+		writeServiceError(w, r, "ListOrders", "order", err)
+		return
+	}
+	writeJSON(w, http.StatusOK, page)
+}
+
+// badParam — a query parameter that cannot be parsed
+type badParam string
+
+func (p badParam) Error() string { return "invalid " + string(p) }
+
+func orderFilter(q url.Values) (model.OrderFilter, error) {
+	filter := model.OrderFilter{
+		CustomerID:      q.Get("customer_id"),
+		TrackNumber:     q.Get("track_number"),
+		DeliveryService: q.Get("delivery_service"),
+		Locale:          q.Get("locale"),
+		PaymentProvider: q.Get("payment_provider"),
+		Currency:        q.Get("currency"),
+		Brand:           q.Get("brand"),
+	}
+
+	// dates are compared in UTC, like date_created is stored: the timestamp
+	// codec would drop the offset of the caller
+	var err error
+	if v := q.Get("date_from"); v != "" {
+		if filter.CreatedFrom, err = time.Parse(time.RFC3339, v); err != nil {
+			return filter, badParam("date_from")
+		}
+		filter.CreatedFrom = filter.CreatedFrom.UTC()
+	}
+	if v := q.Get("date_to"); v != "" {
+		if filter.CreatedTo, err = time.Parse(time.RFC3339, v); err != nil {
+			return filter, badParam("date_to")
+		}
+		filter.CreatedTo = filter.CreatedTo.UTC()
+	}
+	if v := q.Get("limit"); v != "" {
+		if filter.Limit, err = strconv.Atoi(v); err != nil || filter.Limit <= 0 || filter.Limit > model.MaxOrderLimit {
+			return filter, badParam("limit")
+		}
+	}
+	if v := q.Get("cursor"); v != "" {
+		cursor, err := model.ParseOrderCursor(v)
+		if err != nil {
+			return filter, badParam("cursor")
+		}
+		filter.After = &cursor
+	}
+	return filter, nil
+}
@@ -0,0 +1,70 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"order-service-wbtech/internal/model"
+)
+
+func TestHandleListOrders(t *testing.T) {
+	svc := &fakeService{orders: map[string]*model.Order{"a": validOrder("a")}}
+	srv := New(svc)
+	cursor := model.OrderCursor{DateCreated: time.Date(2025, 1, 15, 10, 0, 0, 0, time.UTC), OrderUID: "b563"}
+
+	rec := httptest.NewRecorder()
+	srv.Router().ServeHTTP(rec, httptest.NewRequest(http.MethodGet,
+		"/orders?customer_id=test&track_number=WBILM&delivery_service=meest&locale=en"+
+			"&date_from=2025-01-01T00:00:00Z&date_to=2025-02-01T00:00:00Z"+
+			"&payment_provider=wbpay&currency=USD&brand=Vivienne+Sabo&limit=10&cursor="+cursor.String(), nil))
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	var page model.OrderPage
+	require.NoError(t, json.NewDecoder(rec.Body).Decode(&page))
+	assert.Len(t, page.Orders, 1)
+
+	assert.Equal(t, model.OrderFilter{
+		CustomerID:      "test",
+		TrackNumber:     "WBILM",
+		DeliveryService: "meest",
+		Locale:          "en",
+		CreatedFrom:     time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC),
+		CreatedTo:       time.Date(2025, 2, 1, 0, 0, 0, 0, time.UTC),
+		PaymentProvider: "wbpay",
+		Currency:        "USD",
+		Brand:           "Vivienne Sabo",
+		Limit:           10,
+		After:           &cursor,
+	}, svc.filter)
+}
+
+func TestHandleListOrders_DatesInUTC(t *testing.T) {
+	svc := &fakeService{}
+	srv := New(svc)
+
+	rec := httptest.NewRecorder()
+	srv.Router().ServeHTTP(rec, httptest.NewRequest(http.MethodGet,
+		"/orders?date_from=2024-01-01T00:00:00%2B03:00&date_to=2024-01-01T12:00:00-05:00", nil))
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	assert.Equal(t, time.Date(2023, 12, 31, 21, 0, 0, 0, time.UTC), svc.filter.CreatedFrom)
+	assert.Equal(t, time.UTC, svc.filter.CreatedFrom.Location())
+	assert.Equal(t, time.Date(2024, 1, 1, 17, 0, 0, 0, time.UTC), svc.filter.CreatedTo)
+	assert.Equal(t, time.UTC, svc.filter.CreatedTo.Location())
+}
+
+func TestHandleListOrders_BadParams(t *testing.T) {
+	srv := New(&fakeService{})
+
+	for _, query := range []string{"date_from=yesterday", "date_to=2025-01-01", "limit=0", "limit=101", "cursor=garbage"} {
+		rec := httptest.NewRecorder()
+		srv.Router().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/orders?"+query, nil))
+		assert.Equal(t, http.StatusBadRequest, rec.Code, query)
+	}
+}
@@ -2,29 +2,61 @@ package cache
 
 import (
 	"errors"
+	"sort"
 	"sync"
+	"time"
 
 	"github.com/hashicorp/golang-lru"
 
 	"order-service-wbtech/internal/model"
 )
 
+// OrderCache — LRU cache of orders with a secondary index by track number
 type OrderCache struct {
 	lru *lru.Cache
 	mu  sync.RWMutex
+
+	// tracks maps order-level and item-level track numbers to the uids of the
+	// cached orders carrying them. Guarded by mu.
+	tracks map[string]*trackEntry
+	// trackTTL bounds how long a track lookup is answered from the cache
+	trackTTL time.Duration
+	now      func() time.Time
+}
+
+// trackEntry — cached orders of a track number. A database lookup makes it
+// complete until completeUntil; only then GetByTrack answers. Orders saved
+// through this cache join the entry, but those written by other processes
+// (another instance, cmd/replay) do not, so the entry expires.
+type trackEntry struct {
+	uids          map[string]struct{}
+	completeUntil time.Time
 }
 
-const defaultCacheSize = 1000
+const (
+	defaultCacheSize = 1000
+	// defaultTrackTTL — how long another process's order of a cached track can go unseen
+	defaultTrackTTL = 30 * time.Second
+)
 
 // New creates a new LRU cache with a default size
 func New() (*OrderCache, error) {
-	l, err := lru.New(defaultCacheSize)
+	return newWithSize(defaultCacheSize)
+}
+
+func newWithSize(size int) (*OrderCache, error) {
+	c := &OrderCache{tracks: make(map[string]*trackEntry), trackTTL: defaultTrackTTL, now: time.Now}
+	// evictions happen inside lru.Add, while Set holds mu
+	l, err := lru.NewWithEvict(size, func(_, value interface{}) {
+		if order, ok := value.(*model.Order); ok {
+			c.unindex(order)
+		}
+	})
 	if err != nil {
 		return nil, errors.New("failed to create LRU cache")
 	}
-	return &OrderCache{
-		lru: l,
-	}, nil
+	c.lru = l
+	return c, nil
 }
 
 func (c *OrderCache) Get(orderUID string) (*model.Order, bool) {
@@ -48,5 +80,127 @@ func (c *OrderCache) Set(order *model.Order) {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
+	c.set(order)
+}
+
+// SetTrack caches the result of a database lookup: all orders of trackNumber.
+// Until one of them is evicted or the track TTL passes, GetByTrack answers from
+// the cache.
+func (c *OrderCache) SetTrack(trackNumber string, orders []*model.Order) {
+	if trackNumber == "" || len(orders) == 0 {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, order := range orders {
+		if order != nil && order.OrderUID != "" {
+			c.set(order)
+		}
+	}
+	// with a cache smaller than the lookup, the first orders are evicted by the last
+	if entry, ok := c.tracks[trackNumber]; ok && len(entry.uids) >= len(orders) {
+		entry.completeUntil = c.now().Add(c.trackTTL)
+	}
+}
+
+// GetByTrack returns the orders whose order or items carry trackNumber, newest
+// first, if the cache knows all of them
+func (c *OrderCache) GetByTrack(trackNumber string) ([]*model.Order, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	entry, ok := c.tracks[trackNumber]
+	if !ok || !c.now().Before(entry.completeUntil) {
+		return nil, false
+	}
+
+	orders := make([]*model.Order, 0, len(entry.uids))
+	for uid := range entry.uids {
+		value, ok := c.lru.Get(uid)
+		if !ok {
+			return nil, false
+		}
+		orders = append(orders, value.(*model.Order))
+	}
+	sort.Slice(orders, func(i, j int) bool {
+		if !orders[i].DateCreated.Equal(orders[j].DateCreated) {
+			return orders[i].DateCreated.After(orders[j].DateCreated)
+		}
+		return orders[i].OrderUID > orders[j].OrderUID
+	})
+	return orders, true
+}
+
+// set adds or replaces a cached order and its index entries; mu must be held
+func (c *OrderCache) set(order *model.Order) {
+	tracks := trackNumbers(order)
+	if old, ok := c.lru.Peek(order.OrderUID); ok {
+		// the order has left the tracks it no longer carries, those stay complete
+		for _, track := range trackNumbers(old.(*model.Order)) {
+			if !contains(tracks, track) {
+				c.removeFromTrack(track, order.OrderUID)
+			}
+		}
+	}
 	c.lru.Add(order.OrderUID, order)
+
+	// a new order of a complete track keeps it complete, as far as this process
+	// writes; the TTL covers orders of other writers
+	for _, track := range tracks {
+		entry, ok := c.tracks[track]
+		if !ok {
+			entry = &trackEntry{uids: make(map[string]struct{})}
+			c.tracks[track] = entry
+		}
+		entry.uids[order.OrderUID] = struct{}{}
+	}
+}
+
+// unindex drops an evicted order from the index; mu must be held. Its tracks
+// are no longer complete: the order is still in the database.
+func (c *OrderCache) unindex(order *model.Order) {
+	for _, track := range trackNumbers(order) {
+		if entry, ok := c.tracks[track]; ok {
+			entry.completeUntil = time.Time{}
+			c.removeFromTrack(track, order.OrderUID)
+		}
+	}
+}
+
+func (c *OrderCache) removeFromTrack(track, orderUID string) {
+	entry, ok := c.tracks[track]
+	if !ok {
+		return
+	}
+	delete(entry.uids, orderUID)
+	if len(entry.uids) == 0 {
+		delete(c.tracks, track)
+	}
+}
+
+// trackNumbers returns the distinct non-empty track numbers of an order and its items
+func trackNumbers(order *model.Order) []string {
+	tracks := make([]string, 0, 1)
+	add := func(track string) {
+		if track != "" && !contains(tracks, track) {
+			tracks = append(tracks, track)
+		}
+	}
+
+	add(order.TrackNumber)
+	for _, item := range order.Items {
+		add(item.TrackNumber)
+	}
+	return tracks
+}
+
+func contains(tracks []string, track string) bool {
+	for _, t := range tracks {
+		if t == track {
+			return true
+		}
+	}
+	return false
 }
@@ -2,6 +2,7 @@ package cache
 
 import (
 	"testing"
+	"time"
 
 	"order-service-wbtech/internal/model"
 )
@@ -28,3 +29,94 @@ func TestCacheSetGet(t *testing.T) {
 		t.Error("expected order '456' to be missing")
 	}
 }
+
+func TestCacheGetByTrack(t *testing.T) {
+	c, err := New()
+	if err != nil {
+		t.Fatalf("failed to create cache: %v", err)
+	}
+
+	older := &model.Order{OrderUID: "1", TrackNumber: "T1", DateCreated: time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)}
+	newer := &model.Order{OrderUID: "2", TrackNumber: "T2", DateCreated: time.Date(2025, 1, 2, 0, 0, 0, 0, time.UTC),
+		Items: []model.Item{{TrackNumber: "T1"}}}
+
+	// orders cached one by one do not prove the track has no others
+	c.Set(older)
+	c.Set(newer)
+	if _, ok := c.GetByTrack("T1"); ok {
+		t.Fatal("expected track T1 to be unknown before a lookup")
+	}
+
+	c.SetTrack("T1", []*model.Order{newer, older})
+	got, ok := c.GetByTrack("T1")
+	if !ok {
+		t.Fatal("expected track T1 to be cached")
+	}
+	if len(got) != 2 || got[0].OrderUID != "2" || got[1].OrderUID != "1" {
+		t.Errorf("expected orders 2, 1 by item and order track, got %v", got)
+	}
+
+	// a new order of the track joins it
+	c.Set(&model.Order{OrderUID: "3", TrackNumber: "T1", DateCreated: time.Date(2025, 1, 3, 0, 0, 0, 0, time.UTC)})
+	if got, _ := c.GetByTrack("T1"); len(got) != 3 {
+		t.Errorf("expected 3 orders of track T1, got %d", len(got))
+	}
+
+	// an order that moved to another track leaves it
+	c.Set(&model.Order{OrderUID: "3", TrackNumber: "T3"})
+	if got, _ := c.GetByTrack("T1"); len(got) != 2 {
+		t.Errorf("expected 2 orders of track T1, got %d", len(got))
+	}
+}
+
+func TestCacheGetByTrack_Eviction(t *testing.T) {
+	c, err := newWithSize(2)
+	if err != nil {
+		t.Fatalf("failed to create cache: %v", err)
+	}
+
+	c.SetTrack("T1", []*model.Order{{OrderUID: "1", TrackNumber: "T1"}, {OrderUID: "2", TrackNumber: "T1"}})
+	if _, ok := c.GetByTrack("T1"); !ok {
+		t.Fatal("expected track T1 to be cached")
+	}
+
+	// evicting one order of the track makes the cache answer incomplete
+	c.Set(&model.Order{OrderUID: "3", TrackNumber: "T2"})
+	if _, ok := c.GetByTrack("T1"); ok {
+		t.Error("expected track T1 to miss after an eviction")
+	}
+
+	// a lookup larger than the cache is not cached
+	c.SetTrack("T4", []*model.Order{
+		{OrderUID: "4", TrackNumber: "T4"}, {OrderUID: "5", TrackNumber: "T4"}, {OrderUID: "6", TrackNumber: "T4"},
+	})
+	if _, ok := c.GetByTrack("T4"); ok {
+		t.Error("expected track T4 to miss")
+	}
+	if len(c.tracks) > 2 {
+		t.Errorf("expected index entries of evicted orders to be dropped, got %d", len(c.tracks))
+	}
+}
+
+func TestCacheGetByTrack_Expires(t *testing.T) {
+	c, err := New()
+	if err != nil {
+		t.Fatalf("failed to create cache: %v", err)
+	}
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	c.now = func() time.Time { return now }
+
+	c.SetTrack("T1", []*model.Order{{OrderUID: "1", TrackNumber: "T1"}})
+	if _, ok := c.GetByTrack("T1"); !ok {
+		t.Fatal("expected track T1 to be cached")
+	}
+
+	// another process may have stored an order of the track meanwhile
+	now = now.Add(defaultTrackTTL)
+	if _, ok := c.GetByTrack("T1"); ok {
+		t.Error("expected track T1 to miss after the TTL")
+	}
+	if _, ok := c.Get("1"); !ok {
+		t.Error("expected the order itself to stay cached")
+	}
+}
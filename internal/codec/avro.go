@@ -0,0 +1,53 @@
+package codec
+
+import (
+	"sync"
+
+	"github.com/hamba/avro/v2"
+
+	"order-service-wbtech/internal/model"
+)
+
+// avroAPI maps Avro fields to model fields by their json tags
+var avroAPI = avro.Config{TagKey: "json"}.Freeze()
+
+// avroCodec — Avro binary encoding, parsed schemas are cached by ID
+type avroCodec struct {
+	mu      sync.Mutex
+	schemas map[int]avro.Schema
+}
+
+func newAvroCodec() *avroCodec {
+	return &avroCodec{schemas: make(map[int]avro.Schema)}
+}
+
+func (c *avroCodec) schema(s *Schema) (avro.Schema, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if parsed, ok := c.schemas[s.ID]; ok {
+		return parsed, nil
+	}
+	parsed, err := avro.Parse(s.Definition)
+	if err != nil {
+		return nil, err
+	}
+	c.schemas[s.ID] = parsed
+	return parsed, nil
+}
+
+func (c *avroCodec) Marshal(s *Schema, order *model.Order) ([]byte, error) {
+	schema, err := c.schema(s)
+	if err != nil {
+		return nil, err
+	}
+	return avroAPI.Marshal(schema, order)
+}
+
+func (c *avroCodec) Unmarshal(s *Schema, data []byte, order *model.Order) error {
+	schema, err := c.schema(s)
+	if err != nil {
+		return err
+	}
+	return avroAPI.Unmarshal(schema, data, order)
+}
@@ -0,0 +1,178 @@
+package codec
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"order-service-wbtech/internal/config"
+	"order-service-wbtech/internal/model"
+)
+
+// Format — serialization format of an order payload
+type Format string
+
+const (
+	FormatJSON     Format = "json"
+	FormatAvro     Format = "avro"
+	FormatProtobuf Format = "protobuf"
+)
+
+// ParseFormat parses a format name; empty means JSON
+func ParseFormat(s string) (Format, error) {
+	switch f := Format(s); f {
+	case "":
+		return FormatJSON, nil
+	case FormatJSON, FormatAvro, FormatProtobuf:
+		return f, nil
+	default:
+		return "", fmt.Errorf("unknown message format %q (expected json, avro or protobuf)", s)
+	}
+}
+
+// OrderCodec converts an order to and from one format. schema is nil for JSON.
+type OrderCodec interface {
+	Marshal(schema *Schema, order *model.Order) ([]byte, error)
+	Unmarshal(schema *Schema, data []byte, order *model.Order) error
+}
+
+func defaultCodecs() map[Format]OrderCodec {
+	return map[Format]OrderCodec{
+		FormatJSON:     jsonCodec{},
+		FormatAvro:     newAvroCodec(),
+		FormatProtobuf: newProtobufCodec(),
+	}
+}
+
+// Decoder — decodes order payloads of any supported format. Payloads in the
+// Confluent wire format are decoded with the registered schema they reference,
+// everything else is decoded as the requested or default format.
+type Decoder struct {
+	registry      SchemaRegistry
+	defaultFormat Format
+	codecs        map[Format]OrderCodec
+}
+
+// NewDecoder returns a decoder; registry may be nil when only JSON is consumed
+func NewDecoder(registry SchemaRegistry, defaultFormat Format) *Decoder {
+	if defaultFormat == "" {
+		defaultFormat = FormatJSON
+	}
+	return &Decoder{
+		registry:      registry,
+		defaultFormat: defaultFormat,
+		codecs:        defaultCodecs(),
+	}
+}
+
+// DecoderFromConfig builds the order decoder from the configured default format
+// and schema registry
+func DecoderFromConfig(k config.Kafka) (*Decoder, error) {
+	format, err := ParseFormat(k.MessageFormat)
+	if err != nil {
+		return nil, fmt.Errorf("KAFKA_MESSAGE_FORMAT: %w", err)
+	}
+	registry, err := RegistryFromConfig(k)
+	if err != nil {
+		return nil, err
+	}
+	if registry == nil && format != FormatJSON {
+		return nil, fmt.Errorf("KAFKA_MESSAGE_FORMAT=%s requires SCHEMA_REGISTRY_URL or SCHEMA_REGISTRY_FILE", format)
+	}
+	return NewDecoder(registry, format), nil
+}
+
+// Decode decodes data. format comes from the message (e.g. a header) and may be
+// empty; for wire-format payloads it must match the format of the schema.
+func (d *Decoder) Decode(ctx context.Context, format Format, data []byte) (*model.Order, error) {
+	var order model.Order
+
+	if !IsWireFormat(data) {
+		if format == "" {
+			format = d.defaultFormat
+		}
+		if format != FormatJSON {
+			return nil, fmt.Errorf("%s payload: %w", format, ErrNotWireFormat)
+		}
+		if err := json.Unmarshal(data, &order); err != nil {
+			return nil, err
+		}
+		return &order, nil
+	}
+
+	if d.registry == nil {
+		return nil, fmt.Errorf("wire-format payload but no schema registry is configured")
+	}
+
+	schemaID, payload, err := DecodeWire(data)
+	if err != nil {
+		return nil, err
+	}
+	schema, err := d.registry.SchemaByID(ctx, schemaID)
+	if err != nil {
+		return nil, err
+	}
+	if format != "" && format != schema.Format {
+		return nil, fmt.Errorf("message declared as %s but schema %d is %s", format, schemaID, schema.Format)
+	}
+
+	codec, ok := d.codecs[schema.Format]
+	if !ok {
+		return nil, fmt.Errorf("no decoder for %s", schema.Format)
+	}
+	if err := codec.Unmarshal(schema, payload, &order); err != nil {
+		return nil, fmt.Errorf("decode %s (schema %d): %w", schema.Format, schemaID, err)
+	}
+	return &order, nil
+}
+
+// Encoder — encodes orders for producers. Avro and Protobuf payloads are framed
+// with the ID of the latest schema of the subject.
+type Encoder struct {
+	registry SchemaRegistry
+	codecs   map[Format]OrderCodec
+}
+
+func NewEncoder(registry SchemaRegistry) *Encoder {
+	return &Encoder{
+		registry: registry,
+		codecs:   defaultCodecs(),
+	}
+}
+
+func (e *Encoder) Encode(ctx context.Context, format Format, subject string, order *model.Order) ([]byte, error) {
+	if format == FormatJSON || format == "" {
+		return json.Marshal(order)
+	}
+
+	if e.registry == nil {
+		return nil, fmt.Errorf("%s needs a schema registry", format)
+	}
+	schema, err := e.registry.LatestSchema(ctx, subject)
+	if err != nil {
+		return nil, err
+	}
+	if schema.Format != format {
+		return nil, fmt.Errorf("subject %s is %s, not %s", subject, schema.Format, format)
+	}
+
+	codec, ok := e.codecs[format]
+	if !ok {
+		return nil, fmt.Errorf("no encoder for %s", format)
+	}
+	payload, err := codec.Marshal(schema, order)
+	if err != nil {
+		return nil, fmt.Errorf("encode %s: %w", format, err)
+	}
+	return EncodeWire(schema.ID, payload), nil
+}
+
+type jsonCodec struct{}
+
+func (jsonCodec) Marshal(_ *Schema, order *model.Order) ([]byte, error) {
+	return json.Marshal(order)
+}
+
+func (jsonCodec) Unmarshal(_ *Schema, data []byte, order *model.Order) error {
+	return json.Unmarshal(data, order)
+}
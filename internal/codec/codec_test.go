@@ -0,0 +1,278 @@
+package codec
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"order-service-wbtech/internal/config"
+	"order-service-wbtech/internal/model"
+)
+
+func testOrder() *model.Order {
+	return &model.Order{
+		OrderUID:    "b563feb7b2b84b6test",
+		TrackNumber: "WBILMTESTTRACK",
+		Entry:       "WBIL",
+		Delivery: model.Delivery{
+			Name: "Test Testov", Phone: "+9720000000", Zip: "2639809", City: "Kiryat Mozkin",
+			Address: "Ploshad Mira 15", Region: "Kraiot", Email: "test@gmail.com",
+		},
+		Payment: model.Payment{
+			Transaction: "b563feb7b2b84b6test", Currency: "USD", Provider: "wbpay", Amount: 1817,
+			PaymentDt: 1637907727, Bank: "alpha", DeliveryCost: 1500, GoodsTotal: 317,
+		},
+		Items: []model.Item{{
+			ChrtID: 9934930, TrackNumber: "WBILMTESTTRACK", Price: 453, Rid: "ab4219087a764ae0btest",
+			Name: "Mascaras", Sale: 30, Size: "0", TotalPrice: 317, NmID: 2389212, Brand: "Vivienne Sabo", Status: 202,
+		}},
+		Locale:          "en",
+		CustomerID:      "test",
+		DeliveryService: "meest",
+		ShardKey:        "9",
+		SMID:            99,
+		DateCreated:     time.Date(2021, 11, 26, 6, 22, 19, 0, time.UTC),
+		OOFShard:        "1",
+	}
+}
+
+func TestEncodeDecode_AllFormats(t *testing.T) {
+	registry, err := NewFileRegistry("../../schemas/registry.json")
+	require.NoError(t, err)
+
+	enc := NewEncoder(registry)
+	dec := NewDecoder(registry, FormatJSON)
+
+	tests := []struct {
+		format  Format
+		subject string
+	}{
+		{FormatJSON, ""},
+		{FormatAvro, "orders-value-avro"},
+		{FormatProtobuf, "orders-value-protobuf"},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.format), func(t *testing.T) {
+			data, err := enc.Encode(context.Background(), tt.format, tt.subject, testOrder())
+			require.NoError(t, err)
+			assert.Equal(t, tt.format != FormatJSON, IsWireFormat(data))
+
+			// without a header the wire format alone selects the decoder
+			got, err := dec.Decode(context.Background(), "", data)
+			require.NoError(t, err)
+			assert.Equal(t, testOrder(), got)
+
+			got, err = dec.Decode(context.Background(), tt.format, data)
+			require.NoError(t, err)
+			assert.Equal(t, testOrder().OrderUID, got.OrderUID)
+		})
+	}
+}
+
+func TestDecode_Errors(t *testing.T) {
+	registry, err := NewFileRegistry("../../schemas/registry.json")
+	require.NoError(t, err)
+	dec := NewDecoder(registry, FormatJSON)
+
+	avroData, err := NewEncoder(registry).Encode(context.Background(), FormatAvro, "orders-value-avro", testOrder())
+	require.NoError(t, err)
+
+	_, err = dec.Decode(context.Background(), FormatProtobuf, avroData)
+	assert.Error(t, err, "declared format must match the schema")
+
+	_, err = dec.Decode(context.Background(), FormatAvro, []byte(`{"order_uid":"1"}`))
+	assert.ErrorIs(t, err, ErrNotWireFormat)
+
+	_, err = dec.Decode(context.Background(), "", EncodeWire(42, []byte{1, 2}))
+	assert.ErrorIs(t, err, ErrSchemaNotFound)
+
+	_, err = NewDecoder(nil, FormatJSON).Decode(context.Background(), "", avroData)
+	assert.Error(t, err)
+}
+
+// renumbered fields, a message before Order and a nested message
+const protoV2 = `syntax = "proto3";
+package wbtech.orders.v2;
+option go_package = "wbtech/orders/v2";
+
+// Envelope comes first, so Order has message index [1]
+message Envelope { string id = 1; }
+
+message Order {
+  message Payment {
+    string transaction = 3;
+    int32 amount = 1;
+    string currency = 2;
+  }
+  reserved 1 to 10;
+  string track_number = 21;
+  string order_uid = 20;
+  Payment payment = 22;
+  repeated Item items = 23;
+  sint64 sm_id = 24;
+  google.protobuf.Timestamp date_created = 25;
+  // fields the model does not have are left alone
+  map<string, string> tags = 26;
+  oneof source { string shop = 27; string partner = 28; }
+}
+
+message Item { string name = 7; int64 chrt_id = 8; }
+
+import "google/protobuf/timestamp.proto";
+`
+
+func TestProtobuf_RegisteredSchema(t *testing.T) {
+	registry, err := NewFileRegistry("../../schemas/registry.json")
+	require.NoError(t, err)
+	registry.Register(&Schema{ID: 10, Subject: "orders-value-v2", Format: FormatProtobuf, Definition: protoV2})
+
+	order := testOrder()
+	data, err := NewEncoder(registry).Encode(context.Background(), FormatProtobuf, "orders-value-v2", order)
+	require.NoError(t, err)
+	_, payload, err := DecodeWire(data)
+	require.NoError(t, err)
+	assert.Equal(t, []byte{2, 2}, payload[:2], "zigzag count 1, zigzag index 1")
+
+	got, err := NewDecoder(registry, FormatJSON).Decode(context.Background(), "", data)
+	require.NoError(t, err)
+	assert.Equal(t, order.OrderUID, got.OrderUID)
+	assert.Equal(t, order.TrackNumber, got.TrackNumber)
+	assert.Equal(t, order.SMID, got.SMID)
+	assert.Equal(t, order.DateCreated, got.DateCreated)
+	assert.Equal(t, model.Payment{Transaction: order.Payment.Transaction, Currency: "USD", Amount: 1817}, got.Payment)
+	assert.Equal(t, []model.Item{{Name: "Mascaras", ChrtID: 9934930}}, got.Items)
+	assert.Empty(t, got.Entry, "not in the schema")
+}
+
+func TestProtobuf_SchemaMismatch(t *testing.T) {
+	registry, err := NewFileRegistry("../../schemas/registry.json")
+	require.NoError(t, err)
+	v1, err := registry.SchemaByID(context.Background(), 2)
+	require.NoError(t, err)
+	// sm_id turned into a string: the payload must not decode into SMID
+	registry.Register(&Schema{
+		ID: 11, Subject: "orders-value-str", Format: FormatProtobuf,
+		Definition: strings.Replace(v1.Definition, "int64 sm_id", "string sm_id", 1),
+	})
+	registry.Register(&Schema{ID: 12, Subject: "orders-value-broken", Format: FormatProtobuf,
+		Definition: `syntax = "proto3"; message Order { Missing delivery = 1; }`})
+
+	data, err := NewEncoder(registry).Encode(context.Background(), FormatProtobuf, "orders-value-protobuf", testOrder())
+	require.NoError(t, err)
+	_, payload, err := DecodeWire(data)
+	require.NoError(t, err)
+	dec := NewDecoder(registry, FormatJSON)
+
+	_, err = dec.Decode(context.Background(), "", EncodeWire(11, payload))
+	assert.ErrorContains(t, err, "sm_id")
+
+	_, err = dec.Decode(context.Background(), "", EncodeWire(12, payload))
+	assert.ErrorContains(t, err, "compile schema-12.proto")
+
+	// message index [7] is not in the file
+	_, err = dec.Decode(context.Background(), "", EncodeWire(2, append([]byte{2, 14}, payload[1:]...)))
+	assert.ErrorContains(t, err, "message index")
+}
+
+func TestDecoderFromConfig(t *testing.T) {
+	dec, err := DecoderFromConfig(config.Kafka{})
+	require.NoError(t, err)
+	assert.Equal(t, FormatJSON, dec.defaultFormat)
+
+	dec, err = DecoderFromConfig(config.Kafka{MessageFormat: "avro", SchemaRegistryFile: "../../schemas/registry.json"})
+	require.NoError(t, err)
+	assert.Equal(t, FormatAvro, dec.defaultFormat)
+	assert.NotNil(t, dec.registry)
+
+	_, err = DecoderFromConfig(config.Kafka{MessageFormat: "avro"})
+	assert.ErrorContains(t, err, "SCHEMA_REGISTRY_FILE")
+
+	_, err = DecoderFromConfig(config.Kafka{MessageFormat: "xml"})
+	assert.Error(t, err)
+
+	registry, err := RegistryFromConfig(config.Kafka{})
+	require.NoError(t, err)
+	assert.Nil(t, registry)
+}
+
+func TestHTTPRegistry(t *testing.T) {
+	files, err := NewFileRegistry("../../schemas/registry.json")
+	require.NoError(t, err)
+	avro, err := files.LatestSchema(context.Background(), "orders-value-avro")
+	require.NoError(t, err)
+	proto, err := files.LatestSchema(context.Background(), "orders-value-protobuf")
+	require.NoError(t, err)
+
+	var requests atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		if user, pass, _ := r.BasicAuth(); user != "key" || pass != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		switch r.URL.Path {
+		case "/schemas/ids/1":
+			_ = json.NewEncoder(w).Encode(map[string]any{"schema": avro.Definition})
+		case "/subjects/orders-value-protobuf/versions/latest":
+			_ = json.NewEncoder(w).Encode(map[string]any{
+				"subject": "orders-value-protobuf", "id": 2, "version": 1,
+				"schema": proto.Definition, "schemaType": "PROTOBUF",
+			})
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			_ = json.NewEncoder(w).Encode(map[string]any{"error_code": 40403, "message": "Schema not found"})
+		}
+	}))
+	defer srv.Close()
+
+	registry := NewHTTPRegistry(srv.URL+"/", "key", "secret")
+	enc, dec := NewEncoder(registry), NewDecoder(registry, FormatJSON)
+
+	// the schema by ID from the wire header, Avro without schemaType
+	data, err := NewEncoder(files).Encode(context.Background(), FormatAvro, "orders-value-avro", testOrder())
+	require.NoError(t, err)
+	got, err := dec.Decode(context.Background(), "", data)
+	require.NoError(t, err)
+	assert.Equal(t, testOrder(), got)
+	_, err = dec.Decode(context.Background(), "", data)
+	require.NoError(t, err)
+	assert.Equal(t, int32(1), requests.Load(), "schemas by ID are cached")
+
+	data, err = enc.Encode(context.Background(), FormatProtobuf, "orders-value-protobuf", testOrder())
+	require.NoError(t, err)
+	got, err = dec.Decode(context.Background(), FormatProtobuf, data)
+	require.NoError(t, err)
+	assert.Equal(t, testOrder(), got)
+	assert.Equal(t, int32(2), requests.Load(), "the latest schema fills the ID cache")
+
+	_, err = registry.SchemaByID(context.Background(), 42)
+	assert.ErrorIs(t, err, ErrSchemaNotFound)
+
+	_, err = NewHTTPRegistry(srv.URL, "key", "wrong").SchemaByID(context.Background(), 1)
+	assert.ErrorContains(t, err, "401")
+
+	fromConfig, err := RegistryFromConfig(config.Kafka{SchemaRegistryURL: srv.URL, SchemaRegistryFile: "ignored.json"})
+	require.NoError(t, err)
+	assert.IsType(t, &HTTPRegistry{}, fromConfig)
+}
+
+func TestWire(t *testing.T) {
+	data := EncodeWire(258, []byte("payload"))
+	assert.Equal(t, []byte{0, 0, 0, 1, 2}, data[:5])
+
+	id, payload, err := DecodeWire(data)
+	require.NoError(t, err)
+	assert.Equal(t, 258, id)
+	assert.Equal(t, []byte("payload"), payload)
+
+	_, _, err = DecodeWire([]byte("{}"))
+	assert.ErrorIs(t, err, ErrNotWireFormat)
+}
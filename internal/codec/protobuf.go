@@ -0,0 +1,498 @@
+package codec
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/dynamicpb"
+
+	"order-service-wbtech/internal/model"
+)
+
+// orderMessage — the message producers encode orders as
+const orderMessage = "Order"
+
+// protobufCodec — Protobuf encoding driven by the registered .proto schema:
+// fields are matched to the model by name, so their numbers come from the
+// schema the payload references. Compiled schemas are cached by ID.
+type protobufCodec struct {
+	mu    sync.Mutex
+	files map[int]protoreflect.FileDescriptor
+}
+
+func newProtobufCodec() *protobufCodec {
+	return &protobufCodec{files: make(map[int]protoreflect.FileDescriptor)}
+}
+
+func (c *protobufCodec) file(s *Schema) (protoreflect.FileDescriptor, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if file, ok := c.files[s.ID]; ok {
+		return file, nil
+	}
+	file, err := compileProto(fmt.Sprintf("schema-%d.proto", s.ID), s.Definition)
+	if err != nil {
+		return nil, err
+	}
+	c.files[s.ID] = file
+	return file, nil
+}
+
+func (c *protobufCodec) Marshal(s *Schema, order *model.Order) ([]byte, error) {
+	file, err := c.file(s)
+	if err != nil {
+		return nil, err
+	}
+	md := file.Messages().ByName(orderMessage)
+	if md == nil {
+		return nil, fmt.Errorf("schema %d has no %s message", s.ID, orderMessage)
+	}
+
+	msg := dynamicpb.NewMessage(md)
+	if err := writeOrder(protoFields{m: msg}, order); err != nil {
+		return nil, err
+	}
+	payload, err := proto.Marshal(msg)
+	if err != nil {
+		return nil, err
+	}
+	return append(appendMessageIndexes(nil, []int{md.Index()}), payload...), nil
+}
+
+func (c *protobufCodec) Unmarshal(s *Schema, data []byte, order *model.Order) error {
+	file, err := c.file(s)
+	if err != nil {
+		return err
+	}
+	indexes, data, err := consumeMessageIndexes(data)
+	if err != nil {
+		return err
+	}
+	md, err := messageByIndexes(file, indexes)
+	if err != nil {
+		return err
+	}
+
+	msg := dynamicpb.NewMessage(md)
+	if err := proto.Unmarshal(data, msg); err != nil {
+		return err
+	}
+	return readOrder(protoFields{m: msg}, order)
+}
+
+// appendMessageIndexes writes the Confluent message-index path: a zigzag
+// count followed by zigzag indexes, with [0] shortened to a single zero
+func appendMessageIndexes(b []byte, indexes []int) []byte {
+	if len(indexes) == 1 && indexes[0] == 0 {
+		return append(b, 0)
+	}
+	b = protowire.AppendVarint(b, protowire.EncodeZigZag(int64(len(indexes))))
+	for _, idx := range indexes {
+		b = protowire.AppendVarint(b, protowire.EncodeZigZag(int64(idx)))
+	}
+	return b
+}
+
+// consumeMessageIndexes reads the message-index path; an empty path means [0]
+func consumeMessageIndexes(b []byte) ([]int, []byte, error) {
+	v, n := protowire.ConsumeVarint(b)
+	if n < 0 {
+		return nil, nil, protowire.ParseError(n)
+	}
+	b = b[n:]
+	count := protowire.DecodeZigZag(v)
+	if count == 0 {
+		return []int{0}, b, nil
+	}
+	if count < 0 || count > int64(len(b)) {
+		return nil, nil, fmt.Errorf("invalid message index count %d", count)
+	}
+
+	indexes := make([]int, count)
+	for i := range indexes {
+		v, n := protowire.ConsumeVarint(b)
+		if n < 0 {
+			return nil, nil, protowire.ParseError(n)
+		}
+		indexes[i] = int(protowire.DecodeZigZag(v))
+		b = b[n:]
+	}
+	return indexes, b, nil
+}
+
+// messageByIndexes resolves a message-index path: the first index selects a
+// top-level message of the file, the next ones its nested messages
+func messageByIndexes(file protoreflect.FileDescriptor, indexes []int) (protoreflect.MessageDescriptor, error) {
+	messages := file.Messages()
+	var md protoreflect.MessageDescriptor
+	for _, idx := range indexes {
+		if idx < 0 || idx >= messages.Len() {
+			return nil, fmt.Errorf("message index %v is not in the schema", indexes)
+		}
+		md = messages.Get(idx)
+		messages = md.Messages()
+	}
+	return md, nil
+}
+
+// protoFields reads and writes fields of a dynamic message by name. A field
+// the schema does not declare reads as zero and is not written; a field whose
+// type does not match the model is an error, never a silently wrong value.
+type protoFields struct {
+	m protoreflect.Message
+}
+
+func (p protoFields) field(name string, kinds ...protoreflect.Kind) (protoreflect.FieldDescriptor, error) {
+	fd := p.m.Descriptor().Fields().ByName(protoreflect.Name(name))
+	if fd == nil {
+		return nil, nil
+	}
+	for _, k := range kinds {
+		if fd.Kind() == k {
+			return fd, nil
+		}
+	}
+	return nil, fmt.Errorf("%s.%s: unexpected type %s", p.m.Descriptor().Name(), name, fd.Kind())
+}
+
+func (p protoFields) scalar(name string, kinds ...protoreflect.Kind) (protoreflect.FieldDescriptor, error) {
+	fd, err := p.field(name, kinds...)
+	if err == nil && fd != nil && fd.IsList() {
+		return nil, fmt.Errorf("%s.%s: unexpected repeated field", p.m.Descriptor().Name(), name)
+	}
+	return fd, err
+}
+
+var intKinds = []protoreflect.Kind{
+	protoreflect.Int32Kind, protoreflect.Int64Kind,
+	protoreflect.Sint32Kind, protoreflect.Sint64Kind,
+	protoreflect.Sfixed32Kind, protoreflect.Sfixed64Kind,
+}
+
+func (p protoFields) str(name string, v *string) error {
+	fd, err := p.scalar(name, protoreflect.StringKind)
+	if fd != nil {
+		*v = p.m.Get(fd).String()
+	}
+	return err
+}
+
+func (p protoFields) int64(name string, v *int64) error {
+	fd, err := p.scalar(name, intKinds...)
+	if fd != nil {
+		*v = p.m.Get(fd).Int()
+	}
+	return err
+}
+
+func (p protoFields) int(name string, v *int) error {
+	var i int64
+	err := p.int64(name, &i)
+	*v = int(i)
+	return err
+}
+
+// message returns a set message field, or nil
+func (p protoFields) message(name string) (*protoFields, error) {
+	fd, err := p.scalar(name, protoreflect.MessageKind)
+	if fd == nil || !p.m.Has(fd) {
+		return nil, err
+	}
+	return &protoFields{m: p.m.Get(fd).Message()}, nil
+}
+
+func (p protoFields) list(name string) ([]protoFields, error) {
+	fd, err := p.field(name, protoreflect.MessageKind)
+	if fd == nil {
+		return nil, err
+	}
+	if !fd.IsList() {
+		return nil, fmt.Errorf("%s.%s: expected a repeated field", p.m.Descriptor().Name(), name)
+	}
+	list := p.m.Get(fd).List()
+	items := make([]protoFields, list.Len())
+	for i := range items {
+		items[i] = protoFields{m: list.Get(i).Message()}
+	}
+	return items, nil
+}
+
+func (p protoFields) timestamp(name string, v *time.Time) error {
+	ts, err := p.message(name)
+	if ts == nil {
+		return err
+	}
+	if ts.m.Descriptor().FullName() != "google.protobuf.Timestamp" {
+		return fmt.Errorf("%s.%s: expected google.protobuf.Timestamp", p.m.Descriptor().Name(), name)
+	}
+	var seconds, nanos int64
+	if err := firstError(ts.int64("seconds", &seconds), ts.int64("nanos", &nanos)); err != nil {
+		return err
+	}
+	*v = time.Unix(seconds, nanos).UTC()
+	return nil
+}
+
+// proto3 omits fields with default values
+func (p protoFields) setStr(name, v string) error {
+	fd, err := p.scalar(name, protoreflect.StringKind)
+	if fd != nil && v != "" {
+		p.m.Set(fd, protoreflect.ValueOfString(v))
+	}
+	return err
+}
+
+func (p protoFields) setInt(name string, v int64) error {
+	fd, err := p.scalar(name, intKinds...)
+	if fd == nil || v == 0 {
+		return err
+	}
+	switch fd.Kind() {
+	case protoreflect.Int32Kind, protoreflect.Sint32Kind, protoreflect.Sfixed32Kind:
+		p.m.Set(fd, protoreflect.ValueOfInt32(int32(v)))
+	default:
+		p.m.Set(fd, protoreflect.ValueOfInt64(v))
+	}
+	return nil
+}
+
+// setMessage returns the message field to fill in, or nil if the schema lacks it
+func (p protoFields) setMessage(name string) (*protoFields, error) {
+	fd, err := p.scalar(name, protoreflect.MessageKind)
+	if fd == nil {
+		return nil, err
+	}
+	return &protoFields{m: p.m.Mutable(fd).Message()}, nil
+}
+
+// appendTo adds n elements to a repeated message field
+func (p protoFields) appendTo(name string, n int) ([]protoFields, error) {
+	fd, err := p.field(name, protoreflect.MessageKind)
+	if fd == nil || n == 0 {
+		return nil, err
+	}
+	if !fd.IsList() {
+		return nil, fmt.Errorf("%s.%s: expected a repeated field", p.m.Descriptor().Name(), name)
+	}
+	list := p.m.Mutable(fd).List()
+	items := make([]protoFields, n)
+	for i := range items {
+		v := list.NewElement()
+		list.Append(v)
+		items[i] = protoFields{m: v.Message()}
+	}
+	return items, nil
+}
+
+func (p protoFields) setTimestamp(name string, t time.Time) error {
+	if t.IsZero() {
+		_, err := p.scalar(name, protoreflect.MessageKind)
+		return err
+	}
+	ts, err := p.setMessage(name)
+	if ts == nil {
+		return err
+	}
+	if ts.m.Descriptor().FullName() != "google.protobuf.Timestamp" {
+		return fmt.Errorf("%s.%s: expected google.protobuf.Timestamp", p.m.Descriptor().Name(), name)
+	}
+	return firstError(ts.setInt("seconds", t.Unix()), ts.setInt("nanos", int64(t.Nanosecond())))
+}
+
+func firstError(errs ...error) error {
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func readOrder(p protoFields, o *model.Order) error {
+	if fd, err := p.scalar("order_uid", protoreflect.StringKind); err != nil || fd == nil {
+		return fmt.Errorf("message %s is not an order: %w", p.m.Descriptor().FullName(), firstError(err, fmt.Errorf("no order_uid field")))
+	}
+
+	var status string
+	err := firstError(
+		p.str("order_uid", &o.OrderUID),
+		p.str("track_number", &o.TrackNumber),
+		p.str("entry", &o.Entry),
+		p.str("locale", &o.Locale),
+		p.str("internal_signature", &o.InternalSignature),
+		p.str("customer_id", &o.CustomerID),
+		p.str("delivery_service", &o.DeliveryService),
+		p.str("shardkey", &o.ShardKey),
+		p.int("sm_id", &o.SMID),
+		p.timestamp("date_created", &o.DateCreated),
+		p.str("oof_shard", &o.OOFShard),
+		p.str("status", &status),
+	)
+	if err != nil {
+		return err
+	}
+	o.Status = model.OrderStatus(status)
+
+	delivery, err := p.message("delivery")
+	if err != nil {
+		return err
+	}
+	if delivery != nil {
+		d := &o.Delivery
+		err := firstError(
+			delivery.str("name", &d.Name),
+			delivery.str("phone", &d.Phone),
+			delivery.str("zip", &d.Zip),
+			delivery.str("city", &d.City),
+			delivery.str("address", &d.Address),
+			delivery.str("region", &d.Region),
+			delivery.str("email", &d.Email),
+		)
+		if err != nil {
+			return err
+		}
+	}
+
+	payment, err := p.message("payment")
+	if err != nil {
+		return err
+	}
+	if payment != nil {
+		pm := &o.Payment
+		err := firstError(
+			payment.str("transaction", &pm.Transaction),
+			payment.str("request_id", &pm.RequestID),
+			payment.str("currency", &pm.Currency),
+			payment.str("provider", &pm.Provider),
+			payment.int("amount", &pm.Amount),
+			payment.int64("payment_dt", &pm.PaymentDt),
+			payment.str("bank", &pm.Bank),
+			payment.int("delivery_cost", &pm.DeliveryCost),
+			payment.int("goods_total", &pm.GoodsTotal),
+			payment.int("custom_fee", &pm.CustomFee),
+		)
+		if err != nil {
+			return err
+		}
+	}
+
+	items, err := p.list("items")
+	if err != nil {
+		return err
+	}
+	o.Items = nil
+	for _, item := range items {
+		var it model.Item
+		err := firstError(
+			item.int64("chrt_id", &it.ChrtID),
+			item.str("track_number", &it.TrackNumber),
+			item.int("price", &it.Price),
+			item.str("rid", &it.Rid),
+			item.str("name", &it.Name),
+			item.int("sale", &it.Sale),
+			item.str("size", &it.Size),
+			item.int("total_price", &it.TotalPrice),
+			item.int64("nm_id", &it.NmID),
+			item.str("brand", &it.Brand),
+			item.int("status", &it.Status),
+		)
+		if err != nil {
+			return err
+		}
+		o.Items = append(o.Items, it)
+	}
+	return nil
+}
+
+func writeOrder(p protoFields, o *model.Order) error {
+	err := firstError(
+		p.setStr("order_uid", o.OrderUID),
+		p.setStr("track_number", o.TrackNumber),
+		p.setStr("entry", o.Entry),
+		p.setStr("locale", o.Locale),
+		p.setStr("internal_signature", o.InternalSignature),
+		p.setStr("customer_id", o.CustomerID),
+		p.setStr("delivery_service", o.DeliveryService),
+		p.setStr("shardkey", o.ShardKey),
+		p.setInt("sm_id", int64(o.SMID)),
+		p.setTimestamp("date_created", o.DateCreated),
+		p.setStr("oof_shard", o.OOFShard),
+		p.setStr("status", string(o.Status)),
+	)
+	if err != nil {
+		return err
+	}
+
+	delivery, err := p.setMessage("delivery")
+	if err != nil {
+		return err
+	}
+	if delivery != nil {
+		d := &o.Delivery
+		err := firstError(
+			delivery.setStr("name", d.Name),
+			delivery.setStr("phone", d.Phone),
+			delivery.setStr("zip", d.Zip),
+			delivery.setStr("city", d.City),
+			delivery.setStr("address", d.Address),
+			delivery.setStr("region", d.Region),
+			delivery.setStr("email", d.Email),
+		)
+		if err != nil {
+			return err
+		}
+	}
+
+	payment, err := p.setMessage("payment")
+	if err != nil {
+		return err
+	}
+	if payment != nil {
+		pm := &o.Payment
+		err := firstError(
+			payment.setStr("transaction", pm.Transaction),
+			payment.setStr("request_id", pm.RequestID),
+			payment.setStr("currency", pm.Currency),
+			payment.setStr("provider", pm.Provider),
+			payment.setInt("amount", int64(pm.Amount)),
+			payment.setInt("payment_dt", pm.PaymentDt),
+			payment.setStr("bank", pm.Bank),
+			payment.setInt("delivery_cost", int64(pm.DeliveryCost)),
+			payment.setInt("goods_total", int64(pm.GoodsTotal)),
+			payment.setInt("custom_fee", int64(pm.CustomFee)),
+		)
+		if err != nil {
+			return err
+		}
+	}
+
+	items, err := p.appendTo("items", len(o.Items))
+	if err != nil {
+		return err
+	}
+	for i, item := range items {
+		it := &o.Items[i]
+		err := firstError(
+			item.setInt("chrt_id", it.ChrtID),
+			item.setStr("track_number", it.TrackNumber),
+			item.setInt("price", int64(it.Price)),
+			item.setStr("rid", it.Rid),
+			item.setStr("name", it.Name),
+			item.setInt("sale", int64(it.Sale)),
+			item.setStr("size", it.Size),
+			item.setInt("total_price", int64(it.TotalPrice)),
+			item.setInt("nm_id", it.NmID),
+			item.setStr("brand", it.Brand),
+			item.setInt("status", int64(it.Status)),
+		)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,25 @@
+package codec
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/bufbuild/protocompile"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// compileProto builds the descriptor of a registered .proto schema with
+// protocompile. Imports of the well-known types (google/protobuf/timestamp.proto
+// and others) resolve to the copies bundled with the compiler.
+func compileProto(name, definition string) (protoreflect.FileDescriptor, error) {
+	compiler := protocompile.Compiler{
+		Resolver: protocompile.WithStandardImports(&protocompile.SourceResolver{
+			Accessor: protocompile.SourceAccessorFromMap(map[string]string{name: definition}),
+		}),
+	}
+	files, err := compiler.Compile(context.Background(), name)
+	if err != nil {
+		return nil, fmt.Errorf("compile %s: %w", name, err)
+	}
+	return files[0], nil
+}
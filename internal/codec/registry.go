@@ -0,0 +1,132 @@
+package codec
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"order-service-wbtech/internal/config"
+)
+
+var ErrSchemaNotFound = errors.New("schema not found")
+
+// Schema — a registered schema, as returned by a schema registry
+type Schema struct {
+	ID         int
+	Subject    string
+	Format     Format
+	Definition string
+}
+
+// SchemaRegistry resolves schemas the way a Confluent-compatible registry does:
+// by the ID embedded in the wire format, or by subject for producers
+type SchemaRegistry interface {
+	SchemaByID(ctx context.Context, id int) (*Schema, error)
+	LatestSchema(ctx context.Context, subject string) (*Schema, error)
+}
+
+// FileRegistry — SchemaRegistry backed by a JSON index of schema files, a
+// stand-in for HTTPRegistry in tests and local setups:
+//
+//	[{"id": 1, "subject": "orders-value-avro", "format": "avro", "file": "order.avsc"}]
+//
+// File paths are relative to the index.
+type FileRegistry struct {
+	mu        sync.RWMutex
+	byID      map[int]*Schema
+	bySubject map[string]*Schema
+}
+
+type fileRegistryEntry struct {
+	ID      int    `json:"id"`
+	Subject string `json:"subject"`
+	Format  Format `json:"format"`
+	File    string `json:"file"`
+}
+
+func NewFileRegistry(indexPath string) (*FileRegistry, error) {
+	data, err := os.ReadFile(indexPath)
+	if err != nil {
+		return nil, fmt.Errorf("read schema index: %w", err)
+	}
+
+	var entries []fileRegistryEntry
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return nil, fmt.Errorf("parse schema index %s: %w", indexPath, err)
+	}
+
+	r := NewMemoryRegistry()
+	dir := filepath.Dir(indexPath)
+	for _, e := range entries {
+		if _, err := ParseFormat(string(e.Format)); err != nil {
+			return nil, fmt.Errorf("schema %d: %w", e.ID, err)
+		}
+
+		def, err := os.ReadFile(filepath.Join(dir, e.File))
+		if err != nil {
+			return nil, fmt.Errorf("schema %d: %w", e.ID, err)
+		}
+		r.Register(&Schema{ID: e.ID, Subject: e.Subject, Format: e.Format, Definition: string(def)})
+	}
+	return r, nil
+}
+
+// RegistryFromConfig returns the client of SCHEMA_REGISTRY_URL or loads the
+// file registry of SCHEMA_REGISTRY_FILE; without either it returns nil, which is
+// enough for JSON
+func RegistryFromConfig(k config.Kafka) (SchemaRegistry, error) {
+	if k.SchemaRegistryURL != "" {
+		return NewHTTPRegistry(k.SchemaRegistryURL, k.SchemaRegistryUsername, k.SchemaRegistryPassword), nil
+	}
+	if k.SchemaRegistryFile == "" {
+		return nil, nil
+	}
+	registry, err := NewFileRegistry(k.SchemaRegistryFile)
+	if err != nil {
+		return nil, fmt.Errorf("load schema registry: %w", err)
+	}
+	return registry, nil
+}
+
+// NewMemoryRegistry returns an empty registry filled with Register
+func NewMemoryRegistry() *FileRegistry {
+	return &FileRegistry{
+		byID:      make(map[int]*Schema),
+		bySubject: make(map[string]*Schema),
+	}
+}
+
+// Register adds s; a later schema with a higher ID becomes the latest of its subject
+func (r *FileRegistry) Register(s *Schema) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.byID[s.ID] = s
+	if latest, ok := r.bySubject[s.Subject]; !ok || s.ID > latest.ID {
+		r.bySubject[s.Subject] = s
+	}
+}
+
+func (r *FileRegistry) SchemaByID(_ context.Context, id int) (*Schema, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if s, ok := r.byID[id]; ok {
+		return s, nil
+	}
+	return nil, fmt.Errorf("schema id %d: %w", id, ErrSchemaNotFound)
+}
+
+func (r *FileRegistry) LatestSchema(_ context.Context, subject string) (*Schema, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if s, ok := r.bySubject[subject]; ok {
+		return s, nil
+	}
+	return nil, fmt.Errorf("subject %s: %w", subject, ErrSchemaNotFound)
+}
@@ -0,0 +1,160 @@
+package codec
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// latestSchemaTTL — how long the latest schema of a subject is reused before
+// the registry is asked again; schemas by ID never change and are kept
+const latestSchemaTTL = time.Minute
+
+// HTTPRegistry — SchemaRegistry client of a Confluent-compatible registry REST
+// API (Confluent Schema Registry, Redpanda, Apicurio in ccompat mode). Protobuf
+// schemas may import the well-known types only: registry references are not
+// resolved.
+type HTTPRegistry struct {
+	baseURL  string
+	username string
+	password string
+	client   *http.Client
+	now      func() time.Time
+
+	mu     sync.RWMutex
+	byID   map[int]*Schema
+	latest map[string]latestSchema
+}
+
+type latestSchema struct {
+	schema  *Schema
+	fetched time.Time
+}
+
+// registrySchema — a schema as the REST API returns it; SchemaType is empty for Avro
+type registrySchema struct {
+	ID         int    `json:"id"`
+	Subject    string `json:"subject"`
+	Schema     string `json:"schema"`
+	SchemaType string `json:"schemaType"`
+}
+
+// registryError — the error body of the REST API, e.g. 40403 for an unknown schema
+type registryError struct {
+	ErrorCode int    `json:"error_code"`
+	Message   string `json:"message"`
+}
+
+// NewHTTPRegistry returns a client of the registry at baseURL; username and
+// password enable basic auth (e.g. a Confluent Cloud API key)
+func NewHTTPRegistry(baseURL, username, password string) *HTTPRegistry {
+	return &HTTPRegistry{
+		baseURL:  strings.TrimRight(baseURL, "/"),
+		username: username,
+		password: password,
+		client:   &http.Client{Timeout: 10 * time.Second},
+		now:      time.Now,
+		byID:     make(map[int]*Schema),
+		latest:   make(map[string]latestSchema),
+	}
+}
+
+func (r *HTTPRegistry) SchemaByID(ctx context.Context, id int) (*Schema, error) {
+	r.mu.RLock()
+	s, ok := r.byID[id]
+	r.mu.RUnlock()
+	if ok {
+		return s, nil
+	}
+
+	var body registrySchema
+	if err := r.get(ctx, "/schemas/ids/"+strconv.Itoa(id), &body); err != nil {
+		return nil, fmt.Errorf("schema id %d: %w", id, err)
+	}
+	body.ID = id
+	s, err := body.schema()
+	if err != nil {
+		return nil, fmt.Errorf("schema id %d: %w", id, err)
+	}
+
+	r.mu.Lock()
+	r.byID[id] = s
+	r.mu.Unlock()
+	return s, nil
+}
+
+func (r *HTTPRegistry) LatestSchema(ctx context.Context, subject string) (*Schema, error) {
+	r.mu.RLock()
+	cached, ok := r.latest[subject]
+	r.mu.RUnlock()
+	if ok && r.now().Sub(cached.fetched) < latestSchemaTTL {
+		return cached.schema, nil
+	}
+
+	var body registrySchema
+	if err := r.get(ctx, "/subjects/"+url.PathEscape(subject)+"/versions/latest", &body); err != nil {
+		return nil, fmt.Errorf("subject %s: %w", subject, err)
+	}
+	s, err := body.schema()
+	if err != nil {
+		return nil, fmt.Errorf("subject %s: %w", subject, err)
+	}
+
+	r.mu.Lock()
+	r.byID[s.ID] = s
+	r.latest[subject] = latestSchema{schema: s, fetched: r.now()}
+	r.mu.Unlock()
+	return s, nil
+}
+
+// get fetches path into out; a 404 is ErrSchemaNotFound
+func (r *HTTPRegistry) get(ctx context.Context, path string, out any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, r.baseURL+path, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/vnd.schemaregistry.v1+json")
+	if r.username != "" {
+		req.SetBasicAuth(r.username, r.password)
+	}
+
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("schema registry: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		var regErr registryError
+		_ = json.NewDecoder(resp.Body).Decode(&regErr)
+		if resp.StatusCode == http.StatusNotFound {
+			return fmt.Errorf("%w: %s", ErrSchemaNotFound, regErr.Message)
+		}
+		return fmt.Errorf("schema registry: %s (error code %d): %s", resp.Status, regErr.ErrorCode, regErr.Message)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("schema registry: decode response: %w", err)
+	}
+	return nil
+}
+
+func (s registrySchema) schema() (*Schema, error) {
+	var format Format
+	switch s.SchemaType {
+	case "", "AVRO":
+		format = FormatAvro
+	case "PROTOBUF":
+		format = FormatProtobuf
+	case "JSON":
+		format = FormatJSON
+	default:
+		return nil, fmt.Errorf("unsupported schema type %q", s.SchemaType)
+	}
+	return &Schema{ID: s.ID, Subject: s.Subject, Format: format, Definition: s.Schema}, nil
+}
@@ -0,0 +1,33 @@
+package codec
+
+import (
+	"encoding/binary"
+	"errors"
+)
+
+// magicByte starts every message in the Confluent wire format:
+// magic byte, 4-byte big-endian schema ID, payload
+const magicByte byte = 0
+
+var ErrNotWireFormat = errors.New("payload is not in the Confluent wire format")
+
+// IsWireFormat reports whether data starts with the Confluent framing
+func IsWireFormat(data []byte) bool {
+	return len(data) >= 5 && data[0] == magicByte
+}
+
+// EncodeWire frames payload with schemaID
+func EncodeWire(schemaID int, payload []byte) []byte {
+	out := make([]byte, 5, 5+len(payload))
+	out[0] = magicByte
+	binary.BigEndian.PutUint32(out[1:5], uint32(schemaID))
+	return append(out, payload...)
+}
+
+// DecodeWire splits a framed message into its schema ID and payload
+func DecodeWire(data []byte) (int, []byte, error) {
+	if !IsWireFormat(data) {
+		return 0, nil, ErrNotWireFormat
+	}
+	return int(binary.BigEndian.Uint32(data[1:5])), data[5:], nil
+}
@@ -1,9 +1,12 @@
 package config
 
 import (
+	"errors"
+	"fmt"
 	"log"
 	"os"
 	"strconv"
+	"time"
 
 	"github.com/joho/godotenv"
 )
@@ -14,18 +17,153 @@ type DB struct {
 	Password string
 	Host     string
 	Port     int
+	// ConflictPolicy for redelivered orders with different content: reject (default), overwrite or version
+	ConflictPolicy string
 }
 
 type Kafka struct {
 	Brokers string
 	Topic   string
 	GroupID string
+
+	// TLSEnabled switches the broker connections to TLS; the CA file replaces the
+	// system roots and the certificate and key enable mutual TLS
+	TLSEnabled  bool
+	TLSCAFile   string
+	TLSCertFile string
+	TLSKeyFile  string
+	// SASLMechanism is empty (no SASL), PLAIN, SCRAM-SHA-256 or SCRAM-SHA-512
+	SASLMechanism string
+	SASLUsername  string
+	SASLPassword  string
+
+	// DLQTopic receives messages that fail decoding or validation; empty disables the DLQ
+	DLQTopic string
+	// ParkingTopic receives messages that could not be saved after all retries; defaults to DLQTopic
+	ParkingTopic string
+
+	// Retry policy of the orders topic: 5 attempts, backoff from 200ms up to 10s
+	// growing 2x per attempt, randomized by ±20%
+	RetryMaxAttempts    int
+	RetryInitialBackoff time.Duration
+	RetryMaxBackoff     time.Duration
+	RetryMultiplier     float64
+	RetryJitter         float64
+
+	// Reader tuning, shared by all consumers. MinBytes (default 1) and MaxBytes
+	// (default 10MB) bound a fetch response; the broker holds a fetch for up to
+	// MaxWait (default 10s) waiting for MinBytes, and the reader waits up to
+	// ReadBatchTimeout (default 5s) for the rest of a response.
+	MinBytes         int
+	MaxBytes         int
+	MaxWait          time.Duration
+	ReadBatchTimeout time.Duration
+	// QueueCapacity is the number of messages the reader prefetches (default 100)
+	QueueCapacity int
+	// WorkerQueueSize is the number of messages waiting for each worker (default 2)
+	WorkerQueueSize int
+	// FetchRetryDelay is the pause after a failed fetch (default 1s)
+	FetchRetryDelay time.Duration
+
+	// BatchSize > 1 switches the consumer to batch mode: up to BatchSize messages,
+	// collected for at most BatchTimeout (default 500ms), are stored in one transaction
+	BatchSize    int
+	BatchTimeout time.Duration
+
+	// MessageFormat is the order payload format (json, avro, protobuf) used when
+	// neither the message_format header nor the Confluent wire format tells it
+	MessageFormat string
+	// SchemaRegistryURL is a Confluent-compatible schema registry, with optional
+	// basic auth; SchemaRegistryFile is the index of a file-backed registry for
+	// local setups. Without either, Avro and Protobuf payloads are disabled.
+	SchemaRegistryURL      string
+	SchemaRegistryUsername string
+	SchemaRegistryPassword string
+	SchemaRegistryFile     string
+
+	// OutboxTopic receives order_accepted events relayed from the outbox table;
+	// empty disables the relay, events stay in the table until it is enabled.
+	// The relay polls every OutboxInterval (default 1s), OutboxBatchSize (default 100) events at a time.
+	OutboxTopic     string
+	OutboxInterval  time.Duration
+	OutboxBatchSize int
+
+	// RateLimit caps ingestion of all topics in messages per second, 0 (default)
+	// means unlimited, with bursts of RateBurst (default 100); SlowRate (default 50)
+	// replaces it while the database is slow
+	RateLimit int
+	RateBurst int
+	SlowRate  int
+	// Backpressure thresholds: above the slow ones ingestion drops to SlowRate,
+	// above the pause ones it stops until the database recovers; 0 disables a check.
+	// Defaults: slow at 500ms save latency or 100ms pool wait, pause at 2s or 1s,
+	// checked every second.
+	SlowSaveLatency      time.Duration
+	SlowPoolWait         time.Duration
+	PauseSaveLatency     time.Duration
+	PausePoolWait        time.Duration
+	BackpressureInterval time.Duration
+
+	// Subscriptions are the consumed topics: Topic with new orders and, when
+	// configured, the order status and payment confirmation topics
+	Subscriptions []Subscription
+}
+
+// Subscription — a consumed topic, the handler of its messages and its own
+// worker pool and retry policy
+type Subscription struct {
+	Topic string
+	// Handler is the event type of messages without an event type header:
+	// order_created, order_status_changed or payment_confirmed
+	Handler string
+	// Workers is the worker pool size (KAFKA_WORKERS, default 10)
+	Workers int
+
+	RetryMaxAttempts    int
+	RetryInitialBackoff time.Duration
+	RetryMaxBackoff     time.Duration
+	// RetryMultiplier grows the backoff per attempt, at least 1
+	RetryMultiplier float64
+	// RetryJitter is the randomized fraction of the backoff, 0..1
+	RetryJitter float64
+}
+
+type Tracing struct {
+	// Exporter is none, stdout or otlp; OTLP is configured by the standard OTEL_EXPORTER_OTLP_* variables
+	Exporter    string
+	ServiceName string
 }
 
 type Config struct {
-	DBcfg    DB
-	Kafkacfg Kafka
-	HTTPPort string
+	DBcfg      DB
+	Kafkacfg   Kafka
+	Tracingcfg Tracing
+	HTTPPort   string
+	// AdminToken is the bearer token of the /admin endpoints; empty disables them
+	AdminToken string
+}
+
+// redacted replaces a secret in String
+const redacted = "***"
+
+// String prints the configuration for the startup log with passwords and
+// tokens masked
+func (c Config) String() string {
+	// plain has the fields of Config but not this method
+	type plain Config
+	p := plain(c)
+	p.DBcfg.Password = mask(p.DBcfg.Password)
+	p.Kafkacfg.SASLPassword = mask(p.Kafkacfg.SASLPassword)
+	p.Kafkacfg.SchemaRegistryPassword = mask(p.Kafkacfg.SchemaRegistryPassword)
+	p.AdminToken = mask(p.AdminToken)
+	return fmt.Sprintf("%+v", p)
+}
+
+func mask(secret string) string {
+	if secret == "" {
+		return ""
+	}
+	return redacted
 }
 
 func LoadConfig() *Config {
@@ -45,6 +183,8 @@ func LoadConfig() *Config {
 		Password: os.Getenv("DB_PASSWORD"),
 		Host:     os.Getenv("DB_HOST"),
 		Port:     portInt,
+
+		ConflictPolicy: os.Getenv("ORDER_CONFLICT_POLICY"),
 	}
 
 	kafkaBrokers := os.Getenv("KAFKA_BROKERS")
@@ -53,14 +193,233 @@ func LoadConfig() *Config {
 	}
 
 	kafkaCfg := Kafka{
-		Brokers: kafkaBrokers,
-		Topic:   os.Getenv("KAFKA_TOPIC"),
-		GroupID: os.Getenv("KAFKA_GROUP_ID"),
+		Brokers:  kafkaBrokers,
+		Topic:    os.Getenv("KAFKA_TOPIC"),
+		GroupID:  os.Getenv("KAFKA_GROUP_ID"),
+		DLQTopic: os.Getenv("KAFKA_DLQ_TOPIC"),
+
+		TLSEnabled:    getEnvBool("KAFKA_TLS_ENABLED", false),
+		TLSCAFile:     os.Getenv("KAFKA_TLS_CA_FILE"),
+		TLSCertFile:   os.Getenv("KAFKA_TLS_CERT_FILE"),
+		TLSKeyFile:    os.Getenv("KAFKA_TLS_KEY_FILE"),
+		SASLMechanism: os.Getenv("KAFKA_SASL_MECHANISM"),
+		SASLUsername:  os.Getenv("KAFKA_SASL_USERNAME"),
+		SASLPassword:  os.Getenv("KAFKA_SASL_PASSWORD"),
+
+		ParkingTopic:        os.Getenv("KAFKA_PARKING_TOPIC"),
+		RetryMaxAttempts:    getEnvInt("KAFKA_RETRY_MAX_ATTEMPTS", 5),
+		RetryInitialBackoff: getEnvDuration("KAFKA_RETRY_INITIAL_BACKOFF", 200*time.Millisecond),
+		RetryMaxBackoff:     getEnvDuration("KAFKA_RETRY_MAX_BACKOFF", 10*time.Second),
+		RetryMultiplier:     getEnvFloat("KAFKA_RETRY_MULTIPLIER", 2),
+		RetryJitter:         getEnvFloat("KAFKA_RETRY_JITTER", 0.2),
+
+		MinBytes:         getEnvInt("KAFKA_MIN_BYTES", 1),
+		MaxBytes:         getEnvInt("KAFKA_MAX_BYTES", 10e6),
+		MaxWait:          getEnvDuration("KAFKA_MAX_WAIT", 10*time.Second),
+		ReadBatchTimeout: getEnvDuration("KAFKA_READ_BATCH_TIMEOUT", 5*time.Second),
+		QueueCapacity:    getEnvInt("KAFKA_QUEUE_CAPACITY", 100),
+		WorkerQueueSize:  getEnvInt("KAFKA_WORKER_QUEUE_SIZE", 2),
+		FetchRetryDelay:  getEnvDuration("KAFKA_FETCH_RETRY_DELAY", time.Second),
+
+		BatchSize:    getEnvInt("KAFKA_BATCH_SIZE", 0),
+		BatchTimeout: getEnvDuration("KAFKA_BATCH_TIMEOUT", 500*time.Millisecond),
+
+		MessageFormat:          os.Getenv("KAFKA_MESSAGE_FORMAT"),
+		SchemaRegistryURL:      os.Getenv("SCHEMA_REGISTRY_URL"),
+		SchemaRegistryUsername: os.Getenv("SCHEMA_REGISTRY_USERNAME"),
+		SchemaRegistryPassword: os.Getenv("SCHEMA_REGISTRY_PASSWORD"),
+		SchemaRegistryFile:     os.Getenv("SCHEMA_REGISTRY_FILE"),
+
+		OutboxTopic:     os.Getenv("KAFKA_OUTBOX_TOPIC"),
+		OutboxInterval:  getEnvDuration("KAFKA_OUTBOX_INTERVAL", time.Second),
+		OutboxBatchSize: getEnvInt("KAFKA_OUTBOX_BATCH_SIZE", 100),
+
+		RateLimit: getEnvInt("KAFKA_RATE_LIMIT", 0),
+		RateBurst: getEnvInt("KAFKA_RATE_BURST", 100),
+		SlowRate:  getEnvInt("KAFKA_SLOW_RATE", 50),
+
+		SlowSaveLatency:      getEnvDuration("BACKPRESSURE_SLOW_SAVE_LATENCY", 500*time.Millisecond),
+		SlowPoolWait:         getEnvDuration("BACKPRESSURE_SLOW_POOL_WAIT", 100*time.Millisecond),
+		PauseSaveLatency:     getEnvDuration("BACKPRESSURE_PAUSE_SAVE_LATENCY", 2*time.Second),
+		PausePoolWait:        getEnvDuration("BACKPRESSURE_PAUSE_POOL_WAIT", time.Second),
+		BackpressureInterval: getEnvDuration("BACKPRESSURE_INTERVAL", time.Second),
+	}
+	kafkaCfg.Subscriptions = loadSubscriptions(kafkaCfg)
+	if err := kafkaCfg.Validate(); err != nil {
+		log.Fatalf("Invalid Kafka config: %v", err)
+	}
+
+	tracingCfg := Tracing{
+		Exporter:    os.Getenv("OTEL_TRACES_EXPORTER"),
+		ServiceName: getEnv("OTEL_SERVICE_NAME", "order-service"),
 	}
 
 	return &Config{
-		DBcfg:    db,
-		Kafkacfg: kafkaCfg,
-		HTTPPort: os.Getenv("HTTP_PORT"),
+		DBcfg:      db,
+		Kafkacfg:   kafkaCfg,
+		Tracingcfg: tracingCfg,
+		HTTPPort:   os.Getenv("HTTP_PORT"),
+		AdminToken: os.Getenv("ADMIN_TOKEN"),
+	}
+}
+
+// defaultStatusRetryMaxAttempts — attempts of a status event before it is parked.
+// An event for an order that has not arrived is retried, and a key-routed worker
+// waits on it meanwhile, so it gets a short budget rather than the orders one.
+const defaultStatusRetryMaxAttempts = 3
+
+// loadSubscriptions builds the orders subscription from KAFKA_TOPIC and the
+// optional status and payment ones from KAFKA_STATUS_* and KAFKA_PAYMENT_*,
+// which default to the worker count and retry policy of the orders topic,
+// except for the attempts of status events
+func loadSubscriptions(k Kafka) []Subscription {
+	orders := Subscription{
+		Topic:               k.Topic,
+		Handler:             "order_created",
+		Workers:             getEnvInt("KAFKA_WORKERS", 10),
+		RetryMaxAttempts:    k.RetryMaxAttempts,
+		RetryInitialBackoff: k.RetryInitialBackoff,
+		RetryMaxBackoff:     k.RetryMaxBackoff,
+		RetryMultiplier:     k.RetryMultiplier,
+		RetryJitter:         k.RetryJitter,
+	}
+	subs := []Subscription{orders}
+
+	for _, extra := range []struct {
+		prefix, handler string
+		maxAttempts     int
+	}{
+		{"KAFKA_STATUS_", "order_status_changed", defaultStatusRetryMaxAttempts},
+		{"KAFKA_PAYMENT_", "payment_confirmed", orders.RetryMaxAttempts},
+	} {
+		topic := os.Getenv(extra.prefix + "TOPIC")
+		if topic == "" {
+			continue
+		}
+		subs = append(subs, Subscription{
+			Topic:               topic,
+			Handler:             extra.handler,
+			Workers:             getEnvInt(extra.prefix+"WORKERS", orders.Workers),
+			RetryMaxAttempts:    getEnvInt(extra.prefix+"RETRY_MAX_ATTEMPTS", extra.maxAttempts),
+			RetryInitialBackoff: getEnvDuration(extra.prefix+"RETRY_INITIAL_BACKOFF", orders.RetryInitialBackoff),
+			RetryMaxBackoff:     getEnvDuration(extra.prefix+"RETRY_MAX_BACKOFF", orders.RetryMaxBackoff),
+			RetryMultiplier:     getEnvFloat(extra.prefix+"RETRY_MULTIPLIER", orders.RetryMultiplier),
+			RetryJitter:         getEnvFloat(extra.prefix+"RETRY_JITTER", orders.RetryJitter),
+		})
+	}
+	return subs
+}
+
+// Validate reports settings the consumers cannot start with, all at once
+func (k Kafka) Validate() error {
+	var errs []error
+	check := func(ok bool, format string, args ...any) {
+		if !ok {
+			errs = append(errs, fmt.Errorf(format, args...))
+		}
+	}
+
+	check(k.MinBytes >= 1, "KAFKA_MIN_BYTES must be at least 1, got %d", k.MinBytes)
+	check(k.MaxBytes >= k.MinBytes, "KAFKA_MAX_BYTES (%d) must not be below KAFKA_MIN_BYTES (%d)", k.MaxBytes, k.MinBytes)
+	check(k.MaxWait > 0, "KAFKA_MAX_WAIT must be positive, got %s", k.MaxWait)
+	check(k.ReadBatchTimeout > 0, "KAFKA_READ_BATCH_TIMEOUT must be positive, got %s", k.ReadBatchTimeout)
+	check(k.QueueCapacity >= 1, "KAFKA_QUEUE_CAPACITY must be at least 1, got %d", k.QueueCapacity)
+	check(k.WorkerQueueSize >= 1, "KAFKA_WORKER_QUEUE_SIZE must be at least 1, got %d", k.WorkerQueueSize)
+	check(k.FetchRetryDelay > 0, "KAFKA_FETCH_RETRY_DELAY must be positive, got %s", k.FetchRetryDelay)
+
+	check(k.BatchSize >= 0, "KAFKA_BATCH_SIZE must not be negative, got %d", k.BatchSize)
+	check(k.BatchTimeout > 0, "KAFKA_BATCH_TIMEOUT must be positive, got %s", k.BatchTimeout)
+
+	check(k.RateLimit >= 0, "KAFKA_RATE_LIMIT must not be negative, got %d", k.RateLimit)
+	check(k.RateBurst >= 1, "KAFKA_RATE_BURST must be at least 1, got %d", k.RateBurst)
+	check(k.SlowRate >= 1, "KAFKA_SLOW_RATE must be at least 1, got %d", k.SlowRate)
+	check(k.RateLimit == 0 || k.SlowRate <= k.RateLimit, "KAFKA_SLOW_RATE (%d) must not exceed KAFKA_RATE_LIMIT (%d)", k.SlowRate, k.RateLimit)
+	check(k.BackpressureInterval > 0, "BACKPRESSURE_INTERVAL must be positive, got %s", k.BackpressureInterval)
+	check(k.PauseSaveLatency == 0 || k.PauseSaveLatency >= k.SlowSaveLatency,
+		"BACKPRESSURE_PAUSE_SAVE_LATENCY (%s) must not be below BACKPRESSURE_SLOW_SAVE_LATENCY (%s)", k.PauseSaveLatency, k.SlowSaveLatency)
+	check(k.PausePoolWait == 0 || k.PausePoolWait >= k.SlowPoolWait,
+		"BACKPRESSURE_PAUSE_POOL_WAIT (%s) must not be below BACKPRESSURE_SLOW_POOL_WAIT (%s)", k.PausePoolWait, k.SlowPoolWait)
+
+	check(k.SchemaRegistryURL == "" || k.SchemaRegistryFile == "", "set either SCHEMA_REGISTRY_URL or SCHEMA_REGISTRY_FILE, not both")
+
+	check(k.OutboxInterval > 0, "KAFKA_OUTBOX_INTERVAL must be positive, got %s", k.OutboxInterval)
+	check(k.OutboxBatchSize >= 1, "KAFKA_OUTBOX_BATCH_SIZE must be at least 1, got %d", k.OutboxBatchSize)
+
+	topics := make(map[string]bool)
+	for _, sub := range k.Subscriptions {
+		check(sub.Topic != "", "topic of the %s subscription is required", sub.Handler)
+		check(!topics[sub.Topic], "topic %s is subscribed twice", sub.Topic)
+		topics[sub.Topic] = true
+		check(sub.Workers >= 1, "workers of topic %s must be at least 1, got %d", sub.Topic, sub.Workers)
+		check(sub.RetryMaxAttempts >= 1, "retry attempts of topic %s must be at least 1, got %d", sub.Topic, sub.RetryMaxAttempts)
+		check(sub.RetryInitialBackoff > 0 && sub.RetryMaxBackoff >= sub.RetryInitialBackoff,
+			"retry backoff of topic %s must be positive with max (%s) not below initial (%s)", sub.Topic, sub.RetryMaxBackoff, sub.RetryInitialBackoff)
+		check(sub.RetryMultiplier >= 1, "retry multiplier of topic %s must be at least 1, got %g", sub.Topic, sub.RetryMultiplier)
+		check(sub.RetryJitter >= 0 && sub.RetryJitter <= 1, "retry jitter of topic %s must be between 0 and 1, got %g", sub.Topic, sub.RetryJitter)
+	}
+	return errors.Join(errs...)
+}
+
+// getEnv reads a string env variable, falling back to def when it is unset
+func getEnv(key, def string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return def
+}
+
+// getEnvInt reads an integer env variable, falling back to def when it is unset
+func getEnvInt(key string, def int) int {
+	value := os.Getenv(key)
+	if value == "" {
+		return def
+	}
+
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		log.Fatalf("Invalid %s: %v", key, err)
+	}
+	return n
+}
+
+// getEnvFloat reads a decimal env variable (e.g. "1.5"), falling back to def when it is unset
+func getEnvFloat(key string, def float64) float64 {
+	value := os.Getenv(key)
+	if value == "" {
+		return def
+	}
+
+	f, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		log.Fatalf("Invalid %s: %v", key, err)
+	}
+	return f
+}
+
+// getEnvBool reads a boolean env variable (true/false, 1/0), falling back to def when it is unset
+func getEnvBool(key string, def bool) bool {
+	value := os.Getenv(key)
+	if value == "" {
+		return def
+	}
+
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		log.Fatalf("Invalid %s: %v", key, err)
+	}
+	return b
+}
+
+// getEnvDuration reads a duration env variable (e.g. "500ms", "2s"), falling back to def when it is unset
+func getEnvDuration(key string, def time.Duration) time.Duration {
+	value := os.Getenv(key)
+	if value == "" {
+		return def
+	}
+
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		log.Fatalf("Invalid %s: %v", key, err)
 	}
+	return d
 }
@@ -0,0 +1,124 @@
+package config
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func validKafka() Kafka {
+	return Kafka{
+		MinBytes:         1,
+		MaxBytes:         10e6,
+		MaxWait:          10 * time.Second,
+		ReadBatchTimeout: 5 * time.Second,
+		QueueCapacity:    100,
+		WorkerQueueSize:  2,
+		FetchRetryDelay:  time.Second,
+
+		BatchTimeout: 500 * time.Millisecond,
+
+		RateBurst:            100,
+		SlowRate:             50,
+		SlowSaveLatency:      500 * time.Millisecond,
+		SlowPoolWait:         100 * time.Millisecond,
+		PauseSaveLatency:     2 * time.Second,
+		PausePoolWait:        time.Second,
+		BackpressureInterval: time.Second,
+
+		OutboxInterval:  time.Second,
+		OutboxBatchSize: 100,
+
+		Subscriptions: []Subscription{{
+			Topic:               "orders",
+			Handler:             "order_created",
+			Workers:             10,
+			RetryMaxAttempts:    5,
+			RetryInitialBackoff: 200 * time.Millisecond,
+			RetryMaxBackoff:     10 * time.Second,
+			RetryMultiplier:     2,
+			RetryJitter:         0.2,
+		}},
+	}
+}
+
+func TestKafkaValidate(t *testing.T) {
+	require.NoError(t, validKafka().Validate())
+
+	cases := map[string]func(k *Kafka){
+		"KAFKA_MIN_BYTES":                  func(k *Kafka) { k.MinBytes = 0 },
+		"KAFKA_MAX_BYTES":                  func(k *Kafka) { k.MinBytes, k.MaxBytes = 1000, 10 },
+		"KAFKA_MAX_WAIT":                   func(k *Kafka) { k.MaxWait = 0 },
+		"KAFKA_WORKER_QUEUE_SIZE":          func(k *Kafka) { k.WorkerQueueSize = 0 },
+		"KAFKA_FETCH_RETRY_DELAY":          func(k *Kafka) { k.FetchRetryDelay = -time.Second },
+		"KAFKA_SLOW_RATE (500)":            func(k *Kafka) { k.RateLimit, k.SlowRate = 100, 500 },
+		"BACKPRESSURE_PAUSE_SAVE_LATENCY":  func(k *Kafka) { k.PauseSaveLatency = 100 * time.Millisecond },
+		"workers of topic orders":          func(k *Kafka) { k.Subscriptions[0].Workers = 0 },
+		"retry multiplier of topic orders": func(k *Kafka) { k.Subscriptions[0].RetryMultiplier = 0.5 },
+		"retry jitter of topic orders":     func(k *Kafka) { k.Subscriptions[0].RetryJitter = 1.5 },
+		"topic orders is subscribed twice": func(k *Kafka) {
+			k.Subscriptions = append(k.Subscriptions, k.Subscriptions[0])
+		},
+		"topic of the order_created subscription": func(k *Kafka) { k.Subscriptions[0].Topic = "" },
+		"SCHEMA_REGISTRY_URL or SCHEMA_REGISTRY_FILE": func(k *Kafka) {
+			k.SchemaRegistryURL, k.SchemaRegistryFile = "http://registry:8081", "registry.json"
+		},
+	}
+	for want, breakIt := range cases {
+		k := validKafka()
+		breakIt(&k)
+		err := k.Validate()
+		if assert.Error(t, err, want) {
+			assert.Contains(t, err.Error(), want)
+		}
+	}
+}
+
+func TestKafkaValidate_ReportsEveryError(t *testing.T) {
+	k := validKafka()
+	k.MinBytes = 0
+	k.QueueCapacity = 0
+	k.OutboxBatchSize = 0
+
+	err := k.Validate()
+	require.Error(t, err)
+	for _, want := range []string{"KAFKA_MIN_BYTES", "KAFKA_QUEUE_CAPACITY", "KAFKA_OUTBOX_BATCH_SIZE"} {
+		assert.Contains(t, err.Error(), want)
+	}
+}
+
+func TestConfigString_MasksSecrets(t *testing.T) {
+	cfg := &Config{
+		DBcfg:      DB{User: "orders", Password: "db-secret"},
+		Kafkacfg:   Kafka{SASLUsername: "svc", SASLPassword: "sasl-secret", SchemaRegistryPassword: "registry-secret"},
+		AdminToken: "admin-secret",
+	}
+
+	for _, out := range []string{cfg.String(), fmt.Sprintf("%+v", cfg), fmt.Sprintf("%v", *cfg)} {
+		for _, secret := range []string{"db-secret", "sasl-secret", "registry-secret", "admin-secret"} {
+			assert.NotContains(t, out, secret)
+		}
+		assert.Contains(t, out, "orders")
+		assert.Contains(t, out, "svc")
+	}
+	assert.Equal(t, "db-secret", cfg.DBcfg.Password, "the config itself is not changed")
+}
+
+func TestLoadSubscriptions_StatusEventsRetryBriefly(t *testing.T) {
+	t.Setenv("KAFKA_STATUS_TOPIC", "order-status")
+	t.Setenv("KAFKA_PAYMENT_TOPIC", "payments")
+	t.Setenv("KAFKA_STATUS_RETRY_MAX_ATTEMPTS", "")
+	t.Setenv("KAFKA_PAYMENT_RETRY_MAX_ATTEMPTS", "")
+
+	subs := loadSubscriptions(Kafka{Topic: "orders", RetryMaxAttempts: 10})
+	require.Len(t, subs, 3)
+	assert.Equal(t, 10, subs[0].RetryMaxAttempts)
+	assert.Equal(t, defaultStatusRetryMaxAttempts, subs[1].RetryMaxAttempts)
+	assert.Equal(t, 10, subs[2].RetryMaxAttempts, "payments follow the orders topic")
+
+	t.Setenv("KAFKA_STATUS_RETRY_MAX_ATTEMPTS", "7")
+	assert.Equal(t, 7, loadSubscriptions(Kafka{Topic: "orders"})[1].RetryMaxAttempts)
+}
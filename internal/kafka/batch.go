@@ -0,0 +1,150 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"go.opentelemetry.io/otel/trace"
+
+	"order-service-wbtech/internal/tracing"
+)
+
+// StartBatchConsumer — consumer that accumulates up to batchSize messages or waits
+// at most batchTimeout, stores them in a single transaction and commits all offsets afterwards
+func StartBatchConsumer(
+	ctx context.Context,
+	cluster Cluster,
+	topic string,
+	groupID string,
+	proc *Processor,
+	batchSize int,
+	batchTimeout time.Duration,
+	control *Control,
+	throttle *Throttle,
+	tuning Tuning,
+) {
+	source := NewKafkaSource(cluster, topic, groupID, tuning)
+	defer func() {
+		if err := source.Close(); err != nil {
+			log.Printf("Error closing reader: %v", err)
+		}
+	}()
+
+	log.Printf("Kafka batch consumer started | topic=%s group=%s batch=%d timeout=%s", topic, groupID, batchSize, batchTimeout)
+	NewConsumer(source, proc, 1).WithControl(control).WithThrottle(throttle).WithTuning(tuning).RunBatch(ctx, batchSize, batchTimeout)
+}
+
+// RunBatch processes messages in batches until ctx is cancelled
+func (c *Consumer) RunBatch(ctx context.Context, batchSize int, batchTimeout time.Duration) {
+	if batchSize <= 0 {
+		batchSize = 100
+	}
+	if batchTimeout <= 0 {
+		batchTimeout = 500 * time.Millisecond
+	}
+	workersTotal.Inc()
+	defer workersTotal.Dec()
+
+	for ctx.Err() == nil {
+		// wait out a backpressure pause before fetching; the rate limit is
+		// applied once the size of the batch is known
+		if err := c.throttle.Wait(ctx, 0); err != nil {
+			break
+		}
+
+		fetchCtx, cancelFetch, err := c.control.fetchContext(ctx)
+		if err != nil {
+			break
+		}
+
+		batch := fetchBatch(fetchCtx, c.source, batchSize, batchTimeout, c.tuning.FetchRetryDelay)
+		cancelFetch()
+		if len(batch) == 0 {
+			c.control.done(1)
+			continue
+		}
+		// the fetch already counts as one message in flight
+		c.control.begin(len(batch) - 1)
+
+		if err := c.throttle.Wait(ctx, len(batch)); err != nil {
+			break
+		}
+
+		started := time.Now()
+		if !handleBatch(ctx, c.proc, batch) {
+			break
+		}
+		batchDuration.WithLabelValues(batch[0].Topic).Observe(time.Since(started).Seconds())
+		for _, m := range batch {
+			observeLag(m)
+		}
+
+		committed := commitUntilDone(ctx, c.proc.retry, func(ctx context.Context) error {
+			commitCtx, span := tracing.Start(ctx, "commit batch", trace.WithSpanKind(trace.SpanKindConsumer))
+			err := c.source.CommitMessages(commitCtx, batch...)
+			tracing.End(span, err)
+			if err != nil {
+				log.Printf("Commit failed for batch of %d messages: %v → retrying", len(batch), err)
+			}
+			return err
+		})
+		if !committed {
+			break
+		}
+		log.Printf("Successfully processed and committed batch of %d messages", len(batch))
+		c.control.done(len(batch))
+	}
+
+	log.Println("Kafka batch consumer stopped gracefully")
+}
+
+// fetchBatch blocks until the first message arrives, then collects more until
+// the batch is full or batchTimeout has passed since the first message
+func fetchBatch(ctx context.Context, source MessageSource, batchSize int, batchTimeout, retryDelay time.Duration) []Message {
+	batch := make([]Message, 0, batchSize)
+
+	fetchCtx := ctx
+	for len(batch) < batchSize {
+		m, err := source.FetchMessage(fetchCtx)
+		if err != nil {
+			if errors.Is(err, context.DeadlineExceeded) || ctx.Err() != nil {
+				break
+			}
+			log.Printf("kafka: fetch message error: %v. Retrying...", err)
+			time.Sleep(retryDelay)
+			continue
+		}
+
+		batch = append(batch, m)
+		if len(batch) == 1 {
+			var cancel context.CancelFunc
+			fetchCtx, cancel = context.WithTimeout(ctx, batchTimeout)
+			defer cancel()
+		}
+	}
+
+	return batch
+}
+
+// handleBatch retries the batch until it is handled or the consumer stops.
+// It reports false when the batch must stay uncommitted.
+func handleBatch(ctx context.Context, proc *Processor, batch []Message) bool {
+	for attempt := 1; ; attempt++ {
+		_, err := proc.HandleBatch(ctx, batch)
+		if err == nil {
+			return true
+		}
+		if ctx.Err() != nil {
+			log.Printf("Stopped before batch of %d messages was handled → not committed", len(batch))
+			return false
+		}
+
+		handleErrors.WithLabelValues(batch[0].Topic).Inc()
+		log.Printf("Failed to handle batch of %d messages: %v → retrying", len(batch), err)
+		if err := proc.retry.sleep(ctx, attempt); err != nil {
+			return false
+		}
+	}
+}
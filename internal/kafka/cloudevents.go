@@ -0,0 +1,150 @@
+package kafka
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
+	"strings"
+
+	"order-service-wbtech/internal/codec"
+)
+
+// CloudEvents 1.0 Kafka protocol binding. In binary mode the attributes travel as
+// ce_* headers and the value is the event data; in structured mode the value is
+// the whole JSON envelope and content-type is application/cloudevents+json.
+const (
+	HeaderContentType           = "content-type"
+	HeaderCloudEventSpecVersion = "ce_specversion"
+	HeaderCloudEventID          = "ce_id"
+	HeaderCloudEventSource      = "ce_source"
+	HeaderCloudEventType        = "ce_type"
+
+	cloudEventHeaderPrefix = "ce_"
+	CloudEventsContentType = "application/cloudevents+json"
+)
+
+// CloudEvents types of order events
+const (
+	CloudEventOrderCreated       = "com.wbtech.order.created"
+	CloudEventOrderStatusChanged = "com.wbtech.order.status_changed"
+	CloudEventPaymentConfirmed   = "com.wbtech.payment.confirmed"
+)
+
+// cloudEventTypes maps CloudEvents types to the event types of the pipeline
+var cloudEventTypes = map[string]string{
+	CloudEventOrderCreated:       EventOrderCreated,
+	CloudEventOrderStatusChanged: EventOrderStatusChanged,
+	CloudEventPaymentConfirmed:   EventPaymentConfirmed,
+}
+
+// contentTypeFormats maps the content type of CloudEvents data to an order format
+var contentTypeFormats = map[string]codec.Format{
+	"application/json":       codec.FormatJSON,
+	"application/avro":       codec.FormatAvro,
+	"application/protobuf":   codec.FormatProtobuf,
+	"application/x-protobuf": codec.FormatProtobuf,
+}
+
+// normalizeCloudEvent turns a structured-mode CloudEvent into the equivalent
+// binary-mode message and checks the required attributes of binary-mode events.
+// Messages that are not CloudEvents are returned unchanged.
+func normalizeCloudEvent(m Message) (Message, error) {
+	if isStructuredCloudEvent(m) {
+		var err error
+		if m, err = structuredToBinary(m); err != nil {
+			return m, fmt.Errorf("structured cloudevent: %w", err)
+		}
+	}
+
+	version, ok := m.Header(HeaderCloudEventSpecVersion)
+	if !ok {
+		return m, nil
+	}
+	if string(version) != "1.0" {
+		return m, fmt.Errorf("unsupported cloudevents specversion %q", version)
+	}
+	for _, key := range []string{HeaderCloudEventID, HeaderCloudEventSource, HeaderCloudEventType} {
+		if v, _ := m.Header(key); len(v) == 0 {
+			return m, fmt.Errorf("cloudevent without required attribute %s", strings.TrimPrefix(key, cloudEventHeaderPrefix))
+		}
+	}
+	return m, nil
+}
+
+func isStructuredCloudEvent(m Message) bool {
+	ct, ok := m.Header(HeaderContentType)
+	if !ok {
+		return false
+	}
+	mediaType, _, err := mime.ParseMediaType(string(ct))
+	return err == nil && mediaType == CloudEventsContentType
+}
+
+// structuredToBinary moves the envelope attributes to ce_* headers and its data
+// to the value, so dead-lettered messages remain valid CloudEvents
+func structuredToBinary(m Message) (Message, error) {
+	var envelope map[string]json.RawMessage
+	if err := json.Unmarshal(m.Value, &envelope); err != nil {
+		return m, err
+	}
+
+	headers := make([]Header, 0, len(m.Headers)+len(envelope))
+	for _, h := range m.Headers {
+		if h.Key != HeaderContentType && !strings.HasPrefix(h.Key, cloudEventHeaderPrefix) {
+			headers = append(headers, h)
+		}
+	}
+
+	contentType := "application/json"
+	var data []byte
+	for name, raw := range envelope {
+		switch name {
+		case "data":
+			data = raw
+		case "data_base64":
+			var encoded string
+			if err := json.Unmarshal(raw, &encoded); err != nil {
+				return m, fmt.Errorf("data_base64: %w", err)
+			}
+			decoded, err := base64.StdEncoding.DecodeString(encoded)
+			if err != nil {
+				return m, fmt.Errorf("data_base64: %w", err)
+			}
+			data = decoded
+		case "datacontenttype":
+			if err := json.Unmarshal(raw, &contentType); err != nil {
+				return m, fmt.Errorf("datacontenttype: %w", err)
+			}
+		default:
+			headers = append(headers, Header{Key: cloudEventHeaderPrefix + name, Value: attributeValue(raw)})
+		}
+	}
+	if data == nil {
+		return m, errors.New("envelope without data")
+	}
+
+	m.Headers = append(headers, Header{Key: HeaderContentType, Value: []byte(contentType)})
+	m.Value = data
+	return m, nil
+}
+
+// attributeValue returns string attributes unquoted and others as JSON text
+func attributeValue(raw json.RawMessage) []byte {
+	var s string
+	if err := json.Unmarshal(raw, &s); err == nil {
+		return []byte(s)
+	}
+	return raw
+}
+
+// cloudEventID identifies a CloudEvent; id is only unique within its source
+func cloudEventID(m Message) string {
+	id, ok := m.Header(HeaderCloudEventID)
+	if !ok || len(id) == 0 {
+		return ""
+	}
+	source, _ := m.Header(HeaderCloudEventSource)
+	return string(source) + "#" + string(id)
+}
@@ -0,0 +1,121 @@
+package kafka
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"order-service-wbtech/internal/model"
+)
+
+func binaryCloudEvent(t *testing.T, id, ceType string, data any) Message {
+	t.Helper()
+
+	b, err := json.Marshal(data)
+	require.NoError(t, err)
+	return Message{
+		Topic: "orders",
+		Value: b,
+		Headers: []Header{
+			{Key: HeaderCloudEventSpecVersion, Value: []byte("1.0")},
+			{Key: HeaderCloudEventID, Value: []byte(id)},
+			{Key: HeaderCloudEventSource, Value: []byte("/shop")},
+			{Key: HeaderCloudEventType, Value: []byte(ceType)},
+			{Key: HeaderContentType, Value: []byte("application/json")},
+		},
+	}
+}
+
+func structuredCloudEvent(t *testing.T, id, ceType string, data any) Message {
+	t.Helper()
+
+	b, err := json.Marshal(map[string]any{
+		"specversion":     "1.0",
+		"id":              id,
+		"source":          "/shop",
+		"type":            ceType,
+		"time":            "2025-01-15T10:00:00Z",
+		"datacontenttype": "application/json",
+		"data":            data,
+	})
+	require.NoError(t, err)
+	return Message{
+		Topic:   "orders",
+		Value:   b,
+		Headers: []Header{{Key: HeaderContentType, Value: []byte(CloudEventsContentType + "; charset=utf-8")}},
+	}
+}
+
+func TestNormalizeCloudEvent_StructuredToBinary(t *testing.T) {
+	order := testOrder("ce")
+	m, err := normalizeCloudEvent(structuredCloudEvent(t, "1", CloudEventOrderCreated, order))
+	require.NoError(t, err)
+
+	var got model.Order
+	require.NoError(t, json.Unmarshal(m.Value, &got))
+	assert.Equal(t, "ce", got.OrderUID)
+
+	ceTime, _ := m.Header("ce_time")
+	assert.Equal(t, "2025-01-15T10:00:00Z", string(ceTime))
+	contentType, _ := m.Header(HeaderContentType)
+	assert.Equal(t, "application/json", string(contentType))
+	assert.Equal(t, EventOrderCreated, eventType(m, ""))
+	assert.Equal(t, "/shop#1", cloudEventID(m))
+}
+
+func TestNormalizeCloudEvent_Validation(t *testing.T) {
+	legacy := testMessage(t, testOrder("legacy"), 0)
+	m, err := normalizeCloudEvent(legacy)
+	require.NoError(t, err)
+	assert.Equal(t, legacy, m, "plain orders pass through unchanged")
+	assert.Empty(t, cloudEventID(m))
+
+	noID := binaryCloudEvent(t, "", CloudEventOrderCreated, testOrder("x"))
+	_, err = normalizeCloudEvent(noID)
+	assert.ErrorContains(t, err, "id")
+
+	badVersion := binaryCloudEvent(t, "1", CloudEventOrderCreated, testOrder("x"))
+	badVersion.Headers[0].Value = []byte("0.3")
+	_, err = normalizeCloudEvent(badVersion)
+	assert.Error(t, err)
+
+	noData := Message{
+		Value:   []byte(`{"specversion":"1.0","id":"1","source":"/shop","type":"t"}`),
+		Headers: []Header{{Key: HeaderContentType, Value: []byte(CloudEventsContentType)}},
+	}
+	_, err = normalizeCloudEvent(noData)
+	assert.Error(t, err)
+}
+
+func TestConsumer_CloudEvents(t *testing.T) {
+	source := NewMemorySource()
+	ingestor := newMemoryIngestor()
+	dlq := NewMemoryDeadLetterWriter()
+	proc := NewProcessor(ingestor, dlq, nil, fastRetry)
+
+	paid := model.StatusEvent{OrderUID: "a", Status: model.StatusPaid, ChangedAt: time.Now()}
+	shipped := model.StatusEvent{OrderUID: "a", Status: model.StatusShipped, ChangedAt: time.Now()}
+	delivered := model.StatusEvent{OrderUID: "a", Status: model.StatusDelivered, ChangedAt: time.Now()}
+
+	source.Publish(
+		structuredCloudEvent(t, "1", CloudEventOrderCreated, testOrder("a")),
+		binaryCloudEvent(t, "2", CloudEventOrderStatusChanged, paid),
+		binaryCloudEvent(t, "3", CloudEventOrderStatusChanged, shipped),
+		// redelivery of an applied event with a new payload is ignored by its id
+		binaryCloudEvent(t, "3", CloudEventOrderStatusChanged, delivered),
+		testMessage(t, testOrder("legacy"), 0),
+		binaryCloudEvent(t, "4", "com.wbtech.order.refunded", paid),
+	)
+
+	runUntilCommitted(t, source, 6, NewConsumer(source, proc, 1).Run)
+
+	assert.Equal(t, model.StatusShipped, ingestor.status("a"))
+	assert.Equal(t, model.StatusCreated, ingestor.status("legacy"))
+
+	require.Len(t, dlq.Letters(), 1)
+	ceType, _ := dlq.Letters()[0].Message.Header(HeaderCloudEventType)
+	assert.Equal(t, "com.wbtech.order.refunded", string(ceType))
+}
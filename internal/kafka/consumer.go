@@ -2,73 +2,142 @@ package kafka
 
 import (
 	"context"
-	"encoding/json"
-	"errors"
 	"log"
 	"sync"
 	"time"
 
-	"github.com/segmentio/kafka-go"
-
-	"order-service-wbtech/internal/model"
-	"order-service-wbtech/internal/service"
-	"order-service-wbtech/internal/validator"
+	"order-service-wbtech/internal/tracing"
 )
 
+// Consumer — fetches messages from a MessageSource, hands them to a Processor
+// and commits offsets once messages are handled
+type Consumer struct {
+	source   MessageSource
+	proc     *Processor
+	workers  int
+	control  *Control
+	throttle *Throttle
+	tuning   Tuning
+}
+
+func NewConsumer(source MessageSource, proc *Processor, workers int) *Consumer {
+	if workers <= 0 {
+		workers = 5
+	}
+	return &Consumer{
+		source:  source,
+		proc:    proc,
+		workers: workers,
+		control: NewControl(),
+		tuning:  DefaultTuning(),
+	}
+}
+
+// WithControl makes the consumer obey control instead of its own Control
+func (c *Consumer) WithControl(control *Control) *Consumer {
+	if control != nil {
+		c.control = control
+	}
+	return c
+}
+
+// WithThrottle limits how fast the consumer fetches messages; nil disables the limit
+func (c *Consumer) WithThrottle(throttle *Throttle) *Consumer {
+	c.throttle = throttle
+	return c
+}
+
+// WithTuning replaces the default worker queue size and fetch retry delay
+func (c *Consumer) WithTuning(tuning Tuning) *Consumer {
+	c.tuning = tuning.withDefaults()
+	return c
+}
+
+// Control returns the pause/resume/drain control of the consumer
+func (c *Consumer) Control() *Control {
+	return c.control
+}
+
 // StartConsumerWithWorkerPool — main consumer startup with worker pool
 func StartConsumerWithWorkerPool(
 	ctx context.Context,
-	brokers []string,
+	cluster Cluster,
 	topic string,
 	groupID string,
-	svc *service.Service,
+	proc *Processor,
 	workerCount int,
+	control *Control,
+	throttle *Throttle,
+	tuning Tuning,
 ) {
-	if workerCount <= 0 {
-		workerCount = 5
-	}
+	source := NewKafkaSource(cluster, topic, groupID, tuning)
+	defer func() {
+		if err := source.Close(); err != nil {
+			log.Printf("Error closing reader: %v", err)
+		}
+	}()
 
-	reader := kafka.NewReader(kafka.ReaderConfig{
-		Brokers:          brokers,
-		GroupID:          groupID,
-		Topic:            topic,
-		MinBytes:         1,
-		MaxBytes:         10e6,
-		ReadBatchTimeout: 5 * time.Second,
-		CommitInterval:   0,
-		Logger:           kafka.LoggerFunc(log.Printf),
-		ErrorLogger:      kafka.LoggerFunc(log.Printf),
-	})
-
-	jobs := make(chan kafka.Message, workerCount*2)
+	log.Printf("Kafka consumer started | topic=%s group=%s workers=%d", topic, groupID, workerCount)
+	NewConsumer(source, proc, workerCount).WithControl(control).WithThrottle(throttle).WithTuning(tuning).Run(ctx)
+}
+
+// Run processes messages with the worker pool until ctx is cancelled
+func (c *Consumer) Run(ctx context.Context) {
+	// each worker owns a queue, messages are routed by key/partition so that
+	// messages of the same key are always processed by the same worker in order
+	queues := make([]chan Message, c.workers)
+	tracker := newOffsetTracker(c.source)
 	var wg sync.WaitGroup
+	workersTotal.Add(float64(c.workers))
+	defer workersTotal.Sub(float64(c.workers))
 
-	for i := 0; i < workerCount; i++ {
+	for i := 0; i < c.workers; i++ {
+		queues[i] = make(chan Message, c.tuning.WorkerQueueSize)
 		wg.Add(1)
 		go func(workerID int) {
 			defer wg.Done()
-			worker(ctx, workerID, jobs, svc, reader)
+			worker(ctx, workerID, queues[workerID], c.proc, tracker, c.control)
 		}(i)
 	}
 
-	log.Printf("Kafka consumer started | topic=%s group=%s workers=%d", topic, groupID, workerCount)
-
 	go func() {
-		defer close(jobs)
+		defer func() {
+			for _, q := range queues {
+				close(q)
+			}
+		}()
 		for {
-			m, err := reader.FetchMessage(ctx)
+			if err := c.throttle.Wait(ctx, 1); err != nil {
+				log.Println("Kafka consumer context cancelled, shutting down...")
+				return
+			}
+
+			fetchCtx, cancelFetch, err := c.control.fetchContext(ctx)
+			if err != nil {
+				log.Println("Kafka consumer context cancelled, shutting down...")
+				return
+			}
+
+			m, err := c.source.FetchMessage(fetchCtx)
+			cancelFetch()
 			if err != nil {
-				if errors.Is(err, context.Canceled) {
+				c.control.done(1)
+				if ctx.Err() != nil {
 					log.Println("Kafka consumer context cancelled, shutting down...")
 					return
 				}
+				if fetchCtx.Err() != nil {
+					// paused while waiting for a message
+					continue
+				}
 				log.Printf("kafka: fetch message error: %v. Retrying...", err)
-				time.Sleep(time.Second)
+				time.Sleep(c.tuning.FetchRetryDelay)
 				continue
 			}
 
+			tracker.track(m)
 			select {
-			case jobs <- m:
+			case queues[workerFor(m, c.workers)] <- m:
 			case <-ctx.Done():
 				return
 			}
@@ -78,46 +147,72 @@ func StartConsumerWithWorkerPool(
 	<-ctx.Done()
 	log.Println("Shutting down Kafka consumer...")
 
-	if err := reader.Close(); err != nil {
-		log.Printf("Error closing reader: %v", err)
-	}
 	wg.Wait()
 	log.Println("Kafka consumer stopped gracefully")
 }
 
-func worker(ctx context.Context, workerID int, jobs <-chan kafka.Message, svc *service.Service, reader *kafka.Reader) {
+// worker handles and commits messages of its queue one by one. A message that
+// could not be handled or committed is retried until it succeeds or the consumer
+// stops, because skipping it would block the commit of its whole partition anyway.
+func worker(ctx context.Context, workerID int, jobs <-chan Message, proc *Processor, tracker *offsetTracker, control *Control) {
 	for msg := range jobs {
-		if err := processMessage(ctx, msg, svc); err != nil {
-			log.Printf("[worker-%d] Failed to process message (offset=%d): %v → will retry later", workerID, msg.Offset, err)
-			continue
+		if !handleMessage(ctx, workerID, proc, msg) {
+			return
 		}
+		observeLag(msg)
 
-		if err := reader.CommitMessages(ctx, msg); err != nil {
-			log.Printf("[worker-%d] Commit failed for offset %d: %v", workerID, msg.Offset, err)
-		} else {
-			log.Printf("[worker-%d] Successfully processed and committed offset=%d", workerID, msg.Offset)
+		committed := commitUntilDone(ctx, proc.retry, func(ctx context.Context) error {
+			commitCtx, span := startMessageSpan(ctx, "commit "+msg.Topic, msg)
+			err := tracker.complete(commitCtx, msg)
+			tracing.End(span, err)
+			if err != nil {
+				log.Printf("[worker-%d] Commit failed for offset=%d partition=%d: %v → retrying", workerID, msg.Offset, msg.Partition, err)
+			}
+			return err
+		})
+		if !committed {
+			return
 		}
+		log.Printf("[worker-%d] Successfully processed offset=%d partition=%d", workerID, msg.Offset, msg.Partition)
+		control.done(1)
 	}
 }
 
-// processMessage processes a single message with all business logic
-func processMessage(ctx context.Context, m kafka.Message, svc *service.Service) error {
-	log.Printf("Processing message offset=%d partition=%d", m.Offset, m.Partition)
-
-	var order model.Order
-	if err := json.Unmarshal(m.Value, &order); err != nil {
-		log.Printf("Invalid JSON (offset %d): %v → skipping (no retry)", m.Offset, err)
-		return nil
+// commitUntilDone retries commit until it succeeds or the consumer stops, and
+// reports whether it succeeded. A message is done only once it is committed:
+// a drained consumer must not hold an offset that was handled but not committed.
+func commitUntilDone(ctx context.Context, retry RetryPolicy, commit func(ctx context.Context) error) bool {
+	for attempt := 1; ; attempt++ {
+		err := commit(ctx)
+		if err == nil {
+			return true
+		}
+		if err := retry.sleep(ctx, attempt); err != nil {
+			return false
+		}
 	}
+}
 
-	if err := validator.ValidateOrder(&order); err != nil {
-		log.Printf("Validation failed for order %s (offset %d): %v → skipping", order.OrderUID, m.Offset, err)
-		return nil
-	}
+// handleMessage retries msg until it is handled or the consumer stops. It reports
+// false when the message must stay uncommitted.
+func handleMessage(ctx context.Context, workerID int, proc *Processor, msg Message) bool {
+	workersBusy.Inc()
+	defer workersBusy.Dec()
 
-	if err := svc.CreateOrder(ctx, &order); err != nil {
-		return err
-	}
+	for attempt := 1; ; attempt++ {
+		_, err := proc.Handle(ctx, msg)
+		if err == nil {
+			return true
+		}
+		if ctx.Err() != nil {
+			log.Printf("[worker-%d] Stopped before offset=%d partition=%d was handled → not committed", workerID, msg.Offset, msg.Partition)
+			return false
+		}
 
-	return nil
+		handleErrors.WithLabelValues(msg.Topic).Inc()
+		log.Printf("[worker-%d] Failed to handle offset=%d partition=%d: %v → retrying", workerID, msg.Offset, msg.Partition, err)
+		if err := proc.retry.sleep(ctx, attempt); err != nil {
+			return false
+		}
+	}
 }
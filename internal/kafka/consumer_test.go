@@ -0,0 +1,223 @@
+package kafka
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"order-service-wbtech/internal/model"
+)
+
+// memoryIngestor — OrderIngestor that keeps orders in a map
+type memoryIngestor struct {
+	mu     sync.Mutex
+	orders map[string]*model.Order
+	// failures is the number of CreateOrder calls to fail per order_uid
+	failures map[string]int
+	// events are the applied event IDs
+	events map[string]bool
+}
+
+func newMemoryIngestor() *memoryIngestor {
+	return &memoryIngestor{
+		orders:   make(map[string]*model.Order),
+		failures: make(map[string]int),
+		events:   make(map[string]bool),
+	}
+}
+
+// applyEvent records the event ID of the change in ctx, reporting duplicates
+func (i *memoryIngestor) applyEvent(ctx context.Context, orderUID string) error {
+	src, _ := model.EventSourceFrom(ctx, orderUID)
+	if src.EventID == "" {
+		return nil
+	}
+	if i.events[src.EventID] {
+		return model.ErrDuplicateEvent
+	}
+	i.events[src.EventID] = true
+	return nil
+}
+
+func (i *memoryIngestor) CreateOrder(ctx context.Context, order *model.Order) error {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	if i.failures[order.OrderUID] > 0 {
+		i.failures[order.OrderUID]--
+		return context.DeadlineExceeded
+	}
+	if _, ok := i.orders[order.OrderUID]; ok {
+		return model.ErrOrderExists
+	}
+	if err := i.applyEvent(ctx, order.OrderUID); err != nil {
+		return err
+	}
+	if order.Status == "" {
+		order.Status = model.StatusCreated
+	}
+	i.orders[order.OrderUID] = order
+	return nil
+}
+
+func (i *memoryIngestor) CreateOrders(ctx context.Context, orders []*model.Order) error {
+	for _, o := range orders {
+		if err := i.CreateOrder(ctx, o); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (i *memoryIngestor) UpdateOrderStatus(ctx context.Context, event *model.StatusEvent) error {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	order, ok := i.orders[event.OrderUID]
+	if !ok {
+		return fmt.Errorf("order %s: %w", event.OrderUID, model.ErrNotFound)
+	}
+	if err := i.applyEvent(ctx, event.OrderUID); err != nil {
+		return err
+	}
+	if order.Status == event.Status {
+		return nil
+	}
+	if !order.Status.CanTransitionTo(event.Status) {
+		return model.ErrInvalidTransition
+	}
+	order.Status = event.Status
+	return nil
+}
+
+func (i *memoryIngestor) status(orderUID string) model.OrderStatus {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	if order, ok := i.orders[orderUID]; ok {
+		return order.Status
+	}
+	return ""
+}
+
+func (i *memoryIngestor) count() int {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	return len(i.orders)
+}
+
+// runUntilCommitted runs fn until partition 0 of "orders" is committed up to offset
+func runUntilCommitted(t *testing.T, source *MemorySource, offset int64, fn func(ctx context.Context)) {
+	t.Helper()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		fn(ctx)
+	}()
+
+	require.Eventually(t, func() bool {
+		return source.Committed("orders", 0) == offset
+	}, 2*time.Second, 5*time.Millisecond)
+
+	cancel()
+	<-done
+}
+
+func TestConsumer_Pipeline(t *testing.T) {
+	source := NewMemorySource()
+	ingestor := newMemoryIngestor()
+	ingestor.failures["flaky"] = 1
+	dlq := NewMemoryDeadLetterWriter()
+	proc := NewProcessor(ingestor, dlq, nil, fastRetry)
+
+	source.Publish(
+		testMessage(t, testOrder("a"), 0),
+		Message{Topic: "orders", Value: []byte("not json")},
+		testMessage(t, testOrder("flaky"), 0),
+		testMessage(t, testOrder("a"), 0),
+		testMessage(t, testOrder("b"), 0),
+	)
+
+	runUntilCommitted(t, source, 5, NewConsumer(source, proc, 3).Run)
+
+	assert.Equal(t, 3, ingestor.count())
+	require.Len(t, dlq.Letters(), 1)
+	assert.Equal(t, StageDecode, dlq.Letters()[0].Stage)
+	assert.Equal(t, int64(1), dlq.Letters()[0].Message.Offset)
+}
+
+func TestConsumer_BatchPipeline(t *testing.T) {
+	source := NewMemorySource()
+	ingestor := newMemoryIngestor()
+	dlq := NewMemoryDeadLetterWriter()
+	proc := NewProcessor(ingestor, dlq, nil, fastRetry)
+
+	source.Publish(
+		testMessage(t, testOrder("a"), 0),
+		testMessage(t, testOrder("b"), 0),
+		Message{Topic: "orders", Value: []byte(`{"order_uid":"invalid"}`)},
+		testMessage(t, testOrder("c"), 0),
+	)
+
+	runUntilCommitted(t, source, 4, func(ctx context.Context) {
+		NewConsumer(source, proc, 1).RunBatch(ctx, 3, 20*time.Millisecond)
+	})
+
+	assert.Equal(t, 3, ingestor.count())
+	require.Len(t, dlq.Letters(), 1)
+	assert.Equal(t, StageValidate, dlq.Letters()[0].Stage)
+}
+
+func statusMessage(t *testing.T, orderUID string, status model.OrderStatus) Message {
+	t.Helper()
+
+	b, err := json.Marshal(model.StatusEvent{OrderUID: orderUID, Status: status, ChangedAt: time.Now()})
+	require.NoError(t, err)
+	return Message{
+		Topic:   "orders",
+		Key:     []byte(orderUID),
+		Value:   b,
+		Headers: []Header{{Key: HeaderEventType, Value: []byte(EventOrderStatusChanged)}},
+	}
+}
+
+func TestConsumer_StatusLifecycle(t *testing.T) {
+	source := NewMemorySource()
+	ingestor := newMemoryIngestor()
+	dlq := NewMemoryDeadLetterWriter()
+	parking := NewMemoryDeadLetterWriter()
+	proc := NewProcessor(ingestor, dlq, parking, fastRetry)
+
+	source.Publish(
+		testMessage(t, testOrder("a"), 0),
+		statusMessage(t, "a", model.StatusPaid),
+		statusMessage(t, "a", model.StatusPaid),
+		statusMessage(t, "a", model.StatusDelivered),
+		statusMessage(t, "a", model.StatusShipped),
+		statusMessage(t, "missing", model.StatusPaid),
+		statusMessage(t, "a", "lost"),
+	)
+
+	runUntilCommitted(t, source, 7, NewConsumer(source, proc, 2).Run)
+
+	assert.Equal(t, model.StatusShipped, ingestor.status("a"))
+
+	parked := map[int64]bool{}
+	for _, dl := range parking.Letters() {
+		parked[dl.Message.Offset] = true
+	}
+	assert.Equal(t, map[int64]bool{3: true, 5: true}, parked, "paid → delivered and unknown order are parked")
+
+	require.Len(t, dlq.Letters(), 1)
+	assert.Equal(t, int64(6), dlq.Letters()[0].Message.Offset)
+	assert.Equal(t, StageValidate, dlq.Letters()[0].Stage)
+}
@@ -0,0 +1,185 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrDrainInterrupted — the consumer was resumed before the drain finished
+var ErrDrainInterrupted = errors.New("drain interrupted by resume")
+
+// ConsumerState — whether the consumer fetches new messages
+type ConsumerState string
+
+const (
+	StateRunning ConsumerState = "running"
+	// StatePaused — no new messages are fetched, in-flight ones are still finished
+	StatePaused ConsumerState = "paused"
+	// StateDraining — paused and waiting for in-flight messages to be committed
+	StateDraining ConsumerState = "draining"
+	// StateDrained — paused with nothing in flight, everything fetched is committed
+	StateDrained ConsumerState = "drained"
+)
+
+// ConsumerStatus — snapshot of a Control
+type ConsumerStatus struct {
+	State    ConsumerState `json:"state"`
+	InFlight int           `json:"in_flight"`
+	Since    time.Time     `json:"since"`
+}
+
+// Control pauses, resumes and drains one or more Consumers while they run. A
+// fetch in progress counts as in flight, so a drained consumer holds no message
+// that is not committed.
+type Control struct {
+	mu       sync.Mutex
+	state    ConsumerState
+	since    time.Time
+	inFlight int
+	// running is closed while the state is running
+	running chan struct{}
+	// paused is cancelled by Pause to interrupt fetches waiting for messages
+	paused      context.Context
+	cancelPause context.CancelFunc
+	drained     []chan error
+}
+
+func NewControl() *Control {
+	running := make(chan struct{})
+	close(running)
+	paused, cancelPause := context.WithCancel(context.Background())
+	return &Control{
+		state:       StateRunning,
+		since:       time.Now(),
+		running:     running,
+		paused:      paused,
+		cancelPause: cancelPause,
+	}
+}
+
+// Pause stops fetching; fetches that are waiting for a message are cancelled
+func (c *Control) Pause() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.pause()
+}
+
+func (c *Control) pause() {
+	if c.state != StateRunning {
+		return
+	}
+	c.setState(StatePaused)
+	c.running = make(chan struct{})
+	c.cancelPause()
+}
+
+// Resume continues fetching after Pause or Drain
+func (c *Control) Resume() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.state == StateRunning {
+		return
+	}
+	c.setState(StateRunning)
+	close(c.running)
+	c.paused, c.cancelPause = context.WithCancel(context.Background())
+	c.releaseDrained(ErrDrainInterrupted)
+}
+
+// Drain pauses the consumer and waits until every in-flight message has been
+// handled and committed, or ctx is done
+func (c *Control) Drain(ctx context.Context) error {
+	c.mu.Lock()
+	c.pause()
+	if c.inFlight == 0 {
+		if c.state != StateDrained {
+			c.setState(StateDrained)
+		}
+		c.mu.Unlock()
+		return nil
+	}
+	if c.state != StateDraining {
+		c.setState(StateDraining)
+	}
+	done := make(chan error, 1)
+	c.drained = append(c.drained, done)
+	c.mu.Unlock()
+
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Status reports the current state
+func (c *Control) Status() ConsumerStatus {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return ConsumerStatus{State: c.state, InFlight: c.inFlight, Since: c.since}
+}
+
+// fetchContext waits until the consumer runs and returns a context for one fetch
+// that is cancelled by Pause. The fetch counts as in flight until it is released
+// with done or turned into a message.
+func (c *Control) fetchContext(ctx context.Context) (context.Context, context.CancelFunc, error) {
+	for {
+		c.mu.Lock()
+		if c.state == StateRunning {
+			fetchCtx, cancel := context.WithCancel(ctx)
+			stop := context.AfterFunc(c.paused, cancel)
+			c.inFlight++
+			c.mu.Unlock()
+			return fetchCtx, func() {
+				stop()
+				cancel()
+			}, nil
+		}
+		running := c.running
+		c.mu.Unlock()
+
+		select {
+		case <-running:
+		case <-ctx.Done():
+			return nil, nil, ctx.Err()
+		}
+	}
+}
+
+// begin adds n messages to the in-flight count
+func (c *Control) begin(n int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.inFlight += n
+}
+
+// done removes n finished messages or fetches from the in-flight count
+func (c *Control) done(n int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.inFlight -= n
+	if c.inFlight == 0 && c.state == StateDraining {
+		c.setState(StateDrained)
+		c.releaseDrained(nil)
+	}
+}
+
+func (c *Control) releaseDrained(err error) {
+	for _, ch := range c.drained {
+		ch <- err
+	}
+	c.drained = nil
+}
+
+func (c *Control) setState(state ConsumerState) {
+	c.state = state
+	c.since = time.Now()
+}
@@ -0,0 +1,199 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// startConsumer runs fn in the background and stops it when the test ends
+func startConsumer(t *testing.T, fn func(ctx context.Context)) {
+	t.Helper()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		fn(ctx)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		<-done
+	})
+}
+
+func TestControl_PauseAndResume(t *testing.T) {
+	source := NewMemorySource()
+	ingestor := newMemoryIngestor()
+	proc := NewProcessor(ingestor, nil, nil, fastRetry)
+	consumer := NewConsumer(source, proc, 2)
+	control := consumer.Control()
+
+	startConsumer(t, consumer.Run)
+
+	source.Publish(testMessage(t, testOrder("a"), 0))
+	require.Eventually(t, func() bool { return source.Committed("orders", 0) == 1 }, 2*time.Second, 5*time.Millisecond)
+
+	control.Pause()
+	assert.Equal(t, StatePaused, control.Status().State)
+	// the fetch waiting for a message is cancelled
+	require.Eventually(t, func() bool { return control.Status().InFlight == 0 }, time.Second, time.Millisecond)
+
+	source.Publish(testMessage(t, testOrder("b"), 0))
+	time.Sleep(50 * time.Millisecond)
+	assert.Equal(t, int64(1), source.Committed("orders", 0))
+	assert.Equal(t, 1, ingestor.count())
+
+	control.Resume()
+	assert.Equal(t, StateRunning, control.Status().State)
+	require.Eventually(t, func() bool { return source.Committed("orders", 0) == 2 }, 2*time.Second, 5*time.Millisecond)
+	assert.Equal(t, 2, ingestor.count())
+}
+
+func TestControl_DrainCommitsInFlightMessages(t *testing.T) {
+	source := NewMemorySource()
+	ingestor := newMemoryIngestor()
+	ingestor.failures["flaky"] = 3
+	proc := NewProcessor(ingestor, nil, nil, RetryPolicy{
+		MaxAttempts:    5,
+		InitialBackoff: 20 * time.Millisecond,
+		MaxBackoff:     20 * time.Millisecond,
+	})
+	consumer := NewConsumer(source, proc, 2)
+	control := consumer.Control()
+
+	startConsumer(t, consumer.Run)
+
+	source.Publish(testMessage(t, testOrder("flaky"), 0))
+	// wait until the first attempt failed and the message is being retried
+	require.Eventually(t, func() bool {
+		ingestor.mu.Lock()
+		defer ingestor.mu.Unlock()
+		return ingestor.failures["flaky"] < 3
+	}, 2*time.Second, time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	require.NoError(t, control.Drain(ctx))
+
+	assert.Equal(t, int64(1), source.Committed("orders", 0))
+	assert.Equal(t, 1, ingestor.count())
+	assert.Equal(t, StateDrained, control.Status().State)
+	assert.Equal(t, 0, control.Status().InFlight)
+
+	// nothing is fetched while drained
+	source.Publish(testMessage(t, testOrder("b"), 0))
+	time.Sleep(50 * time.Millisecond)
+	assert.Equal(t, 1, ingestor.count())
+}
+
+// flakyCommitSource — MemorySource whose first commits fail
+type flakyCommitSource struct {
+	*MemorySource
+
+	mu    sync.Mutex
+	fails int
+}
+
+func (s *flakyCommitSource) CommitMessages(ctx context.Context, msgs ...Message) error {
+	s.mu.Lock()
+	if s.fails > 0 {
+		s.fails--
+		s.mu.Unlock()
+		return errors.New("coordinator not available")
+	}
+	s.mu.Unlock()
+	return s.MemorySource.CommitMessages(ctx, msgs...)
+}
+
+func TestControl_DrainWaitsForFailedCommit(t *testing.T) {
+	for name, batch := range map[string]bool{"workers": false, "batch": true} {
+		t.Run(name, func(t *testing.T) {
+			source := &flakyCommitSource{MemorySource: NewMemorySource(), fails: 2}
+			proc := NewProcessor(newMemoryIngestor(), nil, nil, fastRetry)
+			consumer := NewConsumer(source, proc, 1)
+			control := consumer.Control()
+
+			if batch {
+				startConsumer(t, func(ctx context.Context) { consumer.RunBatch(ctx, 10, 20*time.Millisecond) })
+			} else {
+				startConsumer(t, consumer.Run)
+			}
+			source.Publish(testMessage(t, testOrder("a"), 0))
+			// wait until the first commit failed
+			require.Eventually(t, func() bool {
+				source.mu.Lock()
+				defer source.mu.Unlock()
+				return source.fails < 2
+			}, 2*time.Second, time.Millisecond)
+			assert.Positive(t, control.Status().InFlight, "a message with a failed commit is still in flight")
+
+			ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+			defer cancel()
+			require.NoError(t, control.Drain(ctx))
+
+			assert.Equal(t, int64(1), source.Committed("orders", 0), "drained only after the commit went through")
+			assert.Equal(t, 0, control.Status().InFlight)
+		})
+	}
+}
+
+func TestControl_DrainBatchConsumer(t *testing.T) {
+	source := NewMemorySource()
+	ingestor := newMemoryIngestor()
+	proc := NewProcessor(ingestor, nil, nil, fastRetry)
+	consumer := NewConsumer(source, proc, 1)
+	control := consumer.Control()
+
+	startConsumer(t, func(ctx context.Context) { consumer.RunBatch(ctx, 10, 20*time.Millisecond) })
+
+	source.Publish(
+		testMessage(t, testOrder("a"), 0),
+		testMessage(t, testOrder("b"), 0),
+	)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	require.NoError(t, control.Drain(ctx))
+
+	assert.Equal(t, StateDrained, control.Status().State)
+	assert.Equal(t, 0, control.Status().InFlight)
+	assert.Equal(t, source.Committed("orders", 0), int64(ingestor.count()))
+
+	control.Resume()
+	require.Eventually(t, func() bool { return source.Committed("orders", 0) == 2 }, 2*time.Second, 5*time.Millisecond)
+}
+
+func TestControl_ResumeInterruptsDrain(t *testing.T) {
+	control := NewControl()
+	control.begin(1)
+
+	result := make(chan error, 1)
+	go func() { result <- control.Drain(context.Background()) }()
+
+	require.Eventually(t, func() bool { return control.Status().State == StateDraining }, time.Second, time.Millisecond)
+	control.Resume()
+
+	assert.ErrorIs(t, <-result, ErrDrainInterrupted)
+	assert.Equal(t, StateRunning, control.Status().State)
+	assert.Equal(t, 1, control.Status().InFlight)
+}
+
+func TestControl_DrainTimeout(t *testing.T) {
+	control := NewControl()
+	control.begin(1)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	assert.ErrorIs(t, control.Drain(ctx), context.DeadlineExceeded)
+	assert.Equal(t, StateDraining, control.Status().State)
+
+	control.done(1)
+	assert.Equal(t, StateDrained, control.Status().State)
+}
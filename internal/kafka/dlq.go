@@ -0,0 +1,128 @@
+package kafka
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+
+	"order-service-wbtech/internal/model"
+)
+
+// Headers attached to every dead-lettered message so it can be inspected and replayed
+const (
+	HeaderDLQReason            = "x-dlq-reason"
+	HeaderDLQStage             = "x-dlq-stage"
+	HeaderDLQOriginalTopic     = "x-dlq-original-topic"
+	HeaderDLQOriginalPartition = "x-dlq-original-partition"
+	HeaderDLQOriginalOffset    = "x-dlq-original-offset"
+	HeaderDLQFailedAt          = "x-dlq-failed-at"
+	HeaderDLQAttempts          = "x-dlq-attempts"
+)
+
+// Stages at which a message can be rejected
+const (
+	StageDecode   = "decode"
+	StageValidate = "validate"
+	StageSave     = "save"
+)
+
+// DeadLetter — a message that could not be processed together with the failure reason
+type DeadLetter struct {
+	Message  Message
+	Stage    string
+	Reason   string
+	Attempts int
+	FailedAt time.Time
+	// FieldErrors are the fields that failed validation at the validate stage
+	FieldErrors []model.FieldError
+}
+
+// DeadLetterWriter publishes messages that must not be retried
+type DeadLetterWriter interface {
+	WriteDeadLetter(ctx context.Context, dl DeadLetter) error
+}
+
+// KafkaDeadLetterWriter republishes dead letters to a dedicated Kafka topic
+type KafkaDeadLetterWriter struct {
+	writer *kafka.Writer
+}
+
+// NewKafkaDeadLetterWriter creates a writer for the given dead-letter topic
+func NewKafkaDeadLetterWriter(cluster Cluster, topic string) *KafkaDeadLetterWriter {
+	return &KafkaDeadLetterWriter{
+		writer: &kafka.Writer{
+			Addr:         kafka.TCP(cluster.Brokers...),
+			Transport:    cluster.Transport(),
+			Topic:        topic,
+			Balancer:     &kafka.Hash{},
+			RequiredAcks: kafka.RequireAll,
+			BatchTimeout: 10 * time.Millisecond,
+		},
+	}
+}
+
+func (w *KafkaDeadLetterWriter) WriteDeadLetter(ctx context.Context, dl DeadLetter) error {
+	msg := kafka.Message{
+		Key:     dl.Message.Key,
+		Value:   dl.Message.Value,
+		Headers: toKafkaHeaders(deadLetterHeaders(dl)),
+		Time:    dl.FailedAt,
+	}
+
+	if err := w.writer.WriteMessages(ctx, msg); err != nil {
+		return fmt.Errorf("write dead letter to %s: %w", w.writer.Topic, err)
+	}
+	return nil
+}
+
+func (w *KafkaDeadLetterWriter) Close() error {
+	return w.writer.Close()
+}
+
+// deadLetterHeaders keeps the original headers and appends failure metadata
+func deadLetterHeaders(dl DeadLetter) []Header {
+	headers := make([]Header, 0, len(dl.Message.Headers)+7)
+	headers = append(headers, dl.Message.Headers...)
+	headers = append(headers,
+		Header{Key: HeaderDLQReason, Value: []byte(dl.Reason)},
+		Header{Key: HeaderDLQStage, Value: []byte(dl.Stage)},
+		Header{Key: HeaderDLQOriginalTopic, Value: []byte(dl.Message.Topic)},
+		Header{Key: HeaderDLQOriginalPartition, Value: []byte(strconv.Itoa(dl.Message.Partition))},
+		Header{Key: HeaderDLQOriginalOffset, Value: []byte(strconv.FormatInt(dl.Message.Offset, 10))},
+		Header{Key: HeaderDLQFailedAt, Value: []byte(dl.FailedAt.UTC().Format(time.RFC3339Nano))},
+		Header{Key: HeaderDLQAttempts, Value: []byte(strconv.Itoa(dl.Attempts))},
+	)
+	return headers
+}
+
+// MemoryDeadLetterWriter keeps dead letters in memory, used in tests
+type MemoryDeadLetterWriter struct {
+	mu      sync.Mutex
+	letters []DeadLetter
+}
+
+func NewMemoryDeadLetterWriter() *MemoryDeadLetterWriter {
+	return &MemoryDeadLetterWriter{}
+}
+
+func (w *MemoryDeadLetterWriter) WriteDeadLetter(_ context.Context, dl DeadLetter) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.letters = append(w.letters, dl)
+	return nil
+}
+
+// Letters returns a copy of all dead letters written so far
+func (w *MemoryDeadLetterWriter) Letters() []DeadLetter {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	out := make([]DeadLetter, len(w.letters))
+	copy(out, w.letters)
+	return out
+}
@@ -0,0 +1,55 @@
+package kafka
+
+import (
+	"mime"
+
+	"order-service-wbtech/internal/codec"
+)
+
+// HeaderEventType selects how a message payload is interpreted
+const HeaderEventType = "event_type"
+
+// HeaderMessageFormat declares the serialization of an order payload: json, avro
+// or protobuf. Without it the Confluent wire format or the configured default decides.
+const HeaderMessageFormat = "message_format"
+
+// Supported event types. Messages without the header get the default event of
+// their topic's processor, order_created unless configured otherwise, so existing
+// producers keep working.
+const (
+	EventOrderCreated       = "order_created"
+	EventOrderStatusChanged = "order_status_changed"
+	EventPaymentConfirmed   = "payment_confirmed"
+)
+
+// eventType returns the event type of a message from its CloudEvents type or,
+// for legacy messages, from the event_type header; def if neither is set
+func eventType(m Message, def string) string {
+	if ceType, ok := m.Header(HeaderCloudEventType); ok {
+		if t, known := cloudEventTypes[string(ceType)]; known {
+			return t
+		}
+		return string(ceType)
+	}
+
+	v, ok := m.Header(HeaderEventType)
+	if !ok || len(v) == 0 {
+		return def
+	}
+	return string(v)
+}
+
+// messageFormat returns the format declared by the message_format header or the
+// content type of the data, empty if neither tells it
+func messageFormat(m Message) (codec.Format, error) {
+	if v, ok := m.Header(HeaderMessageFormat); ok && len(v) > 0 {
+		return codec.ParseFormat(string(v))
+	}
+
+	if ct, ok := m.Header(HeaderContentType); ok {
+		if mediaType, _, err := mime.ParseMediaType(string(ct)); err == nil {
+			return contentTypeFormats[mediaType], nil
+		}
+	}
+	return "", nil
+}
@@ -0,0 +1,91 @@
+package kafka
+
+import (
+	"context"
+	"log"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// KafkaSource — MessageSource backed by a kafka-go consumer group reader
+type KafkaSource struct {
+	reader *kafka.Reader
+}
+
+func NewKafkaSource(cluster Cluster, topic, groupID string, tuning Tuning) *KafkaSource {
+	tuning = tuning.withDefaults()
+	return &KafkaSource{
+		reader: kafka.NewReader(kafka.ReaderConfig{
+			Brokers:          cluster.Brokers,
+			Dialer:           cluster.Dialer(),
+			GroupID:          groupID,
+			Topic:            topic,
+			MinBytes:         tuning.MinBytes,
+			MaxBytes:         tuning.MaxBytes,
+			MaxWait:          tuning.MaxWait,
+			ReadBatchTimeout: tuning.ReadBatchTimeout,
+			QueueCapacity:    tuning.QueueCapacity,
+			CommitInterval:   0,
+			Logger:           kafka.LoggerFunc(log.Printf),
+			ErrorLogger:      kafka.LoggerFunc(log.Printf),
+		}),
+	}
+}
+
+func (s *KafkaSource) FetchMessage(ctx context.Context) (Message, error) {
+	m, err := s.reader.FetchMessage(ctx)
+	if err != nil {
+		return Message{}, err
+	}
+	return fromKafkaMessage(m), nil
+}
+
+func (s *KafkaSource) CommitMessages(ctx context.Context, msgs ...Message) error {
+	out := make([]kafka.Message, len(msgs))
+	for i, m := range msgs {
+		out[i] = toKafkaMessage(m)
+	}
+	return s.reader.CommitMessages(ctx, out...)
+}
+
+func (s *KafkaSource) Close() error {
+	return s.reader.Close()
+}
+
+func fromKafkaMessage(m kafka.Message) Message {
+	headers := make([]Header, len(m.Headers))
+	for i, h := range m.Headers {
+		headers[i] = Header{Key: h.Key, Value: h.Value}
+	}
+
+	return Message{
+		Topic:         m.Topic,
+		Partition:     m.Partition,
+		Offset:        m.Offset,
+		HighWaterMark: m.HighWaterMark,
+		Key:           m.Key,
+		Value:         m.Value,
+		Headers:       headers,
+		Time:          m.Time,
+	}
+}
+
+func toKafkaMessage(m Message) kafka.Message {
+	return kafka.Message{
+		Topic:     m.Topic,
+		Partition: m.Partition,
+		Offset:    m.Offset,
+		Key:       m.Key,
+		Value:     m.Value,
+		Headers:   toKafkaHeaders(m.Headers),
+		Time:      m.Time,
+	}
+}
+
+func toKafkaHeaders(headers []Header) []kafka.Header {
+	out := make([]kafka.Header, len(headers))
+	for i, h := range headers {
+		out[i] = kafka.Header{Key: h.Key, Value: h.Value}
+	}
+	return out
+}
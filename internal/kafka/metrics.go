@@ -0,0 +1,83 @@
+package kafka
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// Consumer metrics, exported through the default Prometheus registry
+var (
+	consumerLag = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "order_consumer_lag",
+		Help: "Messages between the last handled offset and the partition high watermark.",
+	}, []string{"topic", "partition"})
+
+	messagesHandled = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "order_consumer_messages_total",
+		Help: "Handled messages by outcome: inserted, status_updated, duplicate, invalid_payload, invalid_order or parked.",
+	}, []string{"topic", "outcome"})
+
+	handleErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "order_consumer_handle_errors_total",
+		Help: "Messages or batches that could not be handled and will be retried.",
+	}, []string{"topic"})
+
+	handleDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "order_consumer_handle_duration_seconds",
+		Help:    "Time to handle a single message, including retries; a message saved in a batch counts the whole batch.",
+		Buckets: prometheus.ExponentialBuckets(0.001, 2, 15),
+	}, []string{"topic", "outcome"})
+
+	batchDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "order_consumer_batch_duration_seconds",
+		Help:    "Time to handle a batch of messages in batch mode.",
+		Buckets: prometheus.ExponentialBuckets(0.005, 2, 15),
+	}, []string{"topic"})
+
+	workersTotal = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "order_consumer_workers",
+		Help: "Workers of all running consumers.",
+	})
+
+	workersBusy = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "order_consumer_workers_busy",
+		Help: "Workers currently handling a message.",
+	})
+
+	throttleRate = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "order_consumer_rate_limit",
+		Help: "Current ingestion limit in messages per second, 0 when unlimited.",
+	})
+
+	throttleLevel = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "order_consumer_backpressure_level",
+		Help: "Backpressure from the database: 0 normal, 1 slowed, 2 paused.",
+	})
+
+	outboxPublished = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "order_outbox_published_total",
+		Help: "Outbox events published to Kafka and marked sent.",
+	})
+
+	outboxErrors = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "order_outbox_errors_total",
+		Help: "Outbox relay runs that failed and will be retried.",
+	})
+)
+
+// observeLag records how far the partition of m is ahead of m
+func observeLag(m Message) {
+	lag := m.HighWaterMark - m.Offset - 1
+	if lag < 0 {
+		lag = 0
+	}
+	consumerLag.WithLabelValues(m.Topic, strconv.Itoa(m.Partition)).Set(float64(lag))
+}
+
+func observeOutcome(m Message, outcome Outcome, started time.Time) {
+	messagesHandled.WithLabelValues(m.Topic, string(outcome)).Inc()
+	handleDuration.WithLabelValues(m.Topic, string(outcome)).Observe(time.Since(started).Seconds())
+}
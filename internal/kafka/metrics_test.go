@@ -0,0 +1,85 @@
+package kafka
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"order-service-wbtech/internal/mocks"
+	"order-service-wbtech/internal/service"
+)
+
+func TestConsumer_Metrics(t *testing.T) {
+	const topic = "metrics-orders"
+
+	source := NewMemorySource()
+	ingestor := newMemoryIngestor()
+	proc := NewProcessor(ingestor, NewMemoryDeadLetterWriter(), nil, fastRetry)
+
+	order := testMessage(t, testOrder("a"), 0)
+	order.Topic = topic
+	source.Publish(order, order, Message{Topic: topic, Value: []byte("not json")})
+
+	handled := func(outcome Outcome) float64 {
+		return testutil.ToFloat64(messagesHandled.WithLabelValues(topic, string(outcome)))
+	}
+	inserted, duplicate, invalid := handled(OutcomeInserted), handled(OutcomeDuplicate), handled(OutcomeInvalidPayload)
+	workers := testutil.ToFloat64(workersTotal)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		NewConsumer(source, proc, 2).Run(ctx)
+	}()
+	require.Eventually(t, func() bool {
+		return source.Committed(topic, 0) == 3
+	}, 2*time.Second, 5*time.Millisecond)
+	assert.Equal(t, workers+2, testutil.ToFloat64(workersTotal))
+	cancel()
+	<-done
+
+	assert.Equal(t, inserted+1, handled(OutcomeInserted))
+	assert.Equal(t, duplicate+1, handled(OutcomeDuplicate))
+	assert.Equal(t, invalid+1, handled(OutcomeInvalidPayload))
+	assert.Equal(t, 0.0, testutil.ToFloat64(consumerLag.WithLabelValues(topic, "0")))
+	assert.Equal(t, workers, testutil.ToFloat64(workersTotal))
+	assert.Equal(t, 0.0, testutil.ToFloat64(workersBusy))
+}
+
+func TestHandleBatch_Metrics(t *testing.T) {
+	const topic = "batch-metrics-orders"
+
+	dbMock := &mocks.Storage{}
+	cacheMock := &mocks.Cache{}
+	proc := NewProcessor(service.New(cacheMock, dbMock), NewMemoryDeadLetterWriter(), nil, fastRetry)
+	dbMock.On("SaveOrders", mock.Anything, mock.Anything).Return(nil)
+	cacheMock.On("Set", mock.Anything).Return()
+
+	batch := []Message{
+		testMessage(t, testOrder("a"), 1),
+		{Offset: 2, Value: []byte("garbage")},
+		testMessage(t, testOrder("b"), 3),
+	}
+	for i := range batch {
+		batch[i].Topic = topic
+	}
+	series := testutil.CollectAndCount(handleDuration)
+
+	_, err := proc.HandleBatch(context.Background(), batch)
+	require.NoError(t, err)
+
+	assert.Equal(t, 2.0, testutil.ToFloat64(messagesHandled.WithLabelValues(topic, string(OutcomeInserted))))
+	assert.Equal(t, 1.0, testutil.ToFloat64(messagesHandled.WithLabelValues(topic, string(OutcomeInvalidPayload))))
+	assert.Equal(t, series+2, testutil.CollectAndCount(handleDuration), "batched and single messages are both timed")
+}
+
+func TestObserveLag(t *testing.T) {
+	observeLag(Message{Topic: "lag-orders", Partition: 1, Offset: 10, HighWaterMark: 15})
+	assert.Equal(t, 4.0, testutil.ToFloat64(consumerLag.WithLabelValues("lag-orders", "1")))
+}
@@ -0,0 +1,127 @@
+package kafka
+
+import (
+	"context"
+	"hash/fnv"
+	"sync"
+)
+
+// messageCommitter is implemented by every MessageSource
+type messageCommitter interface {
+	CommitMessages(ctx context.Context, msgs ...Message) error
+}
+
+type topicPartition struct {
+	topic     string
+	partition int
+}
+
+type pendingMessage struct {
+	msg  Message
+	done bool
+}
+
+// offsetTracker remembers fetched messages per partition and only advances the
+// committed offset past messages that were completed contiguously, so a slow or
+// failing message is never skipped by a later offset of the same partition.
+type offsetTracker struct {
+	committer messageCommitter
+
+	mu      sync.Mutex
+	pending map[topicPartition][]*pendingMessage
+
+	commitMu  sync.Mutex
+	committed map[topicPartition]int64
+}
+
+func newOffsetTracker(committer messageCommitter) *offsetTracker {
+	return &offsetTracker{
+		committer: committer,
+		pending:   make(map[topicPartition][]*pendingMessage),
+		committed: make(map[topicPartition]int64),
+	}
+}
+
+// track registers a fetched message. Messages must be tracked in fetch order.
+func (t *offsetTracker) track(m Message) {
+	tp := topicPartition{m.Topic, m.Partition}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.pending[tp] = append(t.pending[tp], &pendingMessage{msg: m})
+}
+
+// complete marks m as processed and commits its partition up to the last
+// contiguously processed message, if that moved forward. Messages leave the
+// tracker only once committed, so calling complete again after an error
+// retries the commit.
+func (t *offsetTracker) complete(ctx context.Context, m Message) error {
+	last, ok := t.markDone(m)
+	if !ok {
+		return nil
+	}
+
+	t.commitMu.Lock()
+	defer t.commitMu.Unlock()
+
+	tp := topicPartition{last.Topic, last.Partition}
+	if committed, seen := t.committed[tp]; !seen || committed < last.Offset {
+		if err := t.committer.CommitMessages(ctx, last); err != nil {
+			return err
+		}
+		t.committed[tp] = last.Offset
+	}
+	t.forget(tp, last.Offset)
+	return nil
+}
+
+// markDone returns the highest message whose predecessors are all done
+func (t *offsetTracker) markDone(m Message) (Message, bool) {
+	tp := topicPartition{m.Topic, m.Partition}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	queue := t.pending[tp]
+	for _, p := range queue {
+		if p.msg.Offset == m.Offset {
+			p.done = true
+			break
+		}
+	}
+
+	n := 0
+	for n < len(queue) && queue[n].done {
+		n++
+	}
+	if n == 0 {
+		return Message{}, false
+	}
+	return queue[n-1].msg, true
+}
+
+// forget drops the committed messages of a partition up to offset
+func (t *offsetTracker) forget(tp topicPartition, offset int64) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	queue := t.pending[tp]
+	n := 0
+	for n < len(queue) && queue[n].done && queue[n].msg.Offset <= offset {
+		n++
+	}
+	t.pending[tp] = queue[n:]
+}
+
+// workerFor picks a fixed worker for a message: by key when present, otherwise
+// by partition, so messages of one key (or keyless partition) keep their order
+func workerFor(m Message, workerCount int) int {
+	if len(m.Key) == 0 {
+		return m.Partition % workerCount
+	}
+
+	h := fnv.New32a()
+	_, _ = h.Write(m.Key)
+	return int(h.Sum32() % uint32(workerCount))
+}
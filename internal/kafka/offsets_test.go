@@ -0,0 +1,95 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type recordingCommitter struct {
+	commits []Message
+	// fails is the number of commits that fail before they start to succeed
+	fails int
+}
+
+func (c *recordingCommitter) CommitMessages(_ context.Context, msgs ...Message) error {
+	if c.fails > 0 {
+		c.fails--
+		return errors.New("coordinator not available")
+	}
+	c.commits = append(c.commits, msgs...)
+	return nil
+}
+
+func committedOffsets(c *recordingCommitter) []int64 {
+	out := make([]int64, 0, len(c.commits))
+	for _, m := range c.commits {
+		out = append(out, m.Offset)
+	}
+	return out
+}
+
+func TestOffsetTracker_CommitsOnlyContiguousOffsets(t *testing.T) {
+	ctx := context.Background()
+	committer := &recordingCommitter{}
+	tracker := newOffsetTracker(committer)
+
+	msgs := make([]Message, 4)
+	for i := range msgs {
+		msgs[i] = Message{Topic: "orders", Partition: 0, Offset: int64(10 + i)}
+		tracker.track(msgs[i])
+	}
+
+	require.NoError(t, tracker.complete(ctx, msgs[1]))
+	require.NoError(t, tracker.complete(ctx, msgs[2]))
+	assert.Empty(t, committer.commits, "offset 10 is still in flight")
+
+	require.NoError(t, tracker.complete(ctx, msgs[0]))
+	assert.Equal(t, []int64{12}, committedOffsets(committer))
+
+	require.NoError(t, tracker.complete(ctx, msgs[3]))
+	assert.Equal(t, []int64{12, 13}, committedOffsets(committer))
+}
+
+func TestOffsetTracker_RetriesFailedCommit(t *testing.T) {
+	ctx := context.Background()
+	committer := &recordingCommitter{fails: 1}
+	tracker := newOffsetTracker(committer)
+
+	m := Message{Topic: "orders", Partition: 0, Offset: 3}
+	tracker.track(m)
+
+	require.Error(t, tracker.complete(ctx, m))
+	assert.Empty(t, committer.commits)
+
+	require.NoError(t, tracker.complete(ctx, m), "the message is still tracked after the failed commit")
+	assert.Equal(t, []int64{3}, committedOffsets(committer))
+}
+
+func TestOffsetTracker_PartitionsAreIndependent(t *testing.T) {
+	ctx := context.Background()
+	committer := &recordingCommitter{}
+	tracker := newOffsetTracker(committer)
+
+	p0 := Message{Topic: "orders", Partition: 0, Offset: 5}
+	p1 := Message{Topic: "orders", Partition: 1, Offset: 7}
+	tracker.track(p0)
+	tracker.track(p1)
+
+	require.NoError(t, tracker.complete(ctx, p1))
+	require.Len(t, committer.commits, 1)
+	assert.Equal(t, 1, committer.commits[0].Partition)
+	assert.Equal(t, int64(7), committer.commits[0].Offset)
+}
+
+func TestWorkerFor_SameKeySameWorker(t *testing.T) {
+	a := Message{Partition: 0, Key: []byte("order-1")}
+	b := Message{Partition: 3, Key: []byte("order-1")}
+	assert.Equal(t, workerFor(a, 8), workerFor(b, 8))
+
+	keyless := Message{Partition: 5}
+	assert.Equal(t, 1, workerFor(keyless, 4))
+}
@@ -0,0 +1,138 @@
+package kafka
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+
+	"order-service-wbtech/internal/model"
+)
+
+// CloudEvents attributes of the events published by the relay
+const (
+	CloudEventOrderAccepted = "com.wbtech.order.accepted"
+	outboxEventSource       = "order-service"
+)
+
+// outboxCloudEventTypes maps outbox event types to their CloudEvents types
+var outboxCloudEventTypes = map[string]string{
+	model.EventOrderAccepted: CloudEventOrderAccepted,
+}
+
+// OutboxStore hands unsent outbox events to publish and marks them sent,
+// implemented by *storage.Postgres
+type OutboxStore interface {
+	PublishOutbox(ctx context.Context, limit int, publish func(context.Context, []model.OutboxEvent) error) (int, error)
+}
+
+// MessageWriter writes messages to a topic, implemented by *kafka.Writer
+type MessageWriter interface {
+	WriteMessages(ctx context.Context, msgs ...kafka.Message) error
+}
+
+// OutboxRelay — publishes the events of the transactional outbox to Kafka. An
+// event is marked sent only after the write succeeded, and redelivered events
+// carry the same ce_id, so consumers can drop duplicates.
+type OutboxRelay struct {
+	store     OutboxStore
+	writer    MessageWriter
+	interval  time.Duration
+	batchSize int
+}
+
+func NewOutboxRelay(store OutboxStore, writer MessageWriter, interval time.Duration, batchSize int) *OutboxRelay {
+	if interval <= 0 {
+		interval = time.Second
+	}
+	if batchSize <= 0 {
+		batchSize = 100
+	}
+	return &OutboxRelay{
+		store:     store,
+		writer:    writer,
+		interval:  interval,
+		batchSize: batchSize,
+	}
+}
+
+// NewOutboxWriter creates the writer of the topic the relay publishes to
+func NewOutboxWriter(cluster Cluster, topic string) *kafka.Writer {
+	return &kafka.Writer{
+		Addr:         kafka.TCP(cluster.Brokers...),
+		Topic:        topic,
+		Transport:    cluster.Transport(),
+		Balancer:     &kafka.Hash{},
+		RequiredAcks: kafka.RequireAll,
+		BatchTimeout: 10 * time.Millisecond,
+	}
+}
+
+// Run relays the outbox every interval until ctx is cancelled
+func (r *OutboxRelay) Run(ctx context.Context) {
+	log.Printf("Outbox relay started | interval=%s batch=%d", r.interval, r.batchSize)
+
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+
+	for {
+		if err := r.Flush(ctx); err != nil && ctx.Err() == nil {
+			log.Printf("Outbox relay error: %v", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			log.Println("Outbox relay stopped")
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Flush publishes unsent events batch by batch until the outbox is empty
+func (r *OutboxRelay) Flush(ctx context.Context) error {
+	for {
+		n, err := r.store.PublishOutbox(ctx, r.batchSize, r.publish)
+		if err != nil {
+			outboxErrors.Inc()
+			return err
+		}
+		outboxPublished.Add(float64(n))
+		if n < r.batchSize {
+			return nil
+		}
+	}
+}
+
+func (r *OutboxRelay) publish(ctx context.Context, events []model.OutboxEvent) error {
+	msgs := make([]kafka.Message, len(events))
+	for i, e := range events {
+		msgs[i] = outboxMessage(e)
+	}
+
+	if err := r.writer.WriteMessages(ctx, msgs...); err != nil {
+		return fmt.Errorf("publish %d outbox events: %w", len(events), err)
+	}
+	return nil
+}
+
+// outboxMessage wraps an outbox event as a binary-mode CloudEvent keyed by order_uid
+func outboxMessage(e model.OutboxEvent) kafka.Message {
+	headers := []Header{
+		{Key: HeaderCloudEventSpecVersion, Value: []byte("1.0")},
+		{Key: HeaderCloudEventID, Value: []byte(e.EventID)},
+		{Key: HeaderCloudEventSource, Value: []byte(outboxEventSource)},
+		{Key: HeaderCloudEventType, Value: []byte(outboxCloudEventTypes[e.EventType])},
+		{Key: HeaderContentType, Value: []byte("application/json")},
+		{Key: HeaderEventType, Value: []byte(e.EventType)},
+	}
+
+	return kafka.Message{
+		Key:     []byte(e.OrderUID),
+		Value:   e.Payload,
+		Headers: toKafkaHeaders(headers),
+		Time:    e.CreatedAt,
+	}
+}
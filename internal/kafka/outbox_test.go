@@ -0,0 +1,135 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"order-service-wbtech/internal/model"
+)
+
+// memoryOutbox — OutboxStore that keeps events in a slice, like the order_outbox table
+type memoryOutbox struct {
+	mu     sync.Mutex
+	events []model.OutboxEvent
+	sent   map[int64]bool
+}
+
+func newMemoryOutbox(n int) *memoryOutbox {
+	o := &memoryOutbox{sent: make(map[int64]bool)}
+	for i := 1; i <= n; i++ {
+		uid := fmt.Sprintf("order-%d", i)
+		o.events = append(o.events, model.OutboxEvent{
+			ID:        int64(i),
+			EventID:   fmt.Sprintf("00000000-0000-0000-0000-%012d", i),
+			EventType: model.EventOrderAccepted,
+			OrderUID:  uid,
+			Payload:   []byte(`{"order_uid":"` + uid + `"}`),
+			CreatedAt: time.Now(),
+		})
+	}
+	return o
+}
+
+func (o *memoryOutbox) PublishOutbox(ctx context.Context, limit int, publish func(context.Context, []model.OutboxEvent) error) (int, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	var batch []model.OutboxEvent
+	for _, e := range o.events {
+		if !o.sent[e.ID] && len(batch) < limit {
+			batch = append(batch, e)
+		}
+	}
+	if len(batch) == 0 {
+		return 0, nil
+	}
+	if err := publish(ctx, batch); err != nil {
+		return 0, err
+	}
+	for _, e := range batch {
+		o.sent[e.ID] = true
+	}
+	return len(batch), nil
+}
+
+// memoryWriter — MessageWriter that records written messages and fails the first failures writes
+type memoryWriter struct {
+	msgs     []kafka.Message
+	failures int
+}
+
+func (w *memoryWriter) WriteMessages(_ context.Context, msgs ...kafka.Message) error {
+	if w.failures > 0 {
+		w.failures--
+		return errors.New("broker unavailable")
+	}
+	w.msgs = append(w.msgs, msgs...)
+	return nil
+}
+
+func TestOutboxRelay_FlushPublishesEverything(t *testing.T) {
+	store := newMemoryOutbox(5)
+	writer := &memoryWriter{}
+
+	require.NoError(t, NewOutboxRelay(store, writer, time.Second, 2).Flush(context.Background()))
+
+	require.Len(t, writer.msgs, 5)
+	assert.Len(t, store.sent, 5)
+
+	m := fromKafkaMessage(writer.msgs[0])
+	assert.Equal(t, "order-1", string(m.Key))
+	assert.Equal(t, `{"order_uid":"order-1"}`, string(m.Value))
+	for key, want := range map[string]string{
+		HeaderCloudEventSpecVersion: "1.0",
+		HeaderCloudEventID:          "00000000-0000-0000-0000-000000000001",
+		HeaderCloudEventSource:      "order-service",
+		HeaderCloudEventType:        CloudEventOrderAccepted,
+		HeaderEventType:             model.EventOrderAccepted,
+	} {
+		v, ok := m.Header(key)
+		require.True(t, ok, key)
+		assert.Equal(t, want, string(v), key)
+	}
+}
+
+func TestOutboxRelay_FailedPublishIsRetried(t *testing.T) {
+	store := newMemoryOutbox(3)
+	writer := &memoryWriter{failures: 1}
+	relay := NewOutboxRelay(store, writer, time.Second, 10)
+
+	assert.Error(t, relay.Flush(context.Background()))
+	assert.Empty(t, store.sent, "nothing is marked sent when the write fails")
+
+	require.NoError(t, relay.Flush(context.Background()))
+	assert.Len(t, writer.msgs, 3)
+	assert.Len(t, store.sent, 3)
+}
+
+func TestOutboxRelay_RunStopsWithContext(t *testing.T) {
+	store := newMemoryOutbox(1)
+	writer := &memoryWriter{failures: 1}
+	relay := NewOutboxRelay(store, writer, 5*time.Millisecond, 10)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		relay.Run(ctx)
+	}()
+
+	require.Eventually(t, func() bool {
+		store.mu.Lock()
+		defer store.mu.Unlock()
+		return len(store.sent) == 1
+	}, 2*time.Second, 5*time.Millisecond)
+	cancel()
+	<-done
+}
@@ -0,0 +1,22 @@
+package kafka
+
+// Outcome — what happened to a handled message
+type Outcome string
+
+const (
+	OutcomeInserted       Outcome = "inserted"
+	OutcomeStatusUpdated  Outcome = "status_updated"
+	OutcomeDuplicate      Outcome = "duplicate"
+	OutcomeStale          Outcome = "stale"
+	OutcomeInvalidPayload Outcome = "invalid_payload"
+	OutcomeInvalidOrder   Outcome = "invalid_order"
+	OutcomeParked         Outcome = "parked"
+)
+
+// rejectedOutcome maps the stage a message was rejected at to its outcome
+func rejectedOutcome(stage string) Outcome {
+	if stage == StageValidate {
+		return OutcomeInvalidOrder
+	}
+	return OutcomeInvalidPayload
+}
@@ -0,0 +1,439 @@
+package kafka
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+
+	"order-service-wbtech/internal/codec"
+	"order-service-wbtech/internal/model"
+	"order-service-wbtech/internal/tracing"
+	"order-service-wbtech/internal/validator"
+)
+
+// ErrOrderNotArrived — a status event refers to an order that is not stored yet
+var ErrOrderNotArrived = errors.New("order has not arrived yet")
+
+// ErrNoDeadLetterWriter — a message was rejected but there is nowhere to keep it,
+// so it stays uncommitted instead of being lost
+var ErrNoDeadLetterWriter = errors.New("no dead-letter writer configured")
+
+// ErrDeadLetterFailed — the DLQ did not take a rejected message; the rejection is
+// retried, as the DLQ is usually just unavailable
+var ErrDeadLetterFailed = errors.New("dead-letter write failed")
+
+// OrderIngestor stores decoded orders, implemented by *service.Service
+type OrderIngestor interface {
+	CreateOrder(ctx context.Context, order *model.Order) error
+	CreateOrders(ctx context.Context, orders []*model.Order) error
+	UpdateOrderStatus(ctx context.Context, event *model.StatusEvent) error
+}
+
+// Processor — decodes, validates and stores messages, retrying transient failures
+type Processor struct {
+	svc     OrderIngestor
+	dlq     DeadLetterWriter
+	parking DeadLetterWriter
+	retry   RetryPolicy
+	decoder *codec.Decoder
+	// defaultEvent is the event type of messages that do not declare one
+	defaultEvent string
+}
+
+// NewProcessor creates a processor. Messages that exhaust their retries are sent
+// to parking; if parking is nil they go to the dlq instead.
+func NewProcessor(svc OrderIngestor, dlq, parking DeadLetterWriter, retry RetryPolicy) *Processor {
+	if parking == nil {
+		parking = dlq
+	}
+	return &Processor{
+		svc:     svc,
+		dlq:     dlq,
+		parking: parking,
+		retry:   retry.withDefaults(),
+		decoder: codec.NewDecoder(nil, codec.FormatJSON),
+
+		defaultEvent: EventOrderCreated,
+	}
+}
+
+// SetDecoder replaces the default JSON-only order decoder, e.g. with one backed
+// by a schema registry for Avro and Protobuf payloads
+func (p *Processor) SetDecoder(d *codec.Decoder) {
+	p.decoder = d
+}
+
+// SetDefaultEvent sets the event type of messages without a CloudEvents type or
+// event_type header, e.g. order_status_changed for a topic of status events
+func (p *Processor) SetDefaultEvent(event string) error {
+	switch event {
+	case EventOrderCreated, EventOrderStatusChanged, EventPaymentConfirmed:
+		p.defaultEvent = event
+		return nil
+	default:
+		return fmt.Errorf("unknown event type %q", event)
+	}
+}
+
+// Handle processes a message with the retry policy. A nil error means the message
+// was stored, skipped, dead-lettered or parked and its offset can be committed.
+func (p *Processor) Handle(ctx context.Context, m Message) (Outcome, error) {
+	started := time.Now()
+	ctx, span := startMessageSpan(ctx, "process "+m.Topic, m)
+	defer span.End()
+
+	outcome, err := p.handle(ctx, m)
+	if err != nil {
+		tracing.Fail(span, err)
+		return outcome, err
+	}
+
+	span.SetAttributes(attribute.String("order.outcome", string(outcome)))
+	observeOutcome(m, outcome, started)
+	return outcome, nil
+}
+
+func (p *Processor) handle(ctx context.Context, m Message) (Outcome, error) {
+	var outcome Outcome
+	var err error
+	attempt := 1
+	for ; ; attempt++ {
+		outcome, err = p.processMessage(ctx, m)
+		if err == nil {
+			return outcome, nil
+		}
+		if !IsRetryable(err) || attempt >= p.retry.MaxAttempts {
+			break
+		}
+
+		log.Printf("Attempt %d/%d failed for offset %d: %v → retrying", attempt, p.retry.MaxAttempts, m.Offset, err)
+		if err := p.retry.sleep(ctx, attempt); err != nil {
+			return "", err
+		}
+	}
+
+	// shutting down: leave the message uncommitted so it is redelivered
+	if ctx.Err() != nil {
+		return "", ctx.Err()
+	}
+
+	log.Printf("Giving up on offset %d after %d attempt(s): %v → parking", m.Offset, attempt, err)
+	if err := p.park(ctx, m, err, attempt); err != nil {
+		return "", err
+	}
+	return OutcomeParked, nil
+}
+
+// Resubmit runs a message once through processMessage as if it had just been
+// consumed, without retries or parking. A message that is rejected again is not
+// dead-lettered; the rejection is returned as the error instead.
+func (p *Processor) Resubmit(ctx context.Context, m Message) (Outcome, error) {
+	ctx, span := startMessageSpan(ctx, "resubmit "+m.Topic, m)
+	defer span.End()
+
+	rejected := NewMemoryDeadLetterWriter()
+	resubmit := *p
+	resubmit.dlq = rejected
+
+	outcome, err := resubmit.processMessage(ctx, m)
+	if err == nil {
+		if letters := rejected.Letters(); len(letters) > 0 {
+			err = fmt.Errorf("rejected at %s: %s", letters[0].Stage, letters[0].Reason)
+		}
+	}
+	if err != nil {
+		tracing.Fail(span, err)
+		return outcome, err
+	}
+
+	span.SetAttributes(attribute.String("order.outcome", string(outcome)))
+	return outcome, nil
+}
+
+// processMessage processes a single message with all business logic
+func (p *Processor) processMessage(ctx context.Context, m Message) (Outcome, error) {
+	log.Printf("Processing message offset=%d partition=%d trace=%s", m.Offset, m.Partition, tracing.TraceID(ctx))
+
+	event, err := normalizeCloudEvent(m)
+	if err != nil {
+		log.Printf("Rejected message (offset %d): %v → dead-lettering (no retry)", m.Offset, err)
+		return p.deadLetter(ctx, m, StageDecode, err)
+	}
+	m = event
+	ctx = model.WithEventSource(ctx, eventSource(m))
+
+	switch event := eventType(m, p.defaultEvent); event {
+	case EventOrderCreated:
+		return p.processOrderCreated(ctx, m)
+	case EventOrderStatusChanged:
+		return p.processStatusChanged(ctx, m)
+	case EventPaymentConfirmed:
+		return p.processPaymentConfirmed(ctx, m)
+	default:
+		err := fmt.Errorf("unknown event type %q", event)
+		log.Printf("Rejected message (offset %d): %v → dead-lettering (no retry)", m.Offset, err)
+		return p.deadLetter(ctx, m, StageDecode, err)
+	}
+}
+
+func (p *Processor) processOrderCreated(ctx context.Context, m Message) (Outcome, error) {
+	order, stage, err := p.decodeOrder(ctx, m)
+	if err != nil {
+		log.Printf("Rejected message (offset %d) at %s: %v → dead-lettering (no retry)", m.Offset, stage, err)
+		return p.deadLetter(ctx, m, stage, err)
+	}
+
+	saveCtx, span := tracing.Start(ctx, "save", trace.WithAttributes(tracing.OrderUID(order.OrderUID)))
+	defer span.End()
+
+	if err := p.svc.CreateOrder(saveCtx, order); err != nil {
+		if errors.Is(err, model.ErrOrderExists) || errors.Is(err, model.ErrDuplicateEvent) {
+			log.Printf("Order %s already stored (offset %d) → skipping redelivery", order.OrderUID, m.Offset)
+			return OutcomeDuplicate, nil
+		}
+		tracing.Fail(span, err)
+		return "", err
+	}
+
+	return OutcomeInserted, nil
+}
+
+func (p *Processor) processStatusChanged(ctx context.Context, m Message) (Outcome, error) {
+	event, stage, err := decodeStatusEvent(ctx, m)
+	if err != nil {
+		log.Printf("Rejected status event (offset %d) at %s: %v → dead-lettering (no retry)", m.Offset, stage, err)
+		return p.deadLetter(ctx, m, stage, err)
+	}
+
+	return p.applyStatusEvent(ctx, m, event, "Status event")
+}
+
+// processPaymentConfirmed moves the order of a confirmed payment to paid
+func (p *Processor) processPaymentConfirmed(ctx context.Context, m Message) (Outcome, error) {
+	payment, stage, err := decodePaymentConfirmation(ctx, m)
+	if err != nil {
+		log.Printf("Rejected payment confirmation (offset %d) at %s: %v → dead-lettering (no retry)", m.Offset, stage, err)
+		return p.deadLetter(ctx, m, stage, err)
+	}
+
+	return p.applyStatusEvent(ctx, m, payment.StatusEvent(), "Payment confirmation")
+}
+
+// applyStatusEvent stores a status change. Redelivered events and events older
+// than the current status are skipped. An event for an order that is not stored
+// yet is retried, since the order may still be on its way through another topic
+// or partition, and parked if it does not arrive.
+func (p *Processor) applyStatusEvent(ctx context.Context, m Message, event *model.StatusEvent, what string) (Outcome, error) {
+	saveCtx, span := tracing.Start(ctx, "save", trace.WithAttributes(tracing.OrderUID(event.OrderUID)))
+	defer span.End()
+
+	err := p.svc.UpdateOrderStatus(saveCtx, event)
+	switch {
+	case err == nil:
+		return OutcomeStatusUpdated, nil
+	case errors.Is(err, model.ErrDuplicateEvent):
+		log.Printf("%s for order %s already applied (offset %d) → skipping redelivery", what, event.OrderUID, m.Offset)
+		return OutcomeDuplicate, nil
+	case errors.Is(err, model.ErrStaleEvent):
+		log.Printf("%s for order %s is older than its current status (offset %d) → skipping", what, event.OrderUID, m.Offset)
+		return OutcomeStale, nil
+	case errors.Is(err, model.ErrNotFound):
+		err = fmt.Errorf("%w: %w", ErrOrderNotArrived, err)
+	}
+	tracing.Fail(span, err)
+	return "", err
+}
+
+// eventSource describes the message as the origin of an order change
+func eventSource(m Message) model.EventSource {
+	return model.EventSource{
+		Kind:      model.SourceKafka,
+		Topic:     m.Topic,
+		Partition: m.Partition,
+		Offset:    m.Offset,
+		EventID:   cloudEventID(m),
+	}
+}
+
+// decodeOrder decodes the message payload in its format and validates it. On
+// failure it returns the stage at which the message was rejected.
+func (p *Processor) decodeOrder(ctx context.Context, m Message) (*model.Order, string, error) {
+	decodeCtx, span := tracing.Start(ctx, "decode")
+	order, err := p.unmarshalOrder(decodeCtx, m)
+	tracing.End(span, err)
+	if err != nil {
+		return nil, StageDecode, err
+	}
+
+	_, span = tracing.Start(ctx, "validate", trace.WithAttributes(tracing.OrderUID(order.OrderUID)))
+	err = validator.ValidateOrder(order)
+	tracing.End(span, err)
+	if err != nil {
+		return nil, StageValidate, fmt.Errorf("order %s: %w", order.OrderUID, err)
+	}
+
+	return order, "", nil
+}
+
+func (p *Processor) unmarshalOrder(ctx context.Context, m Message) (*model.Order, error) {
+	format, err := messageFormat(m)
+	if err != nil {
+		return nil, err
+	}
+	return p.decoder.Decode(ctx, format, m.Value)
+}
+
+func decodeStatusEvent(ctx context.Context, m Message) (*model.StatusEvent, string, error) {
+	var event model.StatusEvent
+	_, span := tracing.Start(ctx, "decode")
+	err := json.Unmarshal(m.Value, &event)
+	tracing.End(span, err)
+	if err != nil {
+		return nil, StageDecode, err
+	}
+
+	_, span = tracing.Start(ctx, "validate", trace.WithAttributes(tracing.OrderUID(event.OrderUID)))
+	err = validator.ValidateStatusEvent(&event)
+	tracing.End(span, err)
+	if err != nil {
+		return nil, StageValidate, fmt.Errorf("status event for order %s: %w", event.OrderUID, err)
+	}
+
+	return &event, "", nil
+}
+
+func decodePaymentConfirmation(ctx context.Context, m Message) (*model.PaymentConfirmation, string, error) {
+	var payment model.PaymentConfirmation
+	_, span := tracing.Start(ctx, "decode")
+	err := json.Unmarshal(m.Value, &payment)
+	tracing.End(span, err)
+	if err != nil {
+		return nil, StageDecode, err
+	}
+
+	_, span = tracing.Start(ctx, "validate", trace.WithAttributes(tracing.OrderUID(payment.OrderUID)))
+	err = validator.ValidatePaymentConfirmation(&payment)
+	tracing.End(span, err)
+	if err != nil {
+		return nil, StageValidate, fmt.Errorf("payment confirmation for order %s: %w", payment.OrderUID, err)
+	}
+
+	return &payment, "", nil
+}
+
+// HandleBatch stores all valid new orders of msgs in one transaction. Everything
+// else — invalid messages, status events, or the whole batch if saving it failed —
+// goes through Handle one by one in the original order, so duplicates, conflicts
+// and transient errors get their usual treatment. Outcomes are returned in the
+// order of msgs; a batched message is observed with the time of the whole batch.
+func (p *Processor) HandleBatch(ctx context.Context, msgs []Message) ([]Outcome, error) {
+	started := time.Now()
+	links := make([]trace.Link, len(msgs))
+	for i, m := range msgs {
+		links[i] = trace.LinkFromContext(extractTraceContext(ctx, m))
+	}
+	ctx, span := tracing.Start(ctx, "process batch",
+		trace.WithSpanKind(trace.SpanKindConsumer),
+		trace.WithLinks(links...),
+		trace.WithAttributes(attribute.Int("messaging.batch.message_count", len(msgs))),
+	)
+	defer span.End()
+
+	orders := make([]*model.Order, 0, len(msgs))
+	batched := make(map[int]bool, len(msgs))
+	sources := make(map[string]model.EventSource, len(msgs))
+
+	for i, m := range msgs {
+		m, err := normalizeCloudEvent(m)
+		if err != nil || eventType(m, p.defaultEvent) != EventOrderCreated {
+			continue
+		}
+		order, _, err := p.decodeOrder(ctx, m)
+		if err != nil {
+			continue
+		}
+		orders = append(orders, order)
+		batched[i] = true
+		sources[order.OrderUID] = eventSource(m)
+	}
+
+	if len(orders) > 0 {
+		saveCtx, saveSpan := tracing.Start(ctx, "save", trace.WithAttributes(attribute.Int("order.count", len(orders))))
+		err := p.svc.CreateOrders(model.WithEventSources(saveCtx, sources), orders)
+		tracing.End(saveSpan, err)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			log.Printf("Batch of %d orders failed: %v → falling back to per-message processing", len(orders), err)
+			batched = nil
+		} else {
+			log.Printf("Saved batch of %d orders", len(orders))
+		}
+	}
+
+	outcomes := make([]Outcome, len(msgs))
+	for i, m := range msgs {
+		if batched[i] {
+			outcomes[i] = OutcomeInserted
+			observeOutcome(m, OutcomeInserted, started)
+			continue
+		}
+
+		outcome, err := p.Handle(ctx, m)
+		if err != nil {
+			return nil, err
+		}
+		outcomes[i] = outcome
+	}
+	return outcomes, nil
+}
+
+// deadLetter hands a non-retryable message to the DLQ. The message is committed only
+// if the DLQ write succeeds, so nothing is lost when the DLQ itself is unavailable.
+func (p *Processor) deadLetter(ctx context.Context, m Message, stage string, cause error) (Outcome, error) {
+	outcome := rejectedOutcome(stage)
+	if p.dlq == nil {
+		return "", fmt.Errorf("dead-letter message (offset %d): %w", m.Offset, ErrNoDeadLetterWriter)
+	}
+
+	err := p.dlq.WriteDeadLetter(ctx, DeadLetter{
+		Message:  m,
+		Stage:    stage,
+		Reason:   cause.Error(),
+		Attempts: 1,
+		FailedAt: time.Now(),
+
+		FieldErrors: validator.FieldErrors(cause),
+	})
+	if err != nil {
+		return "", fmt.Errorf("dead-letter message (offset %d): %w: %w", m.Offset, ErrDeadLetterFailed, err)
+	}
+	return outcome, nil
+}
+
+// park moves a message that could not be saved out of the main topic
+func (p *Processor) park(ctx context.Context, m Message, cause error, attempts int) error {
+	if p.parking == nil {
+		log.Printf("No parking location configured, dropping offset %d", m.Offset)
+		return nil
+	}
+
+	err := p.parking.WriteDeadLetter(ctx, DeadLetter{
+		Message:  m,
+		Stage:    StageSave,
+		Reason:   cause.Error(),
+		Attempts: attempts,
+		FailedAt: time.Now(),
+	})
+	if err != nil {
+		return fmt.Errorf("park message (offset %d): %w", m.Offset, err)
+	}
+	return nil
+}
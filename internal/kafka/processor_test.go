@@ -0,0 +1,411 @@
+package kafka
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"order-service-wbtech/internal/codec"
+	"order-service-wbtech/internal/mocks"
+	"order-service-wbtech/internal/model"
+	"order-service-wbtech/internal/service"
+)
+
+func testOrder(uid string) *model.Order {
+	return &model.Order{
+		OrderUID:        uid,
+		TrackNumber:     "WBILMTESTTRACK",
+		Entry:           "WBIL",
+		Locale:          "en",
+		CustomerID:      "test",
+		DeliveryService: "meest",
+		ShardKey:        "9",
+		SMID:            99,
+		DateCreated:     time.Date(2021, 11, 26, 6, 22, 19, 0, time.UTC),
+		OOFShard:        "1",
+		Delivery: model.Delivery{
+			Name:    "Test Testov",
+			Phone:   "+9720000000",
+			Zip:     "2639809",
+			City:    "Kiryat Mozkin",
+			Address: "Ploshad Mira 15",
+			Region:  "Kraiot",
+			Email:   "test@gmail.com",
+		},
+		Payment: model.Payment{
+			Transaction:  uid,
+			Currency:     "USD",
+			Provider:     "wbpay",
+			Amount:       1817,
+			PaymentDt:    1637907727,
+			Bank:         "alpha",
+			DeliveryCost: 1500,
+			GoodsTotal:   317,
+		},
+		Items: []model.Item{{
+			ChrtID:      9934930,
+			TrackNumber: "WBILMTESTTRACK",
+			Price:       453,
+			Rid:         "ab4219087a764ae0btest",
+			Name:        "Mascaras",
+			Sale:        30,
+			Size:        "0",
+			TotalPrice:  317,
+			NmID:        2389212,
+			Brand:       "Vivienne Sabo",
+			Status:      202,
+		}},
+	}
+}
+
+func testMessage(t *testing.T, order *model.Order, offset int64) Message {
+	t.Helper()
+
+	b, err := json.Marshal(order)
+	require.NoError(t, err)
+	return Message{Topic: "orders", Offset: offset, Key: []byte(order.OrderUID), Value: b}
+}
+
+var fastRetry = RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Millisecond, MaxBackoff: time.Millisecond, Multiplier: 1}
+
+func TestProcessMessage_InvalidJSONGoesToDLQ(t *testing.T) {
+	dbMock := &mocks.Storage{}
+	cacheMock := &mocks.Cache{}
+	svc := service.New(cacheMock, dbMock)
+	dlq := NewMemoryDeadLetterWriter()
+	proc := NewProcessor(svc, dlq, nil, RetryPolicy{})
+
+	msg := Message{
+		Topic:     "orders",
+		Partition: 2,
+		Offset:    42,
+		Key:       []byte("key"),
+		Value:     []byte("{not json"),
+		Headers:   []Header{{Key: "source", Value: []byte("test")}},
+	}
+
+	outcome, err := proc.Handle(context.Background(), msg)
+	require.NoError(t, err)
+	assert.Equal(t, OutcomeInvalidPayload, outcome)
+
+	letters := dlq.Letters()
+	require.Len(t, letters, 1)
+	assert.Equal(t, StageDecode, letters[0].Stage)
+	assert.Equal(t, msg.Value, letters[0].Message.Value)
+	assert.Equal(t, int64(42), letters[0].Message.Offset)
+	assert.NotEmpty(t, letters[0].Reason)
+
+	dbMock.AssertNotCalled(t, "SaveOrder")
+}
+
+func TestProcessMessage_ValidationFailureGoesToDLQ(t *testing.T) {
+	dbMock := &mocks.Storage{}
+	cacheMock := &mocks.Cache{}
+	svc := service.New(cacheMock, dbMock)
+	dlq := NewMemoryDeadLetterWriter()
+	proc := NewProcessor(svc, dlq, nil, RetryPolicy{})
+
+	msg := Message{Topic: "orders", Offset: 7, Value: []byte(`{"order_uid":"123"}`)}
+
+	outcome, err := proc.Handle(context.Background(), msg)
+	require.NoError(t, err)
+	assert.Equal(t, OutcomeInvalidOrder, outcome)
+
+	letters := dlq.Letters()
+	require.Len(t, letters, 1)
+	assert.Equal(t, StageValidate, letters[0].Stage)
+
+	dbMock.AssertNotCalled(t, "SaveOrder")
+}
+
+func TestDeadLetterHeaders(t *testing.T) {
+	dl := DeadLetter{
+		Message: Message{
+			Topic:     "orders",
+			Partition: 3,
+			Offset:    100,
+			Headers:   []Header{{Key: "trace", Value: []byte("abc")}},
+		},
+		Stage:  StageDecode,
+		Reason: "boom",
+	}
+
+	headers := map[string]string{}
+	for _, h := range deadLetterHeaders(dl) {
+		headers[h.Key] = string(h.Value)
+	}
+
+	assert.Equal(t, "abc", headers["trace"])
+	assert.Equal(t, "boom", headers[HeaderDLQReason])
+	assert.Equal(t, StageDecode, headers[HeaderDLQStage])
+	assert.Equal(t, "orders", headers[HeaderDLQOriginalTopic])
+	assert.Equal(t, "3", headers[HeaderDLQOriginalPartition])
+	assert.Equal(t, "100", headers[HeaderDLQOriginalOffset])
+}
+
+func TestHandle_SavesValidOrder(t *testing.T) {
+	dbMock := &mocks.Storage{}
+	cacheMock := &mocks.Cache{}
+	dlq := NewMemoryDeadLetterWriter()
+	proc := NewProcessor(service.New(cacheMock, dbMock), dlq, nil, fastRetry)
+
+	dbMock.On("SaveOrder", mock.Anything, mock.Anything).Return(nil)
+	cacheMock.On("Set", mock.Anything).Return()
+
+	outcome, err := proc.Handle(context.Background(), testMessage(t, testOrder("ok"), 1))
+	require.NoError(t, err)
+	assert.Equal(t, OutcomeInserted, outcome)
+
+	dbMock.AssertNumberOfCalls(t, "SaveOrder", 1)
+	assert.Empty(t, dlq.Letters())
+}
+
+func TestHandle_RetriesTransientErrorThenParks(t *testing.T) {
+	dbMock := &mocks.Storage{}
+	cacheMock := &mocks.Cache{}
+	dlq := NewMemoryDeadLetterWriter()
+	parking := NewMemoryDeadLetterWriter()
+	proc := NewProcessor(service.New(cacheMock, dbMock), dlq, parking, fastRetry)
+
+	dbMock.On("SaveOrder", mock.Anything, mock.Anything).Return(&pgconn.PgError{Code: "08006"})
+
+	outcome, err := proc.Handle(context.Background(), testMessage(t, testOrder("retry"), 5))
+	require.NoError(t, err)
+	assert.Equal(t, OutcomeParked, outcome)
+
+	dbMock.AssertNumberOfCalls(t, "SaveOrder", 3)
+	assert.Empty(t, dlq.Letters())
+
+	letters := parking.Letters()
+	require.Len(t, letters, 1)
+	assert.Equal(t, StageSave, letters[0].Stage)
+	assert.Equal(t, 3, letters[0].Attempts)
+}
+
+func TestHandle_RejectWithoutDeadLetterWriterIsParked(t *testing.T) {
+	parking := NewMemoryDeadLetterWriter()
+	proc := NewProcessor(newMemoryIngestor(), nil, parking, fastRetry)
+
+	// without a dead-letter writer the reject is not committed as handled
+	outcome, err := proc.Handle(context.Background(), Message{Topic: "orders", Offset: 4, Value: []byte("{not json")})
+	require.NoError(t, err)
+	assert.Equal(t, OutcomeParked, outcome)
+
+	letters := parking.Letters()
+	require.Len(t, letters, 1)
+	assert.Contains(t, letters[0].Reason, ErrNoDeadLetterWriter.Error())
+}
+
+func TestHandle_RecoversAfterTransientError(t *testing.T) {
+	dbMock := &mocks.Storage{}
+	cacheMock := &mocks.Cache{}
+	parking := NewMemoryDeadLetterWriter()
+	proc := NewProcessor(service.New(cacheMock, dbMock), nil, parking, fastRetry)
+
+	dbMock.On("SaveOrder", mock.Anything, mock.Anything).Return(fmt.Errorf("%w: conn closed", model.ErrUnavailable)).Once()
+	dbMock.On("SaveOrder", mock.Anything, mock.Anything).Return(nil).Once()
+	cacheMock.On("Set", mock.Anything).Return()
+
+	outcome, err := proc.Handle(context.Background(), testMessage(t, testOrder("flaky"), 6))
+	require.NoError(t, err)
+	assert.Equal(t, OutcomeInserted, outcome)
+
+	dbMock.AssertNumberOfCalls(t, "SaveOrder", 2)
+	assert.Empty(t, parking.Letters())
+}
+
+func TestHandle_PermanentErrorParksWithoutRetry(t *testing.T) {
+	dbMock := &mocks.Storage{}
+	cacheMock := &mocks.Cache{}
+	parking := NewMemoryDeadLetterWriter()
+	proc := NewProcessor(service.New(cacheMock, dbMock), nil, parking, fastRetry)
+
+	dbMock.On("SaveOrder", mock.Anything, mock.Anything).Return(&pgconn.PgError{Code: "23502"})
+
+	outcome, err := proc.Handle(context.Background(), testMessage(t, testOrder("bad"), 7))
+	require.NoError(t, err)
+	assert.Equal(t, OutcomeParked, outcome)
+
+	dbMock.AssertNumberOfCalls(t, "SaveOrder", 1)
+	require.Len(t, parking.Letters(), 1)
+	assert.Equal(t, 1, parking.Letters()[0].Attempts)
+}
+
+func TestHandle_StatusBeforeOrderIsRetried(t *testing.T) {
+	dbMock := &mocks.Storage{}
+	cacheMock := &mocks.Cache{}
+	parking := NewMemoryDeadLetterWriter()
+	proc := NewProcessor(service.New(cacheMock, dbMock), nil, parking, fastRetry)
+
+	// the order arrives while the status event waits for it
+	notFound := fmt.Errorf("order early: %w", model.ErrNotFound)
+	dbMock.On("UpdateOrderStatus", mock.Anything, "early", model.StatusPaid, mock.Anything).Return(nil, notFound).Once()
+	dbMock.On("UpdateOrderStatus", mock.Anything, "early", model.StatusPaid, mock.Anything).
+		Return(&model.Order{OrderUID: "early", Status: model.StatusPaid}, nil).Once()
+	dbMock.On("UpdateOrderStatus", mock.Anything, "never", model.StatusPaid, mock.Anything).Return(nil, notFound)
+	cacheMock.On("Set", mock.Anything).Return()
+
+	outcome, err := proc.Handle(context.Background(), statusMessage(t, "early", model.StatusPaid))
+	require.NoError(t, err)
+	assert.Equal(t, OutcomeStatusUpdated, outcome)
+
+	outcome, err = proc.Handle(context.Background(), statusMessage(t, "never", model.StatusPaid))
+	require.NoError(t, err)
+	assert.Equal(t, OutcomeParked, outcome)
+	require.Len(t, parking.Letters(), 1)
+	assert.Equal(t, fastRetry.MaxAttempts, parking.Letters()[0].Attempts)
+}
+
+func TestHandle_StaleStatusIsSkipped(t *testing.T) {
+	dbMock := &mocks.Storage{}
+	parking := NewMemoryDeadLetterWriter()
+	proc := NewProcessor(service.New(&mocks.Cache{}, dbMock), nil, parking, fastRetry)
+
+	dbMock.On("UpdateOrderStatus", mock.Anything, "late", model.StatusPaid, mock.Anything).
+		Return(nil, fmt.Errorf("order late: %w", model.ErrStaleEvent))
+
+	outcome, err := proc.Handle(context.Background(), statusMessage(t, "late", model.StatusPaid))
+	require.NoError(t, err)
+	assert.Equal(t, OutcomeStale, outcome)
+	dbMock.AssertNumberOfCalls(t, "UpdateOrderStatus", 1)
+	assert.Empty(t, parking.Letters())
+}
+
+func TestHandle_CancelledContextIsNotParked(t *testing.T) {
+	dbMock := &mocks.Storage{}
+	cacheMock := &mocks.Cache{}
+	parking := NewMemoryDeadLetterWriter()
+	proc := NewProcessor(service.New(cacheMock, dbMock), nil, parking, fastRetry)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	dbMock.On("SaveOrder", mock.Anything, mock.Anything).Return(context.Canceled)
+
+	_, err := proc.Handle(ctx, testMessage(t, testOrder("cancel"), 8))
+	require.ErrorIs(t, err, context.Canceled)
+	assert.Empty(t, parking.Letters())
+}
+
+func TestHandle_DuplicateOrderIsSkipped(t *testing.T) {
+	dbMock := &mocks.Storage{}
+	cacheMock := &mocks.Cache{}
+	parking := NewMemoryDeadLetterWriter()
+	proc := NewProcessor(service.New(cacheMock, dbMock), nil, parking, fastRetry)
+
+	dbMock.On("SaveOrder", mock.Anything, mock.Anything).Return(model.ErrOrderExists)
+
+	outcome, err := proc.Handle(context.Background(), testMessage(t, testOrder("dup"), 9))
+	require.NoError(t, err)
+	assert.Equal(t, OutcomeDuplicate, outcome)
+
+	dbMock.AssertNumberOfCalls(t, "SaveOrder", 1)
+	assert.Empty(t, parking.Letters())
+}
+
+func TestHandle_ConflictingOrderIsParked(t *testing.T) {
+	dbMock := &mocks.Storage{}
+	cacheMock := &mocks.Cache{}
+	parking := NewMemoryDeadLetterWriter()
+	proc := NewProcessor(service.New(cacheMock, dbMock), nil, parking, fastRetry)
+
+	dbMock.On("SaveOrder", mock.Anything, mock.Anything).Return(model.ErrOrderConflict)
+
+	outcome, err := proc.Handle(context.Background(), testMessage(t, testOrder("conflict"), 10))
+	require.NoError(t, err)
+	assert.Equal(t, OutcomeParked, outcome)
+
+	dbMock.AssertNumberOfCalls(t, "SaveOrder", 1)
+	require.Len(t, parking.Letters(), 1)
+	assert.Contains(t, parking.Letters()[0].Reason, model.ErrOrderConflict.Error())
+}
+
+func TestHandleBatch_SavesValidOrdersTogether(t *testing.T) {
+	dbMock := &mocks.Storage{}
+	cacheMock := &mocks.Cache{}
+	dlq := NewMemoryDeadLetterWriter()
+	proc := NewProcessor(service.New(cacheMock, dbMock), dlq, nil, fastRetry)
+
+	dbMock.On("SaveOrders", mock.Anything, mock.MatchedBy(func(orders []*model.Order) bool {
+		return len(orders) == 2
+	})).Return(nil)
+	cacheMock.On("Set", mock.Anything).Return()
+
+	batch := []Message{
+		testMessage(t, testOrder("a"), 1),
+		{Topic: "orders", Offset: 2, Value: []byte("garbage")},
+		testMessage(t, testOrder("b"), 3),
+	}
+
+	outcomes, err := proc.HandleBatch(context.Background(), batch)
+	require.NoError(t, err)
+	assert.Equal(t, []Outcome{OutcomeInserted, OutcomeInvalidPayload, OutcomeInserted}, outcomes)
+
+	dbMock.AssertNumberOfCalls(t, "SaveOrders", 1)
+	dbMock.AssertNotCalled(t, "SaveOrder", mock.Anything, mock.Anything)
+	require.Len(t, dlq.Letters(), 1)
+	assert.Equal(t, int64(2), dlq.Letters()[0].Message.Offset)
+}
+
+func TestHandleBatch_FallsBackToSingleMessages(t *testing.T) {
+	dbMock := &mocks.Storage{}
+	cacheMock := &mocks.Cache{}
+	proc := NewProcessor(service.New(cacheMock, dbMock), nil, nil, fastRetry)
+
+	dbMock.On("SaveOrders", mock.Anything, mock.Anything).Return(&pgconn.PgError{Code: "23505"})
+	dbMock.On("SaveOrder", mock.Anything, mock.MatchedBy(func(o *model.Order) bool {
+		return o.OrderUID == "a"
+	})).Return(model.ErrOrderExists)
+	dbMock.On("SaveOrder", mock.Anything, mock.MatchedBy(func(o *model.Order) bool {
+		return o.OrderUID == "b"
+	})).Return(nil)
+	cacheMock.On("Set", mock.Anything).Return()
+
+	batch := []Message{testMessage(t, testOrder("a"), 1), testMessage(t, testOrder("b"), 2)}
+
+	outcomes, err := proc.HandleBatch(context.Background(), batch)
+	require.NoError(t, err)
+	assert.Equal(t, []Outcome{OutcomeDuplicate, OutcomeInserted}, outcomes)
+
+	dbMock.AssertNumberOfCalls(t, "SaveOrder", 2)
+}
+
+func TestHandle_DecodesRegisteredFormats(t *testing.T) {
+	registry, err := codec.NewFileRegistry("../../schemas/registry.json")
+	require.NoError(t, err)
+	enc := codec.NewEncoder(registry)
+
+	ingestor := newMemoryIngestor()
+	dlq := NewMemoryDeadLetterWriter()
+	proc := NewProcessor(ingestor, dlq, nil, fastRetry)
+	proc.SetDecoder(codec.NewDecoder(registry, codec.FormatJSON))
+
+	avroValue, err := enc.Encode(context.Background(), codec.FormatAvro, "orders-value-avro", testOrder("avro"))
+	require.NoError(t, err)
+	protoValue, err := enc.Encode(context.Background(), codec.FormatProtobuf, "orders-value-protobuf", testOrder("proto"))
+	require.NoError(t, err)
+
+	msgs := []Message{
+		{Topic: "orders", Value: avroValue},
+		{Topic: "orders", Value: protoValue, Headers: []Header{{Key: HeaderMessageFormat, Value: []byte("protobuf")}}},
+		{Topic: "orders", Value: avroValue, Headers: []Header{{Key: HeaderMessageFormat, Value: []byte("protobuf")}}},
+		{Topic: "orders", Value: avroValue, Headers: []Header{{Key: HeaderMessageFormat, Value: []byte("xml")}}},
+	}
+	var outcomes []Outcome
+	for _, m := range msgs {
+		outcome, err := proc.Handle(context.Background(), m)
+		require.NoError(t, err)
+		outcomes = append(outcomes, outcome)
+	}
+
+	assert.Equal(t, []Outcome{OutcomeInserted, OutcomeInserted, OutcomeInvalidPayload, OutcomeInvalidPayload}, outcomes)
+	assert.Equal(t, model.StatusCreated, ingestor.status("avro"))
+	assert.Equal(t, model.StatusCreated, ingestor.status("proto"))
+	assert.Len(t, dlq.Letters(), 2)
+}
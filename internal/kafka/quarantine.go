@@ -0,0 +1,158 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+
+	"order-service-wbtech/internal/model"
+)
+
+// ErrTopicNotSubscribed — a rejected message cannot be replayed because no
+// processor is registered for its topic
+var ErrTopicNotSubscribed = errors.New("topic is not subscribed")
+
+// outcomeReplayFailed is recorded when a replay failed without an outcome,
+// e.g. because the database was unavailable
+const outcomeReplayFailed = "failed"
+
+// QuarantineStore keeps rejected messages for review, implemented by *storage.Postgres
+type QuarantineStore interface {
+	SaveRejectedMessage(ctx context.Context, msg *model.RejectedMessage) error
+	GetRejectedMessage(ctx context.Context, id int64) (*model.RejectedMessage, error)
+	ListRejectedMessages(ctx context.Context, filter model.RejectedFilter) ([]model.RejectedMessage, error)
+	MarkRejectedMessageReplayed(ctx context.Context, id int64, outcome, replayErr string) error
+}
+
+// Quarantine — DeadLetterWriter that stores rejected messages in Postgres, where
+// they can be reviewed and replayed through the pipeline once the cause is fixed
+type Quarantine struct {
+	store    QuarantineStore
+	registry *Registry
+}
+
+func NewQuarantine(store QuarantineStore) *Quarantine {
+	return &Quarantine{store: store, registry: NewRegistry()}
+}
+
+// SetRegistry sets the processors used to replay messages by topic. The
+// processors usually write to the quarantine themselves, so it is set afterwards.
+func (q *Quarantine) SetRegistry(registry *Registry) {
+	q.registry = registry
+}
+
+func (q *Quarantine) WriteDeadLetter(ctx context.Context, dl DeadLetter) error {
+	msg := rejectedMessage(dl)
+	if err := q.store.SaveRejectedMessage(ctx, msg); err != nil {
+		return fmt.Errorf("quarantine message (offset %d): %w", dl.Message.Offset, err)
+	}
+	return nil
+}
+
+// List returns rejected messages matching filter, newest first
+func (q *Quarantine) List(ctx context.Context, filter model.RejectedFilter) ([]model.RejectedMessage, error) {
+	return q.store.ListRejectedMessages(ctx, filter)
+}
+
+// Get returns a rejected message by ID
+func (q *Quarantine) Get(ctx context.Context, id int64) (*model.RejectedMessage, error) {
+	return q.store.GetRejectedMessage(ctx, id)
+}
+
+// Replay resubmits a rejected message to the processor of its topic and records
+// the outcome. A message that is rejected again stays in the quarantine with the
+// new error; it is not quarantined a second time.
+func (q *Quarantine) Replay(ctx context.Context, id int64) (*model.RejectedMessage, error) {
+	msg, err := q.store.GetRejectedMessage(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	route, ok := q.registry.Lookup(msg.Topic)
+	if !ok {
+		return nil, fmt.Errorf("replay rejected message %d from %s: %w", id, msg.Topic, ErrTopicNotSubscribed)
+	}
+
+	outcome, err := route.Processor.Resubmit(ctx, fromRejectedMessage(msg))
+	if err != nil && ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+
+	var replayErr string
+	if err != nil {
+		replayErr = err.Error()
+		if outcome == "" {
+			outcome = outcomeReplayFailed
+		}
+	}
+	log.Printf("Replayed rejected message %d (%s/%d/%d): outcome=%s err=%v", id, msg.Topic, msg.Partition, msg.Offset, outcome, err)
+
+	if err := q.store.MarkRejectedMessageReplayed(ctx, id, string(outcome), replayErr); err != nil {
+		return nil, err
+	}
+	return q.store.GetRejectedMessage(ctx, id)
+}
+
+func rejectedMessage(dl DeadLetter) *model.RejectedMessage {
+	headers := make([]model.MessageHeader, len(dl.Message.Headers))
+	for i, h := range dl.Message.Headers {
+		headers[i] = model.MessageHeader{Key: h.Key, Value: h.Value}
+	}
+
+	return &model.RejectedMessage{
+		Topic:       dl.Message.Topic,
+		Partition:   dl.Message.Partition,
+		Offset:      dl.Message.Offset,
+		Key:         string(dl.Message.Key),
+		Headers:     headers,
+		Payload:     dl.Message.Value,
+		Stage:       dl.Stage,
+		Error:       dl.Reason,
+		FieldErrors: dl.FieldErrors,
+		Attempts:    dl.Attempts,
+		ReceivedAt:  dl.FailedAt,
+	}
+}
+
+func fromRejectedMessage(msg *model.RejectedMessage) Message {
+	headers := make([]Header, len(msg.Headers))
+	for i, h := range msg.Headers {
+		headers[i] = Header{Key: h.Key, Value: h.Value}
+	}
+
+	return Message{
+		Topic:     msg.Topic,
+		Partition: msg.Partition,
+		Offset:    msg.Offset,
+		Key:       []byte(msg.Key),
+		Value:     msg.Payload,
+		Headers:   headers,
+		Time:      msg.ReceivedAt,
+	}
+}
+
+// MultiDeadLetterWriter writes every dead letter to all writers in order, e.g.
+// to the quarantine table and the Kafka DLQ; nil writers are skipped. The first
+// failure stops the write and the retry starts over, so writers that are safe to
+// repeat go first: the Quarantine stores a message once per position.
+func MultiDeadLetterWriter(writers ...DeadLetterWriter) DeadLetterWriter {
+	var out multiDeadLetterWriter
+	for _, w := range writers {
+		if w != nil {
+			out = append(out, w)
+		}
+	}
+	return out
+}
+
+type multiDeadLetterWriter []DeadLetterWriter
+
+func (m multiDeadLetterWriter) WriteDeadLetter(ctx context.Context, dl DeadLetter) error {
+	for _, w := range m {
+		if err := w.WriteDeadLetter(ctx, dl); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,219 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"order-service-wbtech/internal/mocks"
+	"order-service-wbtech/internal/model"
+	"order-service-wbtech/internal/service"
+)
+
+// memoryQuarantine — QuarantineStore that keeps messages in a map, like the
+// rejected_messages table: a message is stored once per topic, partition and offset
+type memoryQuarantine struct {
+	mu   sync.Mutex
+	msgs map[int64]model.RejectedMessage
+	next int64
+}
+
+func newMemoryQuarantine() *memoryQuarantine {
+	return &memoryQuarantine{msgs: make(map[int64]model.RejectedMessage)}
+}
+
+func (q *memoryQuarantine) SaveRejectedMessage(_ context.Context, msg *model.RejectedMessage) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for id, stored := range q.msgs {
+		if stored.Topic == msg.Topic && stored.Partition == msg.Partition && stored.Offset == msg.Offset {
+			msg.ID = id
+			return nil
+		}
+	}
+	q.next++
+	msg.ID = q.next
+	q.msgs[msg.ID] = *msg
+	return nil
+}
+
+func (q *memoryQuarantine) GetRejectedMessage(_ context.Context, id int64) (*model.RejectedMessage, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	msg, ok := q.msgs[id]
+	if !ok {
+		return nil, fmt.Errorf("rejected message %d: %w", id, model.ErrNotFound)
+	}
+	return &msg, nil
+}
+
+func (q *memoryQuarantine) ListRejectedMessages(_ context.Context, _ model.RejectedFilter) ([]model.RejectedMessage, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	var out []model.RejectedMessage
+	for id := q.next; id > 0; id-- {
+		out = append(out, q.msgs[id])
+	}
+	return out, nil
+}
+
+func (q *memoryQuarantine) MarkRejectedMessageReplayed(_ context.Context, id int64, outcome, replayErr string) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	msg, ok := q.msgs[id]
+	if !ok {
+		return model.ErrNotFound
+	}
+	now := time.Now()
+	msg.ReplayedAt, msg.ReplayOutcome, msg.ReplayError = &now, outcome, replayErr
+	q.msgs[id] = msg
+	return nil
+}
+
+// quarantineProcessor builds a processor for "orders" that rejects into the quarantine
+func quarantineProcessor(t *testing.T, store QuarantineStore) (*Quarantine, *mocks.Storage) {
+	t.Helper()
+
+	dbMock := &mocks.Storage{}
+	cacheMock := &mocks.Cache{}
+	cacheMock.On("Set", mock.Anything).Return()
+
+	quarantine := NewQuarantine(store)
+	registry := NewRegistry()
+	proc := NewProcessor(service.New(cacheMock, dbMock), quarantine, nil, fastRetry)
+	require.NoError(t, registry.Register(Route{Topic: "orders", Processor: proc, Workers: 1}))
+	quarantine.SetRegistry(registry)
+
+	return quarantine, dbMock
+}
+
+func TestQuarantine_StoresRejectedMessages(t *testing.T) {
+	store := newMemoryQuarantine()
+	quarantine, dbMock := quarantineProcessor(t, store)
+	proc, _ := quarantine.registry.Lookup("orders")
+
+	order := testOrder("bad-currency")
+	order.Payment.Currency = ""
+	msg := testMessage(t, order, 5)
+	msg.Partition = 1
+	msg.Headers = []Header{{Key: "source", Value: []byte("test")}, {Key: "trace", Value: []byte{0xff, 0x00, 0x80}}}
+
+	outcome, err := proc.Processor.Handle(context.Background(), msg)
+	require.NoError(t, err)
+	assert.Equal(t, OutcomeInvalidOrder, outcome)
+	dbMock.AssertNotCalled(t, "SaveOrder")
+
+	rejected, err := quarantine.Get(context.Background(), 1)
+	require.NoError(t, err)
+	assert.Equal(t, "orders", rejected.Topic)
+	assert.Equal(t, 1, rejected.Partition)
+	assert.Equal(t, int64(5), rejected.Offset)
+	assert.Equal(t, "bad-currency", rejected.Key)
+	assert.Equal(t, msg.Value, rejected.Payload)
+	assert.Equal(t, []model.MessageHeader{{Key: "source", Value: []byte("test")}, {Key: "trace", Value: []byte{0xff, 0x00, 0x80}}}, rejected.Headers)
+	assert.Equal(t, msg.Headers, fromRejectedMessage(rejected).Headers, "binary header values survive a replay")
+	assert.Equal(t, StageValidate, rejected.Stage)
+	assert.Contains(t, rejected.FieldErrors, model.FieldError{Field: "payment.currency", Message: "is required"})
+	assert.False(t, rejected.ReceivedAt.IsZero())
+}
+
+func TestQuarantine_ReplayAfterFix(t *testing.T) {
+	store := newMemoryQuarantine()
+	quarantine, dbMock := quarantineProcessor(t, store)
+	dbMock.On("SaveOrder", mock.Anything, mock.Anything).Return(nil)
+
+	// the message was rejected by a bug that has since been fixed
+	msg := testMessage(t, testOrder("fixed"), 9)
+	require.NoError(t, store.SaveRejectedMessage(context.Background(), rejectedMessage(DeadLetter{
+		Message: msg, Stage: StageValidate, Reason: "validation failed", FailedAt: time.Now(),
+	})))
+
+	replayed, err := quarantine.Replay(context.Background(), 1)
+	require.NoError(t, err)
+	assert.Equal(t, string(OutcomeInserted), replayed.ReplayOutcome)
+	assert.Empty(t, replayed.ReplayError)
+	require.NotNil(t, replayed.ReplayedAt)
+	dbMock.AssertNumberOfCalls(t, "SaveOrder", 1)
+}
+
+func TestQuarantine_ReplayStillInvalid(t *testing.T) {
+	store := newMemoryQuarantine()
+	quarantine, dbMock := quarantineProcessor(t, store)
+
+	msg := Message{Topic: "orders", Offset: 3, Value: []byte("{not json")}
+	require.NoError(t, quarantine.WriteDeadLetter(context.Background(), DeadLetter{
+		Message: msg, Stage: StageDecode, Reason: "bad json", FailedAt: time.Now(),
+	}))
+
+	replayed, err := quarantine.Replay(context.Background(), 1)
+	require.NoError(t, err)
+	assert.Equal(t, string(OutcomeInvalidPayload), replayed.ReplayOutcome)
+	assert.Contains(t, replayed.ReplayError, "rejected at "+StageDecode)
+	assert.Len(t, store.msgs, 1, "a message rejected again is not quarantined twice")
+	dbMock.AssertNotCalled(t, "SaveOrder")
+}
+
+func TestQuarantine_ReplayErrors(t *testing.T) {
+	store := newMemoryQuarantine()
+	quarantine, _ := quarantineProcessor(t, store)
+
+	_, err := quarantine.Replay(context.Background(), 42)
+	assert.ErrorIs(t, err, model.ErrNotFound)
+
+	require.NoError(t, store.SaveRejectedMessage(context.Background(), &model.RejectedMessage{Topic: "unknown"}))
+	_, err = quarantine.Replay(context.Background(), 1)
+	assert.ErrorIs(t, err, ErrTopicNotSubscribed)
+}
+
+type failingDeadLetterWriter struct{}
+
+func (failingDeadLetterWriter) WriteDeadLetter(context.Context, DeadLetter) error {
+	return errors.New("broker unavailable")
+}
+
+// flakyDeadLetterWriter fails the first fails writes
+type flakyDeadLetterWriter struct {
+	*MemoryDeadLetterWriter
+	fails int
+}
+
+func (w *flakyDeadLetterWriter) WriteDeadLetter(ctx context.Context, dl DeadLetter) error {
+	if w.fails > 0 {
+		w.fails--
+		return errors.New("broker unavailable")
+	}
+	return w.MemoryDeadLetterWriter.WriteDeadLetter(ctx, dl)
+}
+
+func TestMultiDeadLetterWriter(t *testing.T) {
+	first, second := NewMemoryDeadLetterWriter(), NewMemoryDeadLetterWriter()
+	dl := DeadLetter{Message: Message{Offset: 1}, Stage: StageDecode}
+
+	require.NoError(t, MultiDeadLetterWriter(first, nil, second).WriteDeadLetter(context.Background(), dl))
+	assert.Len(t, first.Letters(), 1)
+	assert.Len(t, second.Letters(), 1)
+}
+
+func TestMultiDeadLetterWriter_RetryStartsOver(t *testing.T) {
+	store := newMemoryQuarantine()
+	flaky := &flakyDeadLetterWriter{MemoryDeadLetterWriter: NewMemoryDeadLetterWriter(), fails: 1}
+	last := NewMemoryDeadLetterWriter()
+	w := MultiDeadLetterWriter(NewQuarantine(store), flaky, last)
+	dl := DeadLetter{Message: Message{Topic: "orders", Offset: 7}, Stage: StageDecode}
+
+	require.Error(t, w.WriteDeadLetter(context.Background(), dl))
+	assert.Len(t, store.msgs, 1)
+	assert.Empty(t, last.Letters(), "the first failure stops the write")
+
+	require.NoError(t, w.WriteDeadLetter(context.Background(), dl))
+	assert.Len(t, store.msgs, 1, "the quarantine stores the retried message once")
+	assert.Len(t, flaky.Letters(), 1)
+	assert.Len(t, last.Letters(), 1)
+}
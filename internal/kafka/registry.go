@@ -0,0 +1,86 @@
+package kafka
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Route — a subscribed topic, the processor that decodes and handles its
+// messages and the size of its worker pool
+type Route struct {
+	Topic     string
+	Processor *Processor
+	Workers   int
+}
+
+// Registry maps subscribed topics to their routes
+type Registry struct {
+	routes []Route
+}
+
+func NewRegistry() *Registry {
+	return &Registry{}
+}
+
+// Register subscribes to route.Topic; a topic can be registered only once
+func (r *Registry) Register(route Route) error {
+	if route.Topic == "" {
+		return fmt.Errorf("register route: topic is required")
+	}
+	if route.Processor == nil {
+		return fmt.Errorf("register route %s: processor is required", route.Topic)
+	}
+	if _, ok := r.Lookup(route.Topic); ok {
+		return fmt.Errorf("register route %s: topic already registered", route.Topic)
+	}
+	r.routes = append(r.routes, route)
+	return nil
+}
+
+// Lookup returns the route of a topic
+func (r *Registry) Lookup(topic string) (Route, bool) {
+	for _, route := range r.routes {
+		if route.Topic == topic {
+			return route, true
+		}
+	}
+	return Route{}, false
+}
+
+// Routes returns the routes in registration order
+func (r *Registry) Routes() []Route {
+	return append([]Route(nil), r.routes...)
+}
+
+// StartConsumers runs a consumer for every registered topic in the same group
+// until ctx is cancelled. batchSize > 1 runs them in batch mode; control, if
+// not nil, pauses and drains all of them together, and throttle, if not nil,
+// limits their combined ingestion rate. tuning applies to every reader and
+// worker pool.
+func StartConsumers(
+	ctx context.Context,
+	cluster Cluster,
+	groupID string,
+	registry *Registry,
+	batchSize int,
+	batchTimeout time.Duration,
+	control *Control,
+	throttle *Throttle,
+	tuning Tuning,
+) {
+	var wg sync.WaitGroup
+	for _, route := range registry.Routes() {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if batchSize > 1 {
+				StartBatchConsumer(ctx, cluster, route.Topic, groupID, route.Processor, batchSize, batchTimeout, control, throttle, tuning)
+				return
+			}
+			StartConsumerWithWorkerPool(ctx, cluster, route.Topic, groupID, route.Processor, route.Workers, control, throttle, tuning)
+		}()
+	}
+	wg.Wait()
+}
@@ -0,0 +1,98 @@
+package kafka
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"order-service-wbtech/internal/model"
+)
+
+func TestRegistry_Register(t *testing.T) {
+	proc := NewProcessor(newMemoryIngestor(), nil, nil, fastRetry)
+	registry := NewRegistry()
+
+	require.NoError(t, registry.Register(Route{Topic: "orders", Processor: proc, Workers: 4}))
+	require.NoError(t, registry.Register(Route{Topic: "order-status", Processor: proc, Workers: 2}))
+	assert.Error(t, registry.Register(Route{Topic: "orders", Processor: proc}), "duplicate topic")
+	assert.Error(t, registry.Register(Route{Topic: "payments"}), "missing processor")
+	assert.Error(t, registry.Register(Route{Processor: proc}), "missing topic")
+
+	route, ok := registry.Lookup("order-status")
+	require.True(t, ok)
+	assert.Equal(t, 2, route.Workers)
+
+	_, ok = registry.Lookup("payments")
+	assert.False(t, ok)
+
+	topics := []string{}
+	for _, r := range registry.Routes() {
+		topics = append(topics, r.Topic)
+	}
+	assert.Equal(t, []string{"orders", "order-status"}, topics)
+}
+
+func TestProcessor_SetDefaultEvent(t *testing.T) {
+	proc := NewProcessor(newMemoryIngestor(), nil, nil, fastRetry)
+
+	assert.NoError(t, proc.SetDefaultEvent(EventPaymentConfirmed))
+	assert.Error(t, proc.SetDefaultEvent("order_deleted"))
+	assert.Equal(t, EventPaymentConfirmed, proc.defaultEvent)
+}
+
+func paymentMessage(t *testing.T, topic, orderUID string) Message {
+	t.Helper()
+
+	b, err := json.Marshal(model.PaymentConfirmation{
+		OrderUID:    orderUID,
+		Transaction: orderUID,
+		Amount:      1817,
+		ConfirmedAt: time.Now(),
+	})
+	require.NoError(t, err)
+	return Message{Topic: topic, Key: []byte(orderUID), Value: b}
+}
+
+// TestConsumer_PerTopicHandlers runs a consumer per topic over the same ingestor:
+// messages without an event type header are handled by the default event of their topic
+func TestConsumer_PerTopicHandlers(t *testing.T) {
+	ingestor := newMemoryIngestor()
+	dlq := NewMemoryDeadLetterWriter()
+
+	orders := NewProcessor(ingestor, dlq, nil, fastRetry)
+	payments := NewProcessor(ingestor, dlq, nil, fastRetry)
+	require.NoError(t, payments.SetDefaultEvent(EventPaymentConfirmed))
+
+	orderSource := NewMemorySource()
+	orderSource.Publish(testMessage(t, testOrder("a"), 0), testMessage(t, testOrder("b"), 0))
+	runUntilCommitted(t, orderSource, 2, NewConsumer(orderSource, orders, 2).Run)
+
+	paymentSource := NewMemorySource()
+	paymentSource.Publish(
+		paymentMessage(t, "payments", "a"),
+		Message{Topic: "payments", Value: []byte(`{"order_uid":"b"}`)},
+	)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		NewConsumer(paymentSource, payments, 1).Run(ctx)
+	}()
+	require.Eventually(t, func() bool {
+		return paymentSource.Committed("payments", 0) == 2
+	}, 2*time.Second, 5*time.Millisecond)
+	cancel()
+	wg.Wait()
+
+	assert.Equal(t, model.StatusPaid, ingestor.status("a"))
+	assert.Equal(t, model.StatusCreated, ingestor.status("b"))
+	require.Len(t, dlq.Letters(), 1)
+	assert.Equal(t, StageValidate, dlq.Letters()[0].Stage)
+}
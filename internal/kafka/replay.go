@@ -0,0 +1,289 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// ReplayConfig — what to reprocess. Replay reads partitions directly, without a
+// consumer group, so the offsets of the running service are left untouched.
+type ReplayConfig struct {
+	Cluster Cluster
+	Topic   string
+	// Partitions to replay; empty means all partitions of Topic
+	Partitions []int
+	// FromOffset is the first offset to replay in every partition; negative means
+	// the oldest retained offset. Ignored when FromTime is set.
+	FromOffset int64
+	// PartitionOffsets overrides FromOffset per partition, since offsets of
+	// different partitions are unrelated. With no Partitions, only the
+	// partitions listed here are replayed.
+	PartitionOffsets map[int]int64
+	// FromTime replays messages written at or after this time
+	FromTime time.Time
+}
+
+// ReplayStats — how the replayed messages were handled
+type ReplayStats struct {
+	Inserted int
+	Updated  int
+	Skipped  int
+	Failed   int
+}
+
+func (s *ReplayStats) add(outcome Outcome) {
+	switch outcome {
+	case OutcomeInserted:
+		s.Inserted++
+	case OutcomeStatusUpdated:
+		s.Updated++
+	case OutcomeDuplicate, OutcomeStale:
+		s.Skipped++
+	default:
+		s.Failed++
+	}
+}
+
+func (s *ReplayStats) merge(other ReplayStats) {
+	s.Inserted += other.Inserted
+	s.Updated += other.Updated
+	s.Skipped += other.Skipped
+	s.Failed += other.Failed
+}
+
+// Replay reprocesses cfg.Topic through proc from the requested position up to the
+// end offset each partition had when the replay started. Saves are idempotent, so
+// orders that are already stored are counted as skipped.
+func Replay(ctx context.Context, cfg ReplayConfig, proc *Processor) (ReplayStats, error) {
+	if len(cfg.Cluster.Brokers) == 0 || cfg.Topic == "" {
+		return ReplayStats{}, errors.New("replay: brokers and topic are required")
+	}
+
+	partitions := cfg.Partitions
+	if len(partitions) == 0 {
+		for partition := range cfg.PartitionOffsets {
+			partitions = append(partitions, partition)
+		}
+		slices.Sort(partitions)
+	}
+	if len(partitions) == 0 {
+		var err error
+		if partitions, err = topicPartitions(ctx, cfg.Cluster, cfg.Topic); err != nil {
+			return ReplayStats{}, err
+		}
+	}
+
+	var (
+		mu       sync.Mutex
+		total    ReplayStats
+		firstErr error
+		wg       sync.WaitGroup
+	)
+	for _, partition := range partitions {
+		wg.Add(1)
+		go func(partition int) {
+			defer wg.Done()
+
+			stats, err := replayPartition(ctx, cfg, partition, proc)
+
+			mu.Lock()
+			defer mu.Unlock()
+			total.merge(stats)
+			if err != nil && firstErr == nil {
+				firstErr = fmt.Errorf("partition %d: %w", partition, err)
+			}
+		}(partition)
+	}
+	wg.Wait()
+
+	return total, firstErr
+}
+
+func replayPartition(ctx context.Context, cfg ReplayConfig, partition int, proc *Processor) (ReplayStats, error) {
+	conn, err := cfg.Cluster.Dialer().DialLeader(ctx, "tcp", cfg.Cluster.Brokers[0], cfg.Topic, partition)
+	if err != nil {
+		return ReplayStats{}, fmt.Errorf("dial partition leader: %w", err)
+	}
+	defer conn.Close()
+
+	start, end, err := replayRange(conn, cfg, partition)
+	if err != nil {
+		return ReplayStats{}, err
+	}
+	if start >= end {
+		log.Printf("Replay partition %d: nothing to replay (start=%d end=%d)", partition, start, end)
+		return ReplayStats{}, nil
+	}
+
+	log.Printf("Replay partition %d: offsets %d..%d", partition, start, end-1)
+	if _, err := conn.Seek(start, kafka.SeekAbsolute); err != nil {
+		return ReplayStats{}, fmt.Errorf("seek to %d: %w", start, err)
+	}
+	return replayUntil(ctx, &partitionSource{conn: conn, pos: start}, proc, end)
+}
+
+// replaySource — a MessageSource over one partition that reports the next
+// offset to read. The position also moves past offsets that never arrive as
+// messages, such as compacted records and transaction markers.
+type replaySource interface {
+	FetchMessage(ctx context.Context) (Message, error)
+	Position() int64
+}
+
+// errBatchDrained — a fetched batch ended without another message; the
+// position may have moved, so the caller checks it before fetching again
+var errBatchDrained = errors.New("batch drained")
+
+// replayUntil handles messages of source one by one until its position reaches
+// end. Nothing is committed.
+func replayUntil(ctx context.Context, source replaySource, proc *Processor, end int64) (ReplayStats, error) {
+	var stats ReplayStats
+	for source.Position() < end {
+		m, err := source.FetchMessage(ctx)
+		if errors.Is(err, errBatchDrained) {
+			continue
+		}
+		if err != nil {
+			return stats, err
+		}
+		if m.Offset >= end {
+			return stats, nil
+		}
+
+		outcome, err := proc.Handle(ctx, m)
+		if err != nil {
+			if ctx.Err() != nil {
+				return stats, ctx.Err()
+			}
+			log.Printf("Replay failed for offset=%d partition=%d: %v", m.Offset, m.Partition, err)
+			outcome = ""
+		}
+		stats.add(outcome)
+	}
+	return stats, nil
+}
+
+// replayFetchWait bounds a single fetch of partitionSource
+const replayFetchWait = 5 * time.Second
+
+// partitionSource reads one partition batch by batch from its leader. Unlike a
+// kafka-go Reader, it exposes the batch position, which skips records that are
+// not delivered.
+type partitionSource struct {
+	conn  *kafka.Conn
+	batch *kafka.Batch
+	pos   int64
+}
+
+func (s *partitionSource) Position() int64 {
+	return s.pos
+}
+
+func (s *partitionSource) FetchMessage(ctx context.Context) (Message, error) {
+	if err := ctx.Err(); err != nil {
+		return Message{}, err
+	}
+	if s.batch == nil {
+		deadline := time.Now().Add(replayFetchWait)
+		if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+			deadline = d
+		}
+		if err := s.conn.SetReadDeadline(deadline); err != nil {
+			return Message{}, err
+		}
+		s.batch = s.conn.ReadBatchWith(kafka.ReadBatchConfig{MinBytes: 1, MaxBytes: 10e6, MaxWait: replayFetchWait})
+	}
+
+	m, err := s.batch.ReadMessage()
+	s.pos = max(s.pos, s.batch.Offset())
+	if err == nil {
+		return fromKafkaMessage(m), nil
+	}
+
+	closeErr := s.batch.Close()
+	s.batch = nil
+	if closeErr != nil && !errors.Is(closeErr, kafka.RequestTimedOut) {
+		return Message{}, closeErr
+	}
+	return Message{}, errBatchDrained
+}
+
+// replayRange resolves the first offset to replay and the end offset (exclusive)
+// of a partition
+func replayRange(conn *kafka.Conn, cfg ReplayConfig, partition int) (start, end int64, err error) {
+	first, end, err := conn.ReadOffsets()
+	if err != nil {
+		return 0, 0, fmt.Errorf("read offsets: %w", err)
+	}
+
+	from := cfg.FromOffset
+	if offset, ok := cfg.PartitionOffsets[partition]; ok {
+		from = offset
+	}
+
+	switch {
+	case !cfg.FromTime.IsZero():
+		if start, err = conn.ReadOffset(cfg.FromTime); err != nil {
+			return 0, 0, fmt.Errorf("read offset at %s: %w", cfg.FromTime, err)
+		}
+	case from < 0:
+		start = first
+	default:
+		start = max(from, first)
+	}
+	return start, end, nil
+}
+
+// ParsePartitionOffsets parses per-partition offsets given as
+// "partition:offset" pairs, e.g. "0:1200,1:900"
+func ParsePartitionOffsets(s string) (map[int]int64, error) {
+	offsets := make(map[int]int64)
+	for _, pair := range strings.Split(s, ",") {
+		partition, offset, ok := strings.Cut(strings.TrimSpace(pair), ":")
+		if !ok {
+			return nil, fmt.Errorf("invalid partition offset %q, expected partition:offset", pair)
+		}
+		p, err := strconv.Atoi(partition)
+		if err != nil || p < 0 {
+			return nil, fmt.Errorf("invalid partition in %q", pair)
+		}
+		o, err := strconv.ParseInt(offset, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid offset in %q", pair)
+		}
+		if _, dup := offsets[p]; dup {
+			return nil, fmt.Errorf("partition %d is listed twice", p)
+		}
+		offsets[p] = o
+	}
+	return offsets, nil
+}
+
+// topicPartitions lists the partition IDs of topic
+func topicPartitions(ctx context.Context, cluster Cluster, topic string) ([]int, error) {
+	conn, err := cluster.Dialer().DialContext(ctx, "tcp", cluster.Brokers[0])
+	if err != nil {
+		return nil, fmt.Errorf("dial broker: %w", err)
+	}
+	defer conn.Close()
+
+	partitions, err := conn.ReadPartitions(topic)
+	if err != nil {
+		return nil, fmt.Errorf("read partitions of %s: %w", topic, err)
+	}
+
+	ids := make([]int, len(partitions))
+	for i, p := range partitions {
+		ids[i] = p.ID
+	}
+	return ids, nil
+}
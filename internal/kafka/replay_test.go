@@ -0,0 +1,118 @@
+package kafka
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"order-service-wbtech/internal/model"
+)
+
+func TestReplayUntil_CountsOutcomesAndStopsAtEnd(t *testing.T) {
+	source := NewMemorySource()
+	ingestor := newMemoryIngestor()
+	require.NoError(t, ingestor.CreateOrder(context.Background(), testOrder("stored")))
+	proc := NewProcessor(ingestor, NewMemoryDeadLetterWriter(), nil, fastRetry)
+
+	source.Publish(
+		testMessage(t, testOrder("stored"), 0),
+		testMessage(t, testOrder("lost"), 0),
+		statusMessage(t, "lost", model.StatusPaid),
+		Message{Topic: "orders", Value: []byte("not json")},
+		testMessage(t, testOrder("after-end"), 0),
+	)
+
+	stats, err := replayUntil(context.Background(), &memoryReplaySource{MemorySource: source}, proc, 4)
+	require.NoError(t, err)
+
+	assert.Equal(t, ReplayStats{Inserted: 1, Updated: 1, Skipped: 1, Failed: 1}, stats)
+	assert.Equal(t, model.StatusPaid, ingestor.status("lost"))
+	assert.Equal(t, model.OrderStatus(""), ingestor.status("after-end"))
+	assert.Equal(t, int64(0), source.Committed("orders", 0), "replay does not commit")
+}
+
+// memoryReplaySource — replaySource over a MemorySource without gaps: the
+// position is the offset after the last fetched message
+type memoryReplaySource struct {
+	*MemorySource
+	pos int64
+}
+
+func (s *memoryReplaySource) FetchMessage(ctx context.Context) (Message, error) {
+	m, err := s.MemorySource.FetchMessage(ctx)
+	if err == nil {
+		s.pos = m.Offset + 1
+	}
+	return m, err
+}
+
+func (s *memoryReplaySource) Position() int64 {
+	return s.pos
+}
+
+// gappySource — replaySource replaying a partition with compacted offsets:
+// each batch is a list of messages and the position after the batch
+type gappySource struct {
+	batches [][]Message
+	ends    []int64
+	pos     int64
+	fetches int
+}
+
+func (s *gappySource) FetchMessage(ctx context.Context) (Message, error) {
+	s.fetches++
+	if len(s.batches) == 0 {
+		<-ctx.Done()
+		return Message{}, ctx.Err()
+	}
+	if len(s.batches[0]) == 0 {
+		s.pos = s.ends[0]
+		s.batches, s.ends = s.batches[1:], s.ends[1:]
+		return Message{}, errBatchDrained
+	}
+	m := s.batches[0][0]
+	s.batches[0] = s.batches[0][1:]
+	s.pos = m.Offset + 1
+	return m, nil
+}
+
+func (s *gappySource) Position() int64 {
+	return s.pos
+}
+
+func TestReplayUntil_StopsAtPositionPastGaps(t *testing.T) {
+	ingestor := newMemoryIngestor()
+	proc := NewProcessor(ingestor, NewMemoryDeadLetterWriter(), nil, fastRetry)
+
+	first := testMessage(t, testOrder("first"), 0)
+	last := testMessage(t, testOrder("last"), 0)
+	last.Offset = 3
+	// offset 2 was compacted away and offset 4 is a transaction marker, so no
+	// message has offset end-1
+	source := &gappySource{
+		batches: [][]Message{{first, last}},
+		ends:    []int64{5},
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	stats, err := replayUntil(ctx, source, proc, 5)
+	require.NoError(t, err, "replay must not wait for an offset that never arrives")
+
+	assert.Equal(t, ReplayStats{Inserted: 2}, stats)
+	assert.Equal(t, 3, source.fetches)
+}
+
+func TestParsePartitionOffsets(t *testing.T) {
+	offsets, err := ParsePartitionOffsets("0:1200, 1:900,3:-1")
+	require.NoError(t, err)
+	assert.Equal(t, map[int]int64{0: 1200, 1: 900, 3: -1}, offsets)
+
+	for _, bad := range []string{"1200", "a:1", "0:x", "-1:5", "0:1,0:2"} {
+		_, err := ParsePartitionOffsets(bad)
+		assert.Error(t, err, bad)
+	}
+}
@@ -0,0 +1,145 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math"
+	"math/rand"
+	"net"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgconn"
+
+	"order-service-wbtech/internal/config"
+	"order-service-wbtech/internal/model"
+)
+
+// RetryPolicy — bounded exponential backoff with jitter for transient failures
+type RetryPolicy struct {
+	MaxAttempts    int
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+	Multiplier     float64
+	// Jitter is the fraction of the backoff that is randomized, 0 disables it
+	Jitter float64
+}
+
+// DefaultRetryPolicy returns the policy used when nothing is configured
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		MaxAttempts:    5,
+		InitialBackoff: 200 * time.Millisecond,
+		MaxBackoff:     10 * time.Second,
+		Multiplier:     2,
+		Jitter:         0.2,
+	}
+}
+
+// RetryPolicyFromConfig returns the retry policy of a subscription
+func RetryPolicyFromConfig(sub config.Subscription) RetryPolicy {
+	return RetryPolicy{
+		MaxAttempts:    sub.RetryMaxAttempts,
+		InitialBackoff: sub.RetryInitialBackoff,
+		MaxBackoff:     sub.RetryMaxBackoff,
+		Multiplier:     sub.RetryMultiplier,
+		Jitter:         sub.RetryJitter,
+	}
+}
+
+// withDefaults fills unset fields from DefaultRetryPolicy
+func (p RetryPolicy) withDefaults() RetryPolicy {
+	def := DefaultRetryPolicy()
+	if p.MaxAttempts <= 0 {
+		p.MaxAttempts = def.MaxAttempts
+	}
+	if p.InitialBackoff <= 0 {
+		p.InitialBackoff = def.InitialBackoff
+	}
+	if p.MaxBackoff <= 0 {
+		p.MaxBackoff = def.MaxBackoff
+	}
+	if p.Multiplier < 1 {
+		p.Multiplier = def.Multiplier
+	}
+	if p.Jitter < 0 || p.Jitter > 1 {
+		p.Jitter = def.Jitter
+	}
+	return p
+}
+
+// Backoff returns the delay before the given retry (attempt starts at 1)
+func (p RetryPolicy) Backoff(attempt int) time.Duration {
+	if attempt < 1 {
+		attempt = 1
+	}
+
+	d := float64(p.InitialBackoff) * math.Pow(p.Multiplier, float64(attempt-1))
+	if d > float64(p.MaxBackoff) {
+		d = float64(p.MaxBackoff)
+	}
+
+	if p.Jitter > 0 {
+		delta := d * p.Jitter
+		d = d - delta + rand.Float64()*2*delta
+	}
+	return time.Duration(d)
+}
+
+// sleep waits for the backoff of the given attempt or until ctx is done
+func (p RetryPolicy) sleep(ctx context.Context, attempt int) error {
+	timer := time.NewTimer(p.Backoff(attempt))
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// IsRetryable — whether err is transient: an unavailable or timed-out database
+// (model.ErrUnavailable, connection and network errors, retryable SQLSTATE
+// classes), a failed DLQ write or a status event ahead of its order. Anything
+// else, unknown errors included, is permanent and parked without retries.
+func IsRetryable(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) {
+		return false
+	}
+
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		return retryableSQLState(pgErr.Code)
+	}
+
+	var connectErr *pgconn.ConnectError
+	var netErr net.Error
+	return errors.Is(err, ErrOrderNotArrived) ||
+		errors.Is(err, ErrDeadLetterFailed) ||
+		errors.Is(err, model.ErrUnavailable) ||
+		errors.Is(err, context.DeadlineExceeded) ||
+		pgconn.Timeout(err) ||
+		errors.As(err, &connectErr) ||
+		errors.As(err, &netErr) ||
+		errors.Is(err, io.EOF) ||
+		errors.Is(err, io.ErrUnexpectedEOF)
+}
+
+// retryableSQLState classifies PostgreSQL errors by SQLSTATE class
+func retryableSQLState(code string) bool {
+	if len(code) < 2 {
+		return false
+	}
+
+	switch code[:2] {
+	case "08", // connection exception
+		"40", // transaction rollback: serialization failure, deadlock
+		"53", // insufficient resources
+		"57", // operator intervention: admin shutdown, query canceled
+		"58": // system error
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,86 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/stretchr/testify/assert"
+
+	"order-service-wbtech/internal/config"
+	"order-service-wbtech/internal/model"
+)
+
+func TestIsRetryable(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"nil", nil, false},
+		{"cancelled", context.Canceled, false},
+		{"deadline", context.DeadlineExceeded, true},
+		{"unique violation", &pgconn.PgError{Code: "23505"}, false},
+		{"invalid text", &pgconn.PgError{Code: "22P02"}, false},
+		{"connection failure", &pgconn.PgError{Code: "08006"}, true},
+		{"serialization failure", &pgconn.PgError{Code: "40001"}, true},
+		{"admin shutdown", &pgconn.PgError{Code: "57P01"}, true},
+		{"wrapped pg error", fmt.Errorf("insert orders: %w", &pgconn.PgError{Code: "23503"}), false},
+		{"no rows", pgx.ErrNoRows, false},
+		{"not found", model.ErrNotFound, false},
+		{"unavailable", fmt.Errorf("%w: %w", model.ErrUnavailable, io.EOF), true},
+		{"network error", &net.OpError{Op: "dial", Err: errors.New("connection refused")}, true},
+		{"order not arrived", fmt.Errorf("%w: %w", ErrOrderNotArrived, model.ErrNotFound), true},
+		{"dead-letter write failed", fmt.Errorf("%w: %w", ErrDeadLetterFailed, errors.New("broker unavailable")), true},
+		{"no dead-letter writer", ErrNoDeadLetterWriter, false},
+		{"stale event", model.ErrStaleEvent, false},
+		{"unknown", errors.New("unexpected"), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, IsRetryable(tt.err))
+		})
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	p := RetryPolicy{
+		MaxAttempts:    5,
+		InitialBackoff: 100 * time.Millisecond,
+		MaxBackoff:     time.Second,
+		Multiplier:     2,
+	}
+
+	assert.Equal(t, 100*time.Millisecond, p.Backoff(1))
+	assert.Equal(t, 200*time.Millisecond, p.Backoff(2))
+	assert.Equal(t, 400*time.Millisecond, p.Backoff(3))
+	assert.Equal(t, time.Second, p.Backoff(10))
+
+	p.Jitter = 0.5
+	for i := 0; i < 100; i++ {
+		d := p.Backoff(2)
+		assert.GreaterOrEqual(t, d, 100*time.Millisecond)
+		assert.LessOrEqual(t, d, 300*time.Millisecond)
+	}
+}
+
+func TestRetryPolicyFromConfig(t *testing.T) {
+	p := RetryPolicyFromConfig(config.Subscription{
+		RetryMaxAttempts:    3,
+		RetryInitialBackoff: 100 * time.Millisecond,
+		RetryMaxBackoff:     time.Second,
+		RetryMultiplier:     3,
+	})
+
+	assert.Equal(t, 3, p.MaxAttempts)
+	// a zero jitter keeps the backoff exact
+	assert.Equal(t, 300*time.Millisecond, p.Backoff(2))
+	assert.Equal(t, 900*time.Millisecond, p.Backoff(3))
+}
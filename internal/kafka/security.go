@@ -0,0 +1,160 @@
+package kafka
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+	"github.com/segmentio/kafka-go/sasl"
+	"github.com/segmentio/kafka-go/sasl/plain"
+	"github.com/segmentio/kafka-go/sasl/scram"
+
+	"order-service-wbtech/internal/config"
+)
+
+// Supported SASL mechanisms
+const (
+	SASLPlain       = "PLAIN"
+	SASLScramSHA256 = "SCRAM-SHA-256"
+	SASLScramSHA512 = "SCRAM-SHA-512"
+)
+
+// Security — TLS and SASL settings of the broker connections. The zero value
+// connects in plaintext without authentication.
+type Security struct {
+	TLS bool
+	// CAFile verifies the brokers instead of the system roots
+	CAFile string
+	// CertFile and KeyFile are the client certificate for mutual TLS
+	CertFile string
+	KeyFile  string
+
+	// SASLMechanism is empty (no SASL), PLAIN, SCRAM-SHA-256 or SCRAM-SHA-512
+	SASLMechanism string
+	Username      string
+	Password      string
+}
+
+// Cluster — the brokers and how readers, writers and admin connections reach them
+type Cluster struct {
+	Brokers   []string
+	dialer    *kafka.Dialer
+	transport *kafka.Transport
+}
+
+// NewCluster loads the certificates and SASL credentials of sec for brokers
+func NewCluster(brokers []string, sec Security) (Cluster, error) {
+	tlsConfig, err := sec.tlsConfig()
+	if err != nil {
+		return Cluster{}, err
+	}
+	mechanism, err := sec.mechanism()
+	if err != nil {
+		return Cluster{}, err
+	}
+
+	return Cluster{
+		Brokers: brokers,
+		dialer: &kafka.Dialer{
+			Timeout:       10 * time.Second,
+			DualStack:     true,
+			TLS:           tlsConfig,
+			SASLMechanism: mechanism,
+		},
+		transport: &kafka.Transport{
+			TLS:  tlsConfig,
+			SASL: mechanism,
+		},
+	}, nil
+}
+
+// ClusterFromConfig builds the cluster of the configured brokers with their TLS
+// and SASL settings; every command connects through it
+func ClusterFromConfig(k config.Kafka) (Cluster, error) {
+	return NewCluster(strings.Split(k.Brokers, ","), Security{
+		TLS:           k.TLSEnabled,
+		CAFile:        k.TLSCAFile,
+		CertFile:      k.TLSCertFile,
+		KeyFile:       k.TLSKeyFile,
+		SASLMechanism: k.SASLMechanism,
+		Username:      k.SASLUsername,
+		Password:      k.SASLPassword,
+	})
+}
+
+// Dialer is used by readers and for metadata and offset requests
+func (c Cluster) Dialer() *kafka.Dialer {
+	if c.dialer == nil {
+		return kafka.DefaultDialer
+	}
+	return c.dialer
+}
+
+// Transport is used by writers
+func (c Cluster) Transport() kafka.RoundTripper {
+	if c.transport == nil {
+		return kafka.DefaultTransport
+	}
+	return c.transport
+}
+
+func (s Security) tlsConfig() (*tls.Config, error) {
+	if !s.TLS {
+		if s.CAFile != "" || s.CertFile != "" || s.KeyFile != "" {
+			return nil, errors.New("kafka tls: certificate files are set but TLS is disabled")
+		}
+		return nil, nil
+	}
+
+	cfg := &tls.Config{MinVersion: tls.VersionTLS12}
+
+	if s.CAFile != "" {
+		pem, err := os.ReadFile(s.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("kafka tls: read CA file: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("kafka tls: no certificates in %s", s.CAFile)
+		}
+		cfg.RootCAs = pool
+	}
+
+	if s.CertFile != "" || s.KeyFile != "" {
+		if s.CertFile == "" || s.KeyFile == "" {
+			return nil, errors.New("kafka tls: client certificate and key must be set together")
+		}
+		cert, err := tls.LoadX509KeyPair(s.CertFile, s.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("kafka tls: load client certificate: %w", err)
+		}
+		cfg.Certificates = []tls.Certificate{cert}
+	}
+
+	return cfg, nil
+}
+
+func (s Security) mechanism() (sasl.Mechanism, error) {
+	if s.SASLMechanism == "" {
+		return nil, nil
+	}
+	if s.Username == "" || s.Password == "" {
+		return nil, fmt.Errorf("kafka sasl: %s requires a username and password", s.SASLMechanism)
+	}
+
+	switch s.SASLMechanism {
+	case SASLPlain:
+		return plain.Mechanism{Username: s.Username, Password: s.Password}, nil
+	case SASLScramSHA256:
+		return scram.Mechanism(scram.SHA256, s.Username, s.Password)
+	case SASLScramSHA512:
+		return scram.Mechanism(scram.SHA512, s.Username, s.Password)
+	default:
+		return nil, fmt.Errorf("kafka sasl: unsupported mechanism %q", s.SASLMechanism)
+	}
+}
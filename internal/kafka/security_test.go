@@ -0,0 +1,123 @@
+package kafka
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"order-service-wbtech/internal/config"
+)
+
+// writeCertificate writes a self-signed certificate and its key as PEM files
+func writeCertificate(t *testing.T) (certFile, keyFile string) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "order-service"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	require.NoError(t, err)
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+
+	dir := t.TempDir()
+	certFile = filepath.Join(dir, "client.pem")
+	keyFile = filepath.Join(dir, "client-key.pem")
+	require.NoError(t, os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600))
+	require.NoError(t, os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600))
+	return certFile, keyFile
+}
+
+func TestNewCluster_Plaintext(t *testing.T) {
+	cluster, err := NewCluster([]string{"kafka:9092"}, Security{})
+	require.NoError(t, err)
+
+	assert.Nil(t, cluster.Dialer().TLS)
+	assert.Nil(t, cluster.Dialer().SASLMechanism)
+
+	// the zero value falls back to the kafka-go defaults
+	assert.Same(t, kafka.DefaultDialer, Cluster{}.Dialer())
+	assert.Equal(t, kafka.DefaultTransport, Cluster{}.Transport())
+}
+
+func TestNewCluster_MutualTLSWithScram(t *testing.T) {
+	certFile, keyFile := writeCertificate(t)
+
+	cluster, err := NewCluster([]string{"kafka:9093"}, Security{
+		TLS:           true,
+		CAFile:        certFile,
+		CertFile:      certFile,
+		KeyFile:       keyFile,
+		SASLMechanism: SASLScramSHA512,
+		Username:      "order-service",
+		Password:      "secret",
+	})
+	require.NoError(t, err)
+
+	dialer := cluster.Dialer()
+	require.NotNil(t, dialer.TLS)
+	assert.Len(t, dialer.TLS.Certificates, 1)
+	assert.NotNil(t, dialer.TLS.RootCAs)
+	require.NotNil(t, dialer.SASLMechanism)
+	assert.Equal(t, SASLScramSHA512, dialer.SASLMechanism.Name())
+
+	transport, ok := cluster.Transport().(*kafka.Transport)
+	require.True(t, ok)
+	assert.Same(t, dialer.TLS, transport.TLS)
+	assert.Equal(t, SASLScramSHA512, transport.SASL.Name())
+}
+
+func TestClusterFromConfig(t *testing.T) {
+	cluster, err := ClusterFromConfig(config.Kafka{
+		Brokers:       "kafka-1:9092,kafka-2:9092",
+		SASLMechanism: SASLPlain,
+		SASLUsername:  "order-service",
+		SASLPassword:  "secret",
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"kafka-1:9092", "kafka-2:9092"}, cluster.Brokers)
+	require.NotNil(t, cluster.Dialer().SASLMechanism)
+	assert.Equal(t, SASLPlain, cluster.Dialer().SASLMechanism.Name())
+
+	_, err = ClusterFromConfig(config.Kafka{Brokers: "kafka:9092", TLSCAFile: "ca.pem"})
+	assert.Error(t, err)
+}
+
+func TestNewCluster_InvalidSecurity(t *testing.T) {
+	certFile, keyFile := writeCertificate(t)
+
+	tests := map[string]Security{
+		"files without tls":    {CertFile: certFile, KeyFile: keyFile},
+		"cert without key":     {TLS: true, CertFile: certFile},
+		"missing ca file":      {TLS: true, CAFile: filepath.Join(t.TempDir(), "ca.pem")},
+		"ca file without pem":  {TLS: true, CAFile: keyFile},
+		"unknown mechanism":    {SASLMechanism: "GSSAPI", Username: "u", Password: "p"},
+		"scram without secret": {SASLMechanism: SASLScramSHA512, Username: "u"},
+	}
+	for name, sec := range tests {
+		t.Run(name, func(t *testing.T) {
+			_, err := NewCluster([]string{"kafka:9093"}, sec)
+			assert.Error(t, err)
+		})
+	}
+}
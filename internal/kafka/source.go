@@ -0,0 +1,124 @@
+package kafka
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Header — a message header
+type Header struct {
+	Key   string
+	Value []byte
+}
+
+// Message — a consumed message, independent of the client library
+type Message struct {
+	Topic         string
+	Partition     int
+	Offset        int64
+	HighWaterMark int64
+	Key           []byte
+	Value         []byte
+	Headers       []Header
+	Time          time.Time
+}
+
+// Header returns the value of the first header with the given key
+func (m Message) Header(key string) ([]byte, bool) {
+	for _, h := range m.Headers {
+		if h.Key == key {
+			return h.Value, true
+		}
+	}
+	return nil, false
+}
+
+// MessageSource — where the consumer fetches messages from and commits them to
+type MessageSource interface {
+	// FetchMessage blocks until the next message is available or ctx is done
+	FetchMessage(ctx context.Context) (Message, error)
+	// CommitMessages marks the messages and everything before them in their partitions as consumed
+	CommitMessages(ctx context.Context, msgs ...Message) error
+	Close() error
+}
+
+// MemorySource — in-memory MessageSource for tests. Published messages are
+// fetched in order; once drained, FetchMessage blocks until more are published.
+type MemorySource struct {
+	mu        sync.Mutex
+	queue     []Message
+	offsets   map[topicPartition]int64
+	committed map[topicPartition]int64
+	notify    chan struct{}
+}
+
+func NewMemorySource() *MemorySource {
+	return &MemorySource{
+		offsets:   make(map[topicPartition]int64),
+		committed: make(map[topicPartition]int64),
+		notify:    make(chan struct{}),
+	}
+}
+
+// Publish appends messages, assigning consecutive offsets per partition
+func (s *MemorySource) Publish(msgs ...Message) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, m := range msgs {
+		tp := topicPartition{m.Topic, m.Partition}
+		m.Offset = s.offsets[tp]
+		s.offsets[tp]++
+		m.HighWaterMark = s.offsets[tp]
+		s.queue = append(s.queue, m)
+	}
+
+	close(s.notify)
+	s.notify = make(chan struct{})
+}
+
+func (s *MemorySource) FetchMessage(ctx context.Context) (Message, error) {
+	for {
+		s.mu.Lock()
+		if len(s.queue) > 0 {
+			m := s.queue[0]
+			s.queue = s.queue[1:]
+			s.mu.Unlock()
+			return m, nil
+		}
+		notify := s.notify
+		s.mu.Unlock()
+
+		select {
+		case <-notify:
+		case <-ctx.Done():
+			return Message{}, ctx.Err()
+		}
+	}
+}
+
+func (s *MemorySource) CommitMessages(_ context.Context, msgs ...Message) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, m := range msgs {
+		tp := topicPartition{m.Topic, m.Partition}
+		if next := m.Offset + 1; next > s.committed[tp] {
+			s.committed[tp] = next
+		}
+	}
+	return nil
+}
+
+// Committed returns the next offset to be consumed for a partition, as Kafka does
+func (s *MemorySource) Committed(topic string, partition int) int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.committed[topicPartition{topic, partition}]
+}
+
+func (s *MemorySource) Close() error {
+	return nil
+}
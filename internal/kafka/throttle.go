@@ -0,0 +1,287 @@
+package kafka
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+)
+
+// TokenBucket — rate limiter that lets rate messages per second through, with
+// bursts of up to burst messages. A rate <= 0 means no limit.
+type TokenBucket struct {
+	mu     sync.Mutex
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+func NewTokenBucket(rate float64, burst int) *TokenBucket {
+	if burst <= 0 {
+		burst = 1
+	}
+	return &TokenBucket{rate: rate, burst: float64(burst), tokens: float64(burst), last: time.Now()}
+}
+
+// SetRate changes the rate; tokens already in the bucket are kept
+func (b *TokenBucket) SetRate(rate float64) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.refill(time.Now())
+	b.rate = rate
+}
+
+// Rate returns the current rate
+func (b *TokenBucket) Rate() float64 {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.rate
+}
+
+// Wait takes n tokens, sleeping until they are available or ctx is done. The
+// bucket can go into debt, so a batch larger than burst waits for the missing
+// tokens instead of blocking forever.
+func (b *TokenBucket) Wait(ctx context.Context, n int) error {
+	b.mu.Lock()
+	if b.rate <= 0 {
+		b.mu.Unlock()
+		return ctx.Err()
+	}
+	now := time.Now()
+	b.refill(now)
+	b.tokens -= float64(n)
+	var delay time.Duration
+	if b.tokens < 0 {
+		delay = time.Duration(-b.tokens / b.rate * float64(time.Second))
+	}
+	b.mu.Unlock()
+
+	if delay == 0 {
+		return ctx.Err()
+	}
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (b *TokenBucket) refill(now time.Time) {
+	if b.rate > 0 {
+		b.tokens = min(b.burst, b.tokens+now.Sub(b.last).Seconds()*b.rate)
+	}
+	b.last = now
+}
+
+// DatabaseHealth reports how the database copes with the load, implemented by *storage.Postgres
+type DatabaseHealth interface {
+	// SaveLatency — average time to save an order since the previous call;
+	// false if nothing was saved
+	SaveLatency() (time.Duration, bool)
+	// PoolWait — average wait for a pool connection since the previous call;
+	// false if no connection was acquired
+	PoolWait() (time.Duration, bool)
+	// Probe times a round trip to the database, including the wait for a connection
+	Probe(ctx context.Context) (time.Duration, error)
+}
+
+// ThrottleLevel — how much ingestion is held back
+type ThrottleLevel int
+
+const (
+	// LevelNormal — messages are fetched at Rate
+	LevelNormal ThrottleLevel = iota
+	// LevelSlowed — the database is slow, messages are fetched at SlowRate
+	LevelSlowed
+	// LevelPaused — the database is overloaded, no messages are fetched
+	LevelPaused
+)
+
+func (l ThrottleLevel) String() string {
+	switch l {
+	case LevelSlowed:
+		return "slowed"
+	case LevelPaused:
+		return "paused"
+	default:
+		return "normal"
+	}
+}
+
+// ThrottleConfig — ingestion rate and the database thresholds of backpressure.
+// A zero threshold is not checked.
+type ThrottleConfig struct {
+	// Rate is the ingestion limit in messages per second, 0 means unlimited
+	Rate  float64
+	Burst int
+	// SlowRate is the limit while the database is slow
+	SlowRate float64
+
+	SlowSaveLatency  time.Duration
+	SlowPoolWait     time.Duration
+	PauseSaveLatency time.Duration
+	PausePoolWait    time.Duration
+
+	// Interval is how often the database health is checked
+	Interval time.Duration
+}
+
+// Throttle limits the ingestion rate of all consumers sharing it and holds it
+// back while the database is slow: it drops to SlowRate when SaveLatency or
+// PoolWait cross the slow thresholds and stops fetching when they cross the
+// pause thresholds. A healthy check moves one level back, so a paused consumer
+// resumes at SlowRate first. A check without samples keeps the level; while
+// paused nothing is saved, so the database is probed instead.
+type Throttle struct {
+	cfg    ThrottleConfig
+	health DatabaseHealth
+	bucket *TokenBucket
+
+	mu    sync.Mutex
+	level ThrottleLevel
+	// resumed is closed while the level is not paused
+	resumed chan struct{}
+}
+
+func NewThrottle(cfg ThrottleConfig, health DatabaseHealth) *Throttle {
+	if cfg.Interval <= 0 {
+		cfg.Interval = time.Second
+	}
+	resumed := make(chan struct{})
+	close(resumed)
+
+	t := &Throttle{
+		cfg:     cfg,
+		health:  health,
+		bucket:  NewTokenBucket(cfg.Rate, cfg.Burst),
+		resumed: resumed,
+	}
+	throttleRate.Set(cfg.Rate)
+	throttleLevel.Set(float64(LevelNormal))
+	return t
+}
+
+// Level returns the current throttle level
+func (t *Throttle) Level() ThrottleLevel {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return t.level
+}
+
+// Wait blocks while ingestion is paused and then takes n messages from the rate
+// limit. A nil Throttle never waits.
+func (t *Throttle) Wait(ctx context.Context, n int) error {
+	if t == nil {
+		return ctx.Err()
+	}
+
+	t.mu.Lock()
+	resumed := t.resumed
+	t.mu.Unlock()
+
+	select {
+	case <-resumed:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	return t.bucket.Wait(ctx, n)
+}
+
+// Run checks the database health every Interval until ctx is cancelled
+func (t *Throttle) Run(ctx context.Context) {
+	if t.health == nil {
+		return
+	}
+
+	ticker := time.NewTicker(t.cfg.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			t.Check(ctx)
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// Check reads the database health once and adjusts the level
+func (t *Throttle) Check(ctx context.Context) {
+	latency, saved := t.health.SaveLatency()
+	wait, acquired := t.health.PoolWait()
+	if !saved {
+		latency = 0
+	}
+	if !acquired {
+		wait = 0
+	}
+
+	if !saved && t.Level() == LevelPaused {
+		// compare the probe with the pool wait thresholds: it is an acquire
+		// plus a trivial query
+		probeCtx, cancel := context.WithTimeout(ctx, t.cfg.Interval)
+		probe, err := t.health.Probe(probeCtx)
+		cancel()
+		if err != nil {
+			log.Printf("Ingestion stays paused: database probe failed: %v", err)
+			return
+		}
+		wait, acquired = max(wait, probe), true
+	}
+	if !saved && !acquired {
+		// an idle database tells nothing, e.g. no messages to consume
+		return
+	}
+
+	var target ThrottleLevel
+	switch {
+	case exceeds(latency, t.cfg.PauseSaveLatency) || exceeds(wait, t.cfg.PausePoolWait):
+		target = LevelPaused
+	case exceeds(latency, t.cfg.SlowSaveLatency) || exceeds(wait, t.cfg.SlowPoolWait):
+		target = LevelSlowed
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	level := target
+	if target < t.level {
+		// recover one level per healthy check
+		level = t.level - 1
+	}
+	if level == t.level {
+		return
+	}
+
+	log.Printf("Ingestion %s → %s | save latency=%s pool wait=%s", t.level, level, latency, wait)
+	t.setLevel(level)
+}
+
+func (t *Throttle) setLevel(level ThrottleLevel) {
+	if level == LevelPaused {
+		t.resumed = make(chan struct{})
+	} else if t.level == LevelPaused {
+		close(t.resumed)
+	}
+	t.level = level
+
+	rate := t.cfg.Rate
+	if level != LevelNormal {
+		rate = t.cfg.SlowRate
+	}
+	t.bucket.SetRate(rate)
+	throttleRate.Set(rate)
+	throttleLevel.Set(float64(level))
+}
+
+func exceeds(value, threshold time.Duration) bool {
+	return threshold > 0 && value > threshold
+}
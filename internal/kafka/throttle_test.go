@@ -0,0 +1,190 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeHealth — DatabaseHealth with settable readings
+type fakeHealth struct {
+	mu      sync.Mutex
+	latency time.Duration
+	wait    time.Duration
+	// idle reports no samples, as when nothing is saved
+	idle     bool
+	probe    time.Duration
+	probeErr error
+	probes   int
+}
+
+func (h *fakeHealth) set(latency, wait time.Duration) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.latency, h.wait, h.idle = latency, wait, false
+}
+
+// setIdle reports no samples and answers probes with probe or err
+func (h *fakeHealth) setIdle(probe time.Duration, err error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.idle, h.probe, h.probeErr = true, probe, err
+}
+
+func (h *fakeHealth) SaveLatency() (time.Duration, bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.latency, !h.idle
+}
+
+func (h *fakeHealth) PoolWait() (time.Duration, bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.wait, !h.idle
+}
+
+func (h *fakeHealth) Probe(context.Context) (time.Duration, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.probes++
+	return h.probe, h.probeErr
+}
+
+var testThrottle = ThrottleConfig{
+	Rate:             1000,
+	Burst:            10,
+	SlowRate:         100,
+	SlowSaveLatency:  100 * time.Millisecond,
+	SlowPoolWait:     50 * time.Millisecond,
+	PauseSaveLatency: time.Second,
+	PausePoolWait:    500 * time.Millisecond,
+}
+
+func TestTokenBucket_LimitsRate(t *testing.T) {
+	bucket := NewTokenBucket(100, 5)
+	ctx := context.Background()
+
+	started := time.Now()
+	for i := 0; i < 15; i++ {
+		require.NoError(t, bucket.Wait(ctx, 1))
+	}
+	// 5 from the burst, 10 more at 100/s
+	elapsed := time.Since(started)
+	assert.GreaterOrEqual(t, elapsed, 90*time.Millisecond)
+	assert.Less(t, elapsed, time.Second)
+}
+
+func TestTokenBucket_Unlimited(t *testing.T) {
+	bucket := NewTokenBucket(0, 1)
+
+	started := time.Now()
+	require.NoError(t, bucket.Wait(context.Background(), 1_000_000))
+	assert.Less(t, time.Since(started), 10*time.Millisecond)
+}
+
+func TestTokenBucket_WaitIsCancelled(t *testing.T) {
+	bucket := NewTokenBucket(1, 1)
+	require.NoError(t, bucket.Wait(context.Background(), 1))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(t, bucket.Wait(ctx, 1), context.DeadlineExceeded)
+}
+
+func TestThrottle_Levels(t *testing.T) {
+	health := &fakeHealth{}
+	throttle := NewThrottle(testThrottle, health)
+
+	steps := []struct {
+		latency, wait time.Duration
+		want          ThrottleLevel
+		rate          float64
+	}{
+		{10 * time.Millisecond, 0, LevelNormal, 1000},
+		{200 * time.Millisecond, 0, LevelSlowed, 100},
+		{0, 2 * time.Second, LevelPaused, 100},
+		// no saves while paused: recover one level per healthy check
+		{0, 0, LevelSlowed, 100},
+		{0, 0, LevelNormal, 1000},
+		{0, 80 * time.Millisecond, LevelSlowed, 100},
+		{5 * time.Second, 0, LevelPaused, 100},
+		{200 * time.Millisecond, 0, LevelSlowed, 100},
+		{200 * time.Millisecond, 0, LevelSlowed, 100},
+	}
+	for i, step := range steps {
+		health.set(step.latency, step.wait)
+		throttle.Check(context.Background())
+		assert.Equal(t, step.want, throttle.Level(), "step %d", i)
+		assert.Equal(t, step.rate, throttle.bucket.Rate(), "step %d", i)
+	}
+}
+
+func TestThrottle_PausedConsumerStopsFetching(t *testing.T) {
+	health := &fakeHealth{}
+	throttle := NewThrottle(testThrottle, health)
+
+	source := NewMemorySource()
+	ingestor := newMemoryIngestor()
+	consumer := NewConsumer(source, NewProcessor(ingestor, nil, nil, fastRetry), 2).WithThrottle(throttle)
+	startConsumer(t, consumer.Run)
+
+	source.Publish(testMessage(t, testOrder("a"), 0))
+	require.Eventually(t, func() bool { return source.Committed("orders", 0) == 1 }, 2*time.Second, 5*time.Millisecond)
+
+	health.set(5*time.Second, 0)
+	throttle.Check(context.Background())
+	require.Equal(t, LevelPaused, throttle.Level())
+
+	// the fetch already waiting may still take one message, nothing after it
+	source.Publish(testMessage(t, testOrder("b"), 0))
+	source.Publish(testMessage(t, testOrder("c"), 0))
+	time.Sleep(50 * time.Millisecond)
+	assert.LessOrEqual(t, ingestor.count(), 2)
+
+	health.set(0, 0)
+	throttle.Check(context.Background())
+	assert.Equal(t, LevelSlowed, throttle.Level())
+	require.Eventually(t, func() bool { return source.Committed("orders", 0) == 3 }, 2*time.Second, 5*time.Millisecond)
+	assert.Equal(t, 3, ingestor.count())
+}
+
+func TestThrottle_PausedWithoutSamples(t *testing.T) {
+	health := &fakeHealth{}
+	throttle := NewThrottle(testThrottle, health)
+	ctx := context.Background()
+
+	health.set(5*time.Second, 0)
+	throttle.Check(ctx)
+	require.Equal(t, LevelPaused, throttle.Level())
+
+	// nothing is saved while paused: an unreachable or overloaded database keeps the pause
+	health.setIdle(0, errors.New("connection refused"))
+	throttle.Check(ctx)
+	assert.Equal(t, LevelPaused, throttle.Level())
+
+	health.setIdle(2*time.Second, nil)
+	throttle.Check(ctx)
+	throttle.Check(ctx)
+	assert.Equal(t, LevelPaused, throttle.Level())
+	assert.Equal(t, 3, health.probes)
+
+	// a fast probe resumes at the slow rate
+	health.setIdle(time.Millisecond, nil)
+	throttle.Check(ctx)
+	assert.Equal(t, LevelSlowed, throttle.Level())
+
+	// no samples outside the pause keep the level without probing
+	throttle.Check(ctx)
+	assert.Equal(t, LevelSlowed, throttle.Level())
+	assert.Equal(t, 4, health.probes)
+}
+
+func TestThrottle_NilNeverWaits(t *testing.T) {
+	var throttle *Throttle
+	assert.NoError(t, throttle.Wait(context.Background(), 100))
+}
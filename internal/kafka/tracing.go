@@ -0,0 +1,72 @@
+package kafka
+
+import (
+	"context"
+	"strconv"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+
+	"order-service-wbtech/internal/tracing"
+)
+
+// headerCarrier lets the OpenTelemetry propagator read and write the W3C
+// traceparent/tracestate headers of a message
+type headerCarrier struct {
+	headers *[]Header
+}
+
+func (c headerCarrier) Get(key string) string {
+	for _, h := range *c.headers {
+		if h.Key == key {
+			return string(h.Value)
+		}
+	}
+	return ""
+}
+
+func (c headerCarrier) Set(key, value string) {
+	for i, h := range *c.headers {
+		if h.Key == key {
+			(*c.headers)[i].Value = []byte(value)
+			return
+		}
+	}
+	*c.headers = append(*c.headers, Header{Key: key, Value: []byte(value)})
+}
+
+func (c headerCarrier) Keys() []string {
+	keys := make([]string, len(*c.headers))
+	for i, h := range *c.headers {
+		keys[i] = h.Key
+	}
+	return keys
+}
+
+// extractTraceContext continues the trace of the producer of m, if any
+func extractTraceContext(ctx context.Context, m Message) context.Context {
+	return otel.GetTextMapPropagator().Extract(ctx, headerCarrier{headers: &m.Headers})
+}
+
+// injectTraceContext writes the trace context of ctx into the headers of m
+func injectTraceContext(ctx context.Context, m *Message) {
+	otel.GetTextMapPropagator().Inject(ctx, headerCarrier{headers: &m.Headers})
+}
+
+// startMessageSpan starts a consumer span for m as a child of the producer span
+func startMessageSpan(ctx context.Context, name string, m Message) (context.Context, trace.Span) {
+	return tracing.Start(extractTraceContext(ctx, m), name,
+		trace.WithSpanKind(trace.SpanKindConsumer),
+		trace.WithAttributes(messageAttributes(m)...),
+	)
+}
+
+func messageAttributes(m Message) []attribute.KeyValue {
+	return []attribute.KeyValue{
+		attribute.String("messaging.system", "kafka"),
+		attribute.String("messaging.destination.name", m.Topic),
+		attribute.String("messaging.destination.partition.id", strconv.Itoa(m.Partition)),
+		attribute.Int64("messaging.kafka.offset", m.Offset),
+	}
+}
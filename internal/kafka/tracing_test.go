@@ -0,0 +1,45 @@
+package kafka
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestHandle_SpansContinueProducerTrace(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+
+	proc := NewProcessor(newMemoryIngestor(), nil, nil, fastRetry)
+	m := testMessage(t, testOrder("traced"), 3)
+	m.Headers = append(m.Headers, Header{Key: "traceparent", Value: []byte("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")})
+
+	_, err := proc.Handle(context.Background(), m)
+	require.NoError(t, err)
+
+	names := map[string]bool{}
+	for _, span := range recorder.Ended() {
+		names[span.Name()] = true
+		assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", span.SpanContext().TraceID().String(), span.Name())
+	}
+	assert.Equal(t, map[string]bool{"process orders": true, "decode": true, "validate": true, "save": true}, names)
+}
+
+func TestInjectTraceContext(t *testing.T) {
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+
+	src := Message{Headers: []Header{{Key: "traceparent", Value: []byte("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")}}}
+	var dst Message
+	injectTraceContext(extractTraceContext(context.Background(), src), &dst)
+
+	v, ok := dst.Header("traceparent")
+	require.True(t, ok)
+	assert.Equal(t, "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", string(v))
+}
@@ -0,0 +1,60 @@
+package kafka
+
+import "time"
+
+// Tuning — reader and worker pool settings of a consumer
+type Tuning struct {
+	// MinBytes and MaxBytes bound the size of a fetch response from the broker
+	MinBytes int
+	MaxBytes int
+	// MaxWait is how long the broker may hold a fetch waiting for MinBytes
+	MaxWait time.Duration
+	// ReadBatchTimeout is how long the reader waits for the rest of a fetch response
+	ReadBatchTimeout time.Duration
+	// QueueCapacity is the number of messages the reader prefetches
+	QueueCapacity int
+	// WorkerQueueSize is the number of messages waiting for each worker
+	WorkerQueueSize int
+	// FetchRetryDelay is the pause after a failed fetch
+	FetchRetryDelay time.Duration
+}
+
+// DefaultTuning returns the settings used when nothing is configured
+func DefaultTuning() Tuning {
+	return Tuning{
+		MinBytes:         1,
+		MaxBytes:         10e6,
+		MaxWait:          10 * time.Second,
+		ReadBatchTimeout: 5 * time.Second,
+		QueueCapacity:    100,
+		WorkerQueueSize:  2,
+		FetchRetryDelay:  time.Second,
+	}
+}
+
+// withDefaults fills unset fields from DefaultTuning
+func (t Tuning) withDefaults() Tuning {
+	def := DefaultTuning()
+	if t.MinBytes <= 0 {
+		t.MinBytes = def.MinBytes
+	}
+	if t.MaxBytes <= 0 {
+		t.MaxBytes = def.MaxBytes
+	}
+	if t.MaxWait <= 0 {
+		t.MaxWait = def.MaxWait
+	}
+	if t.ReadBatchTimeout <= 0 {
+		t.ReadBatchTimeout = def.ReadBatchTimeout
+	}
+	if t.QueueCapacity <= 0 {
+		t.QueueCapacity = def.QueueCapacity
+	}
+	if t.WorkerQueueSize <= 0 {
+		t.WorkerQueueSize = def.WorkerQueueSize
+	}
+	if t.FetchRetryDelay <= 0 {
+		t.FetchRetryDelay = def.FetchRetryDelay
+	}
+	return t
+}
@@ -43,11 +43,46 @@ func (_m *Cache) Get(orderUID string) (*model.Order, bool) {
 	return r0, r1
 }
 
+// GetByTrack provides a mock function with given fields: trackNumber
+func (_m *Cache) GetByTrack(trackNumber string) ([]*model.Order, bool) {
+	ret := _m.Called(trackNumber)
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetByTrack")
+	}
+
+	var r0 []*model.Order
+	var r1 bool
+	if rf, ok := ret.Get(0).(func(string) ([]*model.Order, bool)); ok {
+		return rf(trackNumber)
+	}
+	if rf, ok := ret.Get(0).(func(string) []*model.Order); ok {
+		r0 = rf(trackNumber)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]*model.Order)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(string) bool); ok {
+		r1 = rf(trackNumber)
+	} else {
+		r1 = ret.Get(1).(bool)
+	}
+
+	return r0, r1
+}
+
 // Set provides a mock function with given fields: order
 func (_m *Cache) Set(order *model.Order) {
 	_m.Called(order)
 }
 
+// SetTrack provides a mock function with given fields: trackNumber, orders
+func (_m *Cache) SetTrack(trackNumber string, orders []*model.Order) {
+	_m.Called(trackNumber, orders)
+}
+
 // NewCache creates a new instance of Cache. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
 // The first argument is typically a *testing.T value.
 func NewCache(t interface {
@@ -7,6 +7,8 @@ import (
 	model "order-service-wbtech/internal/model"
 
 	mock "github.com/stretchr/testify/mock"
+
+	time "time"
 )
 
 // Storage is an autogenerated mock type for the Storage type
@@ -44,6 +46,96 @@ func (_m *Storage) GetOrder(ctx context.Context, orderUID string) (*model.Order,
 	return r0, r1
 }
 
+// GetOrderHistory provides a mock function with given fields: ctx, orderUID
+func (_m *Storage) GetOrderHistory(ctx context.Context, orderUID string) ([]model.OrderEvent, error) {
+	ret := _m.Called(ctx, orderUID)
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetOrderHistory")
+	}
+
+	var r0 []model.OrderEvent
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string) ([]model.OrderEvent, error)); ok {
+		return rf(ctx, orderUID)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string) []model.OrderEvent); ok {
+		r0 = rf(ctx, orderUID)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]model.OrderEvent)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, orderUID)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetOrdersByTrack provides a mock function with given fields: ctx, trackNumber
+func (_m *Storage) GetOrdersByTrack(ctx context.Context, trackNumber string) (*model.TrackOrders, error) {
+	ret := _m.Called(ctx, trackNumber)
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetOrdersByTrack")
+	}
+
+	var r0 *model.TrackOrders
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string) (*model.TrackOrders, error)); ok {
+		return rf(ctx, trackNumber)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string) *model.TrackOrders); ok {
+		r0 = rf(ctx, trackNumber)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*model.TrackOrders)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, trackNumber)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// ListOrders provides a mock function with given fields: ctx, filter
+func (_m *Storage) ListOrders(ctx context.Context, filter model.OrderFilter) (*model.OrderPage, error) {
+	ret := _m.Called(ctx, filter)
+
+	if len(ret) == 0 {
+		panic("no return value specified for ListOrders")
+	}
+
+	var r0 *model.OrderPage
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, model.OrderFilter) (*model.OrderPage, error)); ok {
+		return rf(ctx, filter)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, model.OrderFilter) *model.OrderPage); ok {
+		r0 = rf(ctx, filter)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*model.OrderPage)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, model.OrderFilter) error); ok {
+		r1 = rf(ctx, filter)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // LoadOrders provides a mock function with given fields: ctx
 func (_m *Storage) LoadOrders(ctx context.Context) ([]*model.Order, error) {
 	ret := _m.Called(ctx)
@@ -92,6 +184,54 @@ func (_m *Storage) SaveOrder(ctx context.Context, order *model.Order) error {
 	return r0
 }
 
+// SaveOrders provides a mock function with given fields: ctx, orders
+func (_m *Storage) SaveOrders(ctx context.Context, orders []*model.Order) error {
+	ret := _m.Called(ctx, orders)
+
+	if len(ret) == 0 {
+		panic("no return value specified for SaveOrders")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, []*model.Order) error); ok {
+		r0 = rf(ctx, orders)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// UpdateOrderStatus provides a mock function with given fields: ctx, orderUID, status, changedAt
+func (_m *Storage) UpdateOrderStatus(ctx context.Context, orderUID string, status model.OrderStatus, changedAt time.Time) (*model.Order, error) {
+	ret := _m.Called(ctx, orderUID, status, changedAt)
+
+	if len(ret) == 0 {
+		panic("no return value specified for UpdateOrderStatus")
+	}
+
+	var r0 *model.Order
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, model.OrderStatus, time.Time) (*model.Order, error)); ok {
+		return rf(ctx, orderUID, status, changedAt)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string, model.OrderStatus, time.Time) *model.Order); ok {
+		r0 = rf(ctx, orderUID, status, changedAt)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*model.Order)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string, model.OrderStatus, time.Time) error); ok {
+		r1 = rf(ctx, orderUID, status, changedAt)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // NewStorage creates a new instance of Storage. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
 // The first argument is typically a *testing.T value.
 func NewStorage(t interface {
@@ -0,0 +1,26 @@
+package model
+
+import "errors"
+
+var (
+	// ErrOrderExists — an identical order with the same order_uid is already stored
+	ErrOrderExists = errors.New("order already exists")
+	// ErrOrderConflict — an order with the same order_uid but different content is already stored
+	ErrOrderConflict = errors.New("order conflicts with stored version")
+	// ErrInvalidTransition — the requested status change is not allowed by the order lifecycle
+	ErrInvalidTransition = errors.New("invalid order status transition")
+	// ErrDuplicateEvent — an event with the same ID has already been applied
+	ErrDuplicateEvent = errors.New("event already processed")
+	// ErrStaleEvent — the status change happened before the current status was set
+	ErrStaleEvent = errors.New("status change is older than the current status")
+)
+
+// Domain errors of the read and write paths, mapped to HTTP statuses by the API
+var (
+	// ErrNotFound — the requested order, event history or message does not exist
+	ErrNotFound = errors.New("not found")
+	// ErrUnavailable — the database cannot be reached, is overloaded or did not answer in time
+	ErrUnavailable = errors.New("storage unavailable")
+	// ErrInvalidArgument — the request cannot be served as asked, e.g. an empty order_uid
+	ErrInvalidArgument = errors.New("invalid argument")
+)
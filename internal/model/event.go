@@ -0,0 +1,73 @@
+package model
+
+import (
+	"context"
+	"time"
+)
+
+// Event types recorded in the order history
+const (
+	EventCreated       = "created"
+	EventReplaced      = "replaced"
+	EventStatusChanged = "status_changed"
+)
+
+// Sources an order change can come from
+const (
+	SourceKafka = "kafka"
+	SourceAPI   = "api"
+)
+
+// EventSource — where a change to an order came from
+type EventSource struct {
+	Kind      string
+	Topic     string
+	Partition int
+	Offset    int64
+	// EventID identifies the change at its producer (e.g. a CloudEvents source and
+	// id); a change with an already recorded EventID is not applied again
+	EventID string
+	// Reference ties the change to its request, e.g. the X-Request-ID of an API call
+	Reference string
+}
+
+// OrderEvent — an entry of the order history
+type OrderEvent struct {
+	ID             int64       `json:"id"`
+	OrderUID       string      `json:"order_uid"`
+	EventType      string      `json:"event_type"`
+	Status         OrderStatus `json:"status,omitempty"`
+	Source         string      `json:"source"`
+	EventID        string      `json:"event_id,omitempty"`
+	Reference      string      `json:"reference,omitempty"`
+	KafkaTopic     string      `json:"kafka_topic,omitempty"`
+	KafkaPartition *int        `json:"kafka_partition,omitempty"`
+	KafkaOffset    *int64      `json:"kafka_offset,omitempty"`
+	OccurredAt     time.Time   `json:"occurred_at"`
+	RecordedAt     time.Time   `json:"recorded_at"`
+}
+
+type eventSourceKey struct{}
+
+type eventSourcesKey struct{}
+
+// WithEventSource attaches the origin of the change being stored to ctx
+func WithEventSource(ctx context.Context, src EventSource) context.Context {
+	return context.WithValue(ctx, eventSourceKey{}, src)
+}
+
+// WithEventSources attaches per-order origins for batch writes, keyed by order_uid
+func WithEventSources(ctx context.Context, sources map[string]EventSource) context.Context {
+	return context.WithValue(ctx, eventSourcesKey{}, sources)
+}
+
+// EventSourceFrom returns the origin of a change to the given order
+func EventSourceFrom(ctx context.Context, orderUID string) (EventSource, bool) {
+	if sources, ok := ctx.Value(eventSourcesKey{}).(map[string]EventSource); ok {
+		if src, ok := sources[orderUID]; ok {
+			return src, true
+		}
+	}
+	src, ok := ctx.Value(eventSourceKey{}).(EventSource)
+	return src, ok
+}
@@ -0,0 +1,87 @@
+package model
+
+import (
+	"encoding/base64"
+	"errors"
+	"strings"
+	"time"
+)
+
+// ErrInvalidCursor — a pagination cursor that was not returned by the API
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+const (
+	// DefaultOrderLimit — page size of an order search without a limit
+	DefaultOrderLimit = 20
+	// MaxOrderLimit caps the page size of an order search
+	MaxOrderLimit = 100
+	// MaxTrackOrders caps the orders returned for one track number
+	MaxTrackOrders = 100
+)
+
+// OrderFilter — conditions of an order search; empty fields are not checked.
+// Orders are sorted by date_created, newest first.
+type OrderFilter struct {
+	CustomerID      string
+	TrackNumber     string
+	DeliveryService string
+	Locale          string
+	// CreatedFrom and CreatedTo bound date_created, both inclusive
+	CreatedFrom     time.Time
+	CreatedTo       time.Time
+	PaymentProvider string
+	Currency        string
+	// Brand matches orders with at least one item of the brand
+	Brand string
+
+	Limit int
+	// After continues the listing after the last order of the previous page
+	After *OrderCursor
+}
+
+// OrderCursor — position in an order listing: the sort key of the last order shown
+type OrderCursor struct {
+	DateCreated time.Time
+	OrderUID    string
+}
+
+// CursorOf returns the cursor positioned after order
+func CursorOf(order *Order) OrderCursor {
+	return OrderCursor{DateCreated: order.DateCreated, OrderUID: order.OrderUID}
+}
+
+// String encodes the cursor as an opaque URL-safe token
+func (c OrderCursor) String() string {
+	raw := c.DateCreated.UTC().Format(time.RFC3339Nano) + "|" + c.OrderUID
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+// ParseOrderCursor decodes a token made by OrderCursor.String
+func ParseOrderCursor(s string) (OrderCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return OrderCursor{}, ErrInvalidCursor
+	}
+	date, uid, ok := strings.Cut(string(raw), "|")
+	if !ok || uid == "" {
+		return OrderCursor{}, ErrInvalidCursor
+	}
+	t, err := time.Parse(time.RFC3339Nano, date)
+	if err != nil {
+		return OrderCursor{}, ErrInvalidCursor
+	}
+	return OrderCursor{DateCreated: t, OrderUID: uid}, nil
+}
+
+// TrackOrders — orders carrying a track number, newest first. Truncated is set
+// when the track has more than MaxTrackOrders orders and only the newest are listed.
+type TrackOrders struct {
+	Orders    []*Order
+	Truncated bool
+}
+
+// OrderPage — one page of an order listing; NextCursor is empty on the last page
+type OrderPage struct {
+	Orders     []*Order `json:"orders"`
+	NextCursor string   `json:"next_cursor,omitempty"`
+}
@@ -0,0 +1,31 @@
+package model
+
+import (
+	"encoding/base64"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOrderCursorRoundTrip(t *testing.T) {
+	order := &Order{OrderUID: "b563feb7b2b84b6test", DateCreated: time.Date(2021, 11, 26, 6, 22, 19, 123456000, time.UTC)}
+
+	cursor, err := ParseOrderCursor(CursorOf(order).String())
+	require.NoError(t, err)
+	assert.Equal(t, order.OrderUID, cursor.OrderUID)
+	assert.True(t, order.DateCreated.Equal(cursor.DateCreated))
+}
+
+func TestParseOrderCursor_Invalid(t *testing.T) {
+	for _, s := range []string{
+		"not base64!",
+		base64.RawURLEncoding.EncodeToString([]byte("no separator")),
+		base64.RawURLEncoding.EncodeToString([]byte("yesterday|uid")),
+		base64.RawURLEncoding.EncodeToString([]byte("2021-11-26T06:22:19Z|")),
+	} {
+		_, err := ParseOrderCursor(s)
+		assert.ErrorIs(t, err, ErrInvalidCursor, s)
+	}
+}
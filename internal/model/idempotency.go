@@ -0,0 +1,11 @@
+package model
+
+// IdempotencyRecord — a request made with an Idempotency-Key and, once it has
+// completed, the response to replay for retries with the same key
+type IdempotencyRecord struct {
+	Key         string
+	RequestHash string
+	Completed   bool
+	StatusCode  int
+	Response    []byte
+}
@@ -5,20 +5,21 @@ import (
 )
 
 type Order struct {
-	OrderUID          string    `json:"order_uid" db:"order_uid" validate:"required"`
-	TrackNumber       string    `json:"track_number" db:"track_number" validate:"required"`
-	Entry             string    `json:"entry" db:"entry" validate:"required"`
-	Delivery          Delivery  `json:"delivery" validate:"required"`
-	Payment           Payment   `json:"payment" validate:"required"`
-	Items             []Item    `json:"items" validate:"required,min=1,dive"`
-	Locale            string    `json:"locale" db:"locale" validate:"required,oneof=ru en"`
-	InternalSignature string    `json:"internal_signature" db:"internal_signature"`
-	CustomerID        string    `json:"customer_id" db:"customer_id" validate:"required"`
-	DeliveryService   string    `json:"delivery_service" db:"delivery_service" validate:"required"`
-	ShardKey          string    `json:"shardkey" db:"shardkey"`
-	SMID              int       `json:"sm_id" db:"sm_id" validate:"gte=0"`
-	DateCreated       time.Time `json:"date_created" db:"date_created" validate:"required"`
-	OOFShard          string    `json:"oof_shard" db:"oof_shard" validate:"required"`
+	OrderUID          string      `json:"order_uid" db:"order_uid" validate:"required"`
+	TrackNumber       string      `json:"track_number" db:"track_number" validate:"required"`
+	Entry             string      `json:"entry" db:"entry" validate:"required"`
+	Delivery          Delivery    `json:"delivery" validate:"required"`
+	Payment           Payment     `json:"payment" validate:"required"`
+	Items             []Item      `json:"items" validate:"required,min=1,dive"`
+	Locale            string      `json:"locale" db:"locale" validate:"required,oneof=ru en"`
+	InternalSignature string      `json:"internal_signature" db:"internal_signature"`
+	CustomerID        string      `json:"customer_id" db:"customer_id" validate:"required"`
+	DeliveryService   string      `json:"delivery_service" db:"delivery_service" validate:"required"`
+	ShardKey          string      `json:"shardkey" db:"shardkey"`
+	SMID              int         `json:"sm_id" db:"sm_id" validate:"gte=0"`
+	DateCreated       time.Time   `json:"date_created" db:"date_created" validate:"required"`
+	OOFShard          string      `json:"oof_shard" db:"oof_shard" validate:"required"`
+	Status            OrderStatus `json:"status,omitempty" db:"status" validate:"omitempty,oneof=created paid shipped delivered cancelled"`
 }
 
 type Delivery struct {
@@ -0,0 +1,48 @@
+package model
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Event types published to downstream services through the outbox
+const (
+	EventOrderAccepted = "order_accepted"
+)
+
+// OutboxEvent — an event stored with the change that caused it and published
+// to Kafka afterwards
+type OutboxEvent struct {
+	ID        int64
+	EventID   string
+	EventType string
+	OrderUID  string
+	Payload   json.RawMessage
+	CreatedAt time.Time
+}
+
+// OrderAccepted — order_accepted event payload, published once a new order is stored
+type OrderAccepted struct {
+	OrderUID    string      `json:"order_uid"`
+	TrackNumber string      `json:"track_number"`
+	CustomerID  string      `json:"customer_id"`
+	Status      OrderStatus `json:"status"`
+	Amount      int         `json:"amount"`
+	Currency    string      `json:"currency"`
+	ItemCount   int         `json:"item_count"`
+	DateCreated time.Time   `json:"date_created"`
+}
+
+// NewOrderAccepted builds the order_accepted payload of a stored order
+func NewOrderAccepted(order *Order) OrderAccepted {
+	return OrderAccepted{
+		OrderUID:    order.OrderUID,
+		TrackNumber: order.TrackNumber,
+		CustomerID:  order.CustomerID,
+		Status:      order.Status,
+		Amount:      order.Payment.Amount,
+		Currency:    order.Payment.Currency,
+		ItemCount:   len(order.Items),
+		DateCreated: order.DateCreated,
+	}
+}
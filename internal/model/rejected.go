@@ -0,0 +1,47 @@
+package model
+
+import "time"
+
+// FieldError — a validation failure of a single field, e.g. payment.currency
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// MessageHeader — a header of a rejected Kafka message. Values may be binary,
+// so they are kept as bytes (base64 in JSON).
+type MessageHeader struct {
+	Key   string `json:"key"`
+	Value []byte `json:"value"`
+}
+
+// RejectedMessage — a message that could not be processed, kept for review and
+// replay once the cause is fixed
+type RejectedMessage struct {
+	ID          int64           `json:"id"`
+	Topic       string          `json:"topic"`
+	Partition   int             `json:"partition"`
+	Offset      int64           `json:"offset"`
+	Key         string          `json:"key,omitempty"`
+	Headers     []MessageHeader `json:"headers,omitempty"`
+	Payload     []byte          `json:"payload"`
+	Stage       string          `json:"stage"`
+	Error       string          `json:"error"`
+	FieldErrors []FieldError    `json:"field_errors,omitempty"`
+	Attempts    int             `json:"attempts"`
+	ReceivedAt  time.Time       `json:"received_at"`
+
+	// ReplayedAt, ReplayOutcome and ReplayError describe the last replay
+	ReplayedAt    *time.Time `json:"replayed_at,omitempty"`
+	ReplayOutcome string     `json:"replay_outcome,omitempty"`
+	ReplayError   string     `json:"replay_error,omitempty"`
+}
+
+// RejectedFilter — which rejected messages to list, newest first
+type RejectedFilter struct {
+	Topic string
+	Stage string
+	// BeforeID continues a listing after the last ID of the previous page
+	BeforeID int64
+	Limit    int
+}
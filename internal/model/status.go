@@ -0,0 +1,55 @@
+package model
+
+import "time"
+
+type OrderStatus string
+
+const (
+	StatusCreated   OrderStatus = "created"
+	StatusPaid      OrderStatus = "paid"
+	StatusShipped   OrderStatus = "shipped"
+	StatusDelivered OrderStatus = "delivered"
+	StatusCancelled OrderStatus = "cancelled"
+)
+
+// transitions — allowed order lifecycle: created → paid → shipped → delivered,
+// an order can be cancelled until it is delivered
+var transitions = map[OrderStatus][]OrderStatus{
+	StatusCreated: {StatusPaid, StatusCancelled},
+	StatusPaid:    {StatusShipped, StatusCancelled},
+	StatusShipped: {StatusDelivered, StatusCancelled},
+}
+
+// CanTransitionTo reports whether the state machine allows moving from s to next
+func (s OrderStatus) CanTransitionTo(next OrderStatus) bool {
+	for _, allowed := range transitions[s] {
+		if allowed == next {
+			return true
+		}
+	}
+	return false
+}
+
+// StatusEvent — order_status_changed event payload
+type StatusEvent struct {
+	OrderUID  string      `json:"order_uid" validate:"required"`
+	Status    OrderStatus `json:"status" validate:"required,oneof=created paid shipped delivered cancelled"`
+	ChangedAt time.Time   `json:"changed_at" validate:"required"`
+}
+
+// PaymentConfirmation — payment_confirmed event payload, moves the order to paid
+type PaymentConfirmation struct {
+	OrderUID    string    `json:"order_uid" validate:"required"`
+	Transaction string    `json:"transaction" validate:"required"`
+	Amount      int       `json:"amount" validate:"gte=0"`
+	ConfirmedAt time.Time `json:"confirmed_at" validate:"required"`
+}
+
+// StatusEvent returns the status change caused by the confirmation
+func (p *PaymentConfirmation) StatusEvent() *StatusEvent {
+	return &StatusEvent{
+		OrderUID:  p.OrderUID,
+		Status:    StatusPaid,
+		ChangedAt: p.ConfirmedAt,
+	}
+}
@@ -0,0 +1,26 @@
+package model
+
+import "testing"
+
+func TestCanTransitionTo(t *testing.T) {
+	tests := []struct {
+		from, to OrderStatus
+		want     bool
+	}{
+		{StatusCreated, StatusPaid, true},
+		{StatusPaid, StatusShipped, true},
+		{StatusShipped, StatusDelivered, true},
+		{StatusCreated, StatusCancelled, true},
+		{StatusShipped, StatusCancelled, true},
+		{StatusCreated, StatusShipped, false},
+		{StatusPaid, StatusCreated, false},
+		{StatusDelivered, StatusCancelled, false},
+		{StatusCancelled, StatusPaid, false},
+	}
+
+	for _, tt := range tests {
+		if got := tt.from.CanTransitionTo(tt.to); got != tt.want {
+			t.Errorf("%s → %s: expected %v, got %v", tt.from, tt.to, tt.want, got)
+		}
+	}
+}
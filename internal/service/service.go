@@ -3,19 +3,27 @@ package service
 import (
 	"context"
 	"fmt"
+	"time"
 
 	"order-service-wbtech/internal/model"
 )
 
 type Storage interface {
 	SaveOrder(ctx context.Context, order *model.Order) error
+	SaveOrders(ctx context.Context, orders []*model.Order) error
+	UpdateOrderStatus(ctx context.Context, orderUID string, status model.OrderStatus, changedAt time.Time) (*model.Order, error)
 	GetOrder(ctx context.Context, orderUID string) (*model.Order, error)
 	LoadOrders(ctx context.Context) ([]*model.Order, error)
+	GetOrderHistory(ctx context.Context, orderUID string) ([]model.OrderEvent, error)
+	ListOrders(ctx context.Context, filter model.OrderFilter) (*model.OrderPage, error)
+	GetOrdersByTrack(ctx context.Context, trackNumber string) (*model.TrackOrders, error)
 }
 
 type Cache interface {
 	Get(orderUID string) (*model.Order, bool)
 	Set(order *model.Order)
+	GetByTrack(trackNumber string) ([]*model.Order, bool)
+	SetTrack(trackNumber string, orders []*model.Order)
 }
 
 type Service struct {
@@ -30,7 +38,11 @@ func New(c Cache, d Storage) *Service {
 	}
 }
 
+// CreateOrder stores a new order and caches it. A resend that replaces a stored
+// order comes back from the database with the status it kept, so the cache does
+// not roll the order back to created.
 func (s *Service) CreateOrder(ctx context.Context, order *model.Order) error {
+	setInitialStatus(order)
 	if err := s.db.SaveOrder(ctx, order); err != nil {
 		return fmt.Errorf("failed to save order to db: %w", err)
 	}
@@ -42,7 +54,48 @@ func (s *Service) CreateOrder(ctx context.Context, order *model.Order) error {
 	return nil
 }
 
+// CreateOrders stores a batch of new orders in one transaction
+func (s *Service) CreateOrders(ctx context.Context, orders []*model.Order) error {
+	for _, order := range orders {
+		setInitialStatus(order)
+	}
+	if err := s.db.SaveOrders(ctx, orders); err != nil {
+		return fmt.Errorf("failed to save %d orders to db: %w", len(orders), err)
+	}
+
+	for _, order := range orders {
+		s.cache.Set(order)
+	}
+
+	return nil
+}
+
+// UpdateOrderStatus applies a status change event and refreshes the cached order
+func (s *Service) UpdateOrderStatus(ctx context.Context, event *model.StatusEvent) error {
+	order, err := s.db.UpdateOrderStatus(ctx, event.OrderUID, event.Status, event.ChangedAt)
+	if err != nil {
+		return fmt.Errorf("failed to update status of order %s: %w", event.OrderUID, err)
+	}
+
+	s.cache.Set(order)
+	return nil
+}
+
+// setInitialStatus starts every new order as created: the status is owned by the
+// lifecycle and only moves with status events, whatever the producer sent
+func setInitialStatus(order *model.Order) {
+	if order != nil {
+		order.Status = model.StatusCreated
+	}
+}
+
+// GetOrder returns an order from the cache or the database. Errors are
+// model.ErrInvalidArgument, model.ErrNotFound, model.ErrUnavailable or unexpected.
 func (s *Service) GetOrder(ctx context.Context, orderUID string) (*model.Order, error) {
+	if orderUID == "" {
+		return nil, fmt.Errorf("%w: order_uid is required", model.ErrInvalidArgument)
+	}
+
 	order, ok := s.cache.Get(orderUID)
 	if ok {
 		return order, nil
@@ -50,13 +103,62 @@ func (s *Service) GetOrder(ctx context.Context, orderUID string) (*model.Order,
 
 	order, err := s.db.GetOrder(ctx, orderUID)
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("get order %s: %w", orderUID, err)
 	}
 
 	s.cache.Set(order)
 	return order, nil
 }
 
+// GetOrderHistory returns the chronological list of changes of an order
+func (s *Service) GetOrderHistory(ctx context.Context, orderUID string) ([]model.OrderEvent, error) {
+	if orderUID == "" {
+		return nil, fmt.Errorf("%w: order_uid is required", model.ErrInvalidArgument)
+	}
+
+	events, err := s.db.GetOrderHistory(ctx, orderUID)
+	if err != nil {
+		return nil, fmt.Errorf("get history of order %s: %w", orderUID, err)
+	}
+	return events, nil
+}
+
+// GetOrdersByTrack returns the newest model.MaxTrackOrders orders whose order or
+// items carry trackNumber, from the cache index or the database.
+// model.ErrNotFound if there are none.
+func (s *Service) GetOrdersByTrack(ctx context.Context, trackNumber string) (*model.TrackOrders, error) {
+	if trackNumber == "" {
+		return nil, fmt.Errorf("%w: track_number is required", model.ErrInvalidArgument)
+	}
+
+	if orders, ok := s.cache.GetByTrack(trackNumber); ok {
+		// orders that arrived after the lookup can take the track past the cap
+		if len(orders) > model.MaxTrackOrders {
+			return &model.TrackOrders{Orders: orders[:model.MaxTrackOrders], Truncated: true}, nil
+		}
+		return &model.TrackOrders{Orders: orders}, nil
+	}
+
+	found, err := s.db.GetOrdersByTrack(ctx, trackNumber)
+	if err != nil {
+		return nil, fmt.Errorf("get orders by track %s: %w", trackNumber, err)
+	}
+	if len(found.Orders) == 0 {
+		return nil, fmt.Errorf("orders with track %s: %w", trackNumber, model.ErrNotFound)
+	}
+
+	// the cache index may only hold every order of a track
+	if !found.Truncated {
+		s.cache.SetTrack(trackNumber, found.Orders)
+	}
+	return found, nil
+}
+
+// ListOrders searches orders in the database; listings are not cached
+func (s *Service) ListOrders(ctx context.Context, filter model.OrderFilter) (*model.OrderPage, error) {
+	return s.db.ListOrders(ctx, filter)
+}
+
 func (s *Service) RestoreCache(ctx context.Context) error {
 	fmt.Println("Restoring cache from Database...")
 
@@ -2,13 +2,17 @@ package service
 
 import (
 	"context"
+	"strconv"
 	"testing"
+	"time"
 
+	"order-service-wbtech/internal/cache"
 	"order-service-wbtech/internal/mocks"
 	"order-service-wbtech/internal/model"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
 )
 
 func TestCreateOrder(t *testing.T) {
@@ -30,6 +34,64 @@ func TestCreateOrder(t *testing.T) {
 	cacheMock.AssertCalled(t, "Set", order)
 }
 
+func TestCreateOrder_StartsAsCreated(t *testing.T) {
+	dbMock := &mocks.Storage{}
+	cacheMock := &mocks.Cache{}
+
+	// a producer cannot skip the lifecycle by sending a later status
+	order := &model.Order{OrderUID: "123", Status: model.StatusDelivered}
+
+	dbMock.On("SaveOrder", mock.Anything, order).Return(nil)
+	cacheMock.On("Set", order).Return()
+
+	err := New(cacheMock, dbMock).CreateOrder(context.Background(), order)
+	assert.NoError(t, err)
+	assert.Equal(t, model.StatusCreated, order.Status)
+}
+
+func TestCreateOrder_ReplaceKeepsStatus(t *testing.T) {
+	ctx := context.Background()
+	dbMock := &mocks.Storage{}
+	c, err := cache.New()
+	require.NoError(t, err)
+	svc := New(c, dbMock)
+
+	shipped := &model.Order{OrderUID: "123", TrackNumber: "T1", Status: model.StatusShipped}
+	dbMock.On("UpdateOrderStatus", mock.Anything, "123", model.StatusShipped, mock.Anything).Return(shipped, nil)
+	require.NoError(t, svc.UpdateOrderStatus(ctx, &model.StatusEvent{OrderUID: "123", Status: model.StatusShipped, ChangedAt: time.Now()}))
+
+	// a conflicting resend replaces the order, which keeps its status in the database
+	resend := &model.Order{OrderUID: "123", TrackNumber: "T2"}
+	dbMock.On("SaveOrder", mock.Anything, resend).Run(func(args mock.Arguments) {
+		args.Get(1).(*model.Order).Status = model.StatusShipped
+	}).Return(nil)
+	require.NoError(t, svc.CreateOrder(ctx, resend))
+
+	got, err := svc.GetOrder(ctx, "123")
+	require.NoError(t, err)
+	assert.Equal(t, "T2", got.TrackNumber)
+	assert.Equal(t, model.StatusShipped, got.Status)
+	dbMock.AssertNotCalled(t, "GetOrder", mock.Anything, mock.Anything)
+}
+
+func TestCreateOrders(t *testing.T) {
+	ctx := context.Background()
+	dbMock := &mocks.Storage{}
+	cacheMock := &mocks.Cache{}
+
+	orders := []*model.Order{{OrderUID: "1"}, {OrderUID: "2"}}
+
+	dbMock.On("SaveOrders", mock.Anything, orders).Return(nil)
+	cacheMock.On("Set", mock.Anything).Return()
+
+	svc := New(cacheMock, dbMock)
+
+	err := svc.CreateOrders(ctx, orders)
+	assert.NoError(t, err)
+
+	cacheMock.AssertNumberOfCalls(t, "Set", 2)
+}
+
 func TestGetOrder_FromCache(t *testing.T) {
 	ctx := context.Background()
 	dbMock := &mocks.Storage{}
@@ -68,3 +130,118 @@ func TestGetOrder_FromDB(t *testing.T) {
 	dbMock.AssertCalled(t, "GetOrder", mock.Anything, "123")
 	cacheMock.AssertCalled(t, "Set", order)
 }
+
+func TestUpdateOrderStatus_RefreshesCache(t *testing.T) {
+	ctx := context.Background()
+	dbMock := &mocks.Storage{}
+	cacheMock := &mocks.Cache{}
+
+	changedAt := time.Now()
+	updated := &model.Order{OrderUID: "123", Status: model.StatusPaid}
+
+	dbMock.On("UpdateOrderStatus", mock.Anything, "123", model.StatusPaid, changedAt).Return(updated, nil)
+	cacheMock.On("Set", updated).Return()
+
+	svc := New(cacheMock, dbMock)
+
+	err := svc.UpdateOrderStatus(ctx, &model.StatusEvent{OrderUID: "123", Status: model.StatusPaid, ChangedAt: changedAt})
+	assert.NoError(t, err)
+
+	cacheMock.AssertCalled(t, "Set", updated)
+}
+
+func TestGetOrder_Errors(t *testing.T) {
+	ctx := context.Background()
+	dbMock := &mocks.Storage{}
+	cacheMock := &mocks.Cache{}
+
+	cacheMock.On("Get", mock.Anything).Return(nil, false)
+	dbMock.On("GetOrder", mock.Anything, "missing").Return(nil, model.ErrNotFound)
+	dbMock.On("GetOrder", mock.Anything, "down").Return(nil, model.ErrUnavailable)
+
+	svc := New(cacheMock, dbMock)
+
+	_, err := svc.GetOrder(ctx, "")
+	assert.ErrorIs(t, err, model.ErrInvalidArgument)
+	_, err = svc.GetOrder(ctx, "missing")
+	assert.ErrorIs(t, err, model.ErrNotFound)
+	_, err = svc.GetOrder(ctx, "down")
+	assert.ErrorIs(t, err, model.ErrUnavailable)
+
+	dbMock.AssertNotCalled(t, "GetOrder", mock.Anything, "")
+	cacheMock.AssertNotCalled(t, "Set", mock.Anything)
+}
+
+func TestGetOrdersByTrack(t *testing.T) {
+	ctx := context.Background()
+	dbMock := &mocks.Storage{}
+	cacheMock := &mocks.Cache{}
+
+	orders := []*model.Order{{OrderUID: "1", TrackNumber: "T1"}}
+
+	cacheMock.On("GetByTrack", "T1").Return(nil, false).Once()
+	dbMock.On("GetOrdersByTrack", mock.Anything, "T1").Return(&model.TrackOrders{Orders: orders}, nil).Once()
+	cacheMock.On("SetTrack", "T1", orders).Return()
+	cacheMock.On("GetByTrack", "T1").Return(orders, true)
+
+	svc := New(cacheMock, dbMock)
+
+	got, err := svc.GetOrdersByTrack(ctx, "T1")
+	assert.NoError(t, err)
+	assert.Equal(t, &model.TrackOrders{Orders: orders}, got)
+
+	// the second lookup is served by the cache index
+	got, err = svc.GetOrdersByTrack(ctx, "T1")
+	assert.NoError(t, err)
+	assert.Equal(t, &model.TrackOrders{Orders: orders}, got)
+
+	dbMock.AssertNumberOfCalls(t, "GetOrdersByTrack", 1)
+	cacheMock.AssertCalled(t, "SetTrack", "T1", orders)
+}
+
+func TestGetOrdersByTrack_NotFound(t *testing.T) {
+	ctx := context.Background()
+	dbMock := &mocks.Storage{}
+	cacheMock := &mocks.Cache{}
+
+	cacheMock.On("GetByTrack", "missing").Return(nil, false)
+	dbMock.On("GetOrdersByTrack", mock.Anything, "missing").Return(&model.TrackOrders{Orders: []*model.Order{}}, nil)
+
+	svc := New(cacheMock, dbMock)
+
+	_, err := svc.GetOrdersByTrack(ctx, "missing")
+	assert.ErrorIs(t, err, model.ErrNotFound)
+	_, err = svc.GetOrdersByTrack(ctx, "")
+	assert.ErrorIs(t, err, model.ErrInvalidArgument)
+
+	cacheMock.AssertNotCalled(t, "SetTrack", mock.Anything, mock.Anything)
+}
+
+func TestGetOrdersByTrack_Truncated(t *testing.T) {
+	ctx := context.Background()
+	dbMock := &mocks.Storage{}
+	cacheMock := &mocks.Cache{}
+
+	orders := make([]*model.Order, model.MaxTrackOrders+1)
+	for i := range orders {
+		orders[i] = &model.Order{OrderUID: strconv.Itoa(i), TrackNumber: "T1"}
+	}
+	truncated := &model.TrackOrders{Orders: orders[:model.MaxTrackOrders], Truncated: true}
+
+	cacheMock.On("GetByTrack", "T1").Return(nil, false).Once()
+	dbMock.On("GetOrdersByTrack", mock.Anything, "T1").Return(truncated, nil).Once()
+	// the cache index grew past the cap after a complete lookup
+	cacheMock.On("GetByTrack", "T2").Return(orders, true)
+
+	svc := New(cacheMock, dbMock)
+
+	got, err := svc.GetOrdersByTrack(ctx, "T1")
+	require.NoError(t, err)
+	assert.Equal(t, truncated, got)
+	cacheMock.AssertNotCalled(t, "SetTrack", mock.Anything, mock.Anything)
+
+	got, err = svc.GetOrdersByTrack(ctx, "T2")
+	require.NoError(t, err)
+	assert.True(t, got.Truncated)
+	assert.Len(t, got.Orders, model.MaxTrackOrders)
+}
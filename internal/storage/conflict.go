@@ -0,0 +1,49 @@
+package storage
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+
+	"order-service-wbtech/internal/model"
+)
+
+// ConflictPolicy defines what SaveOrder does when an order with the same
+// order_uid but different content is already stored
+type ConflictPolicy string
+
+const (
+	// ConflictReject keeps the stored order and returns model.ErrOrderConflict
+	ConflictReject ConflictPolicy = "reject"
+	// ConflictOverwrite replaces the stored order in place
+	ConflictOverwrite ConflictPolicy = "overwrite"
+	// ConflictVersion archives the stored order in order_versions and replaces it with a new version
+	ConflictVersion ConflictPolicy = "version"
+)
+
+// ParseConflictPolicy parses a policy name, empty means ConflictReject
+func ParseConflictPolicy(s string) (ConflictPolicy, error) {
+	switch p := ConflictPolicy(s); p {
+	case "":
+		return ConflictReject, nil
+	case ConflictReject, ConflictOverwrite, ConflictVersion:
+		return p, nil
+	default:
+		return "", fmt.Errorf("unknown conflict policy %q", s)
+	}
+}
+
+// orderHash returns a fingerprint of the order content used to detect redeliveries
+func orderHash(order *model.Order) (string, error) {
+	o := *order
+	o.DateCreated = o.DateCreated.UTC()
+
+	b, err := json.Marshal(o)
+	if err != nil {
+		return "", fmt.Errorf("marshal order: %w", err)
+	}
+
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:]), nil
+}
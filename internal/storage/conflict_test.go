@@ -0,0 +1,41 @@
+package storage
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"order-service-wbtech/internal/model"
+)
+
+func TestParseConflictPolicy(t *testing.T) {
+	p, err := ParseConflictPolicy("")
+	require.NoError(t, err)
+	assert.Equal(t, ConflictReject, p)
+
+	p, err = ParseConflictPolicy("version")
+	require.NoError(t, err)
+	assert.Equal(t, ConflictVersion, p)
+
+	_, err = ParseConflictPolicy("merge")
+	assert.Error(t, err)
+}
+
+func TestOrderHash(t *testing.T) {
+	created := time.Date(2021, 11, 26, 6, 22, 19, 0, time.UTC)
+	a := &model.Order{OrderUID: "123", TrackNumber: "T1", DateCreated: created}
+	b := &model.Order{OrderUID: "123", TrackNumber: "T1", DateCreated: created.In(time.FixedZone("MSK", 3*3600))}
+	c := &model.Order{OrderUID: "123", TrackNumber: "T2", DateCreated: created}
+
+	ha, err := orderHash(a)
+	require.NoError(t, err)
+	hb, err := orderHash(b)
+	require.NoError(t, err)
+	hc, err := orderHash(c)
+	require.NoError(t, err)
+
+	assert.Equal(t, ha, hb, "same instant in another time zone is the same content")
+	assert.NotEqual(t, ha, hc)
+}
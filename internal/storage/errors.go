@@ -0,0 +1,57 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+
+	"order-service-wbtech/internal/model"
+)
+
+// mapError turns driver errors into the domain errors of model: a missing row is
+// model.ErrNotFound; lost connections, timeouts and an overloaded server are
+// model.ErrUnavailable. The driver error stays wrapped for logs and retries.
+func mapError(err error) error {
+	switch {
+	case err == nil, errors.Is(err, model.ErrNotFound), errors.Is(err, model.ErrUnavailable):
+		return err
+	case errors.Is(err, pgx.ErrNoRows):
+		return fmt.Errorf("%w: %w", model.ErrNotFound, err)
+	case unavailable(err):
+		return fmt.Errorf("%w: %w", model.ErrUnavailable, err)
+	default:
+		return err
+	}
+}
+
+// unavailable reports whether err means the database could not serve the query
+// at all, as opposed to rejecting it
+func unavailable(err error) bool {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		if len(pgErr.Code) < 2 {
+			return false
+		}
+		switch pgErr.Code[:2] {
+		case "08", // connection exception
+			"53", // insufficient resources: too many connections, out of memory
+			"57": // operator intervention: shutdown, statement timeout
+			return true
+		}
+		return false
+	}
+
+	var connectErr *pgconn.ConnectError
+	var netErr net.Error
+	return errors.Is(err, context.DeadlineExceeded) ||
+		pgconn.Timeout(err) ||
+		errors.As(err, &connectErr) ||
+		errors.As(err, &netErr) ||
+		errors.Is(err, io.EOF) ||
+		errors.Is(err, io.ErrUnexpectedEOF)
+}
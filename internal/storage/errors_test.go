@@ -0,0 +1,50 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"testing"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/stretchr/testify/assert"
+
+	"order-service-wbtech/internal/model"
+)
+
+func TestMapError(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want error
+	}{
+		{"no rows", fmt.Errorf("order 1: %w", pgx.ErrNoRows), model.ErrNotFound},
+		{"deadline", context.DeadlineExceeded, model.ErrUnavailable},
+		{"connection failure", &pgconn.PgError{Code: "08006"}, model.ErrUnavailable},
+		{"too many connections", &pgconn.PgError{Code: "53300"}, model.ErrUnavailable},
+		{"statement timeout", &pgconn.PgError{Code: "57014"}, model.ErrUnavailable},
+		{"connection dropped", fmt.Errorf("select: %w", io.ErrUnexpectedEOF), model.ErrUnavailable},
+		{"unique violation", &pgconn.PgError{Code: "23505"}, nil},
+		{"order conflict", model.ErrOrderConflict, model.ErrOrderConflict},
+		{"unknown", errors.New("boom"), nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := mapError(tt.err)
+			assert.ErrorIs(t, err, tt.err, "the driver error stays wrapped")
+			if tt.want != nil {
+				assert.ErrorIs(t, err, tt.want)
+			}
+			for _, domain := range []error{model.ErrNotFound, model.ErrUnavailable} {
+				if domain != tt.want {
+					assert.NotErrorIs(t, err, domain)
+				}
+			}
+		})
+	}
+
+	assert.NoError(t, mapError(nil))
+}
@@ -0,0 +1,104 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+
+	"order-service-wbtech/internal/model"
+)
+
+const insertEventSQL = `INSERT INTO order_events (
+		order_uid, event_type, status, source, kafka_topic, kafka_partition, kafka_offset, occurred_at, event_id, reference
+	) VALUES ($1,$2,NULLIF($3, ''),$4,$5,$6,$7,$8,NULLIF($9, ''),NULLIF($10, ''))`
+
+// eventIDConstraint keeps an event ID from being recorded twice
+const eventIDConstraint = "order_events_event_id_key"
+
+// eventArgs builds order_events arguments, taking the source from ctx
+func eventArgs(ctx context.Context, orderUID, eventType string, status model.OrderStatus, occurredAt time.Time) []any {
+	src, ok := model.EventSourceFrom(ctx, orderUID)
+	if !ok || src.Kind == "" {
+		src.Kind = "unknown"
+	}
+
+	var topic *string
+	var partition *int
+	var offset *int64
+	if src.Kind == model.SourceKafka {
+		topic, partition, offset = &src.Topic, &src.Partition, &src.Offset
+	}
+
+	return []any{orderUID, eventType, status, src.Kind, topic, partition, offset, occurredAt, src.EventID, src.Reference}
+}
+
+// recordEvent appends an entry to the order history within tx
+func recordEvent(ctx context.Context, tx pgx.Tx, orderUID, eventType string, status model.OrderStatus, occurredAt time.Time) error {
+	if _, err := tx.Exec(ctx, insertEventSQL, eventArgs(ctx, orderUID, eventType, status, occurredAt)...); err != nil {
+		// a concurrent transaction recorded the same event first
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.ConstraintName == eventIDConstraint {
+			return fmt.Errorf("order %s: %w", orderUID, model.ErrDuplicateEvent)
+		}
+		return fmt.Errorf("insert order_events: %w", err)
+	}
+	return nil
+}
+
+// checkEventApplied returns model.ErrDuplicateEvent when the change in ctx
+// carries an event ID that is already recorded
+func checkEventApplied(ctx context.Context, tx pgx.Tx, orderUID string) error {
+	src, ok := model.EventSourceFrom(ctx, orderUID)
+	if !ok || src.EventID == "" {
+		return nil
+	}
+
+	var exists bool
+	err := tx.QueryRow(ctx, `SELECT EXISTS (SELECT 1 FROM order_events WHERE event_id=$1)`, src.EventID).Scan(&exists)
+	if err != nil {
+		return fmt.Errorf("check event %s: %w", src.EventID, err)
+	}
+	if exists {
+		return fmt.Errorf("event %s: %w", src.EventID, model.ErrDuplicateEvent)
+	}
+	return nil
+}
+
+// GetOrderHistory returns all recorded changes of an order in chronological order
+func (p *Postgres) GetOrderHistory(ctx context.Context, orderUID string) (_ []model.OrderEvent, err error) {
+	defer func() { err = mapError(err) }()
+
+	rows, err := p.Pool.Query(ctx,
+		`SELECT id, order_uid, event_type, COALESCE(status, ''), source, COALESCE(event_id, ''), COALESCE(reference, ''),
+		        COALESCE(kafka_topic, ''), kafka_partition, kafka_offset, occurred_at, recorded_at
+		   FROM order_events
+		  WHERE order_uid=$1
+		  ORDER BY occurred_at, id`, orderUID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	events := make([]model.OrderEvent, 0)
+	for rows.Next() {
+		var e model.OrderEvent
+		if err := rows.Scan(&e.ID, &e.OrderUID, &e.EventType, &e.Status, &e.Source, &e.EventID, &e.Reference,
+			&e.KafkaTopic, &e.KafkaPartition, &e.KafkaOffset, &e.OccurredAt, &e.RecordedAt); err != nil {
+			return nil, err
+		}
+		events = append(events, e)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// every stored order has at least its creation event
+	if len(events) == 0 {
+		return nil, fmt.Errorf("history of order %s: %w", orderUID, pgx.ErrNoRows)
+	}
+	return events, nil
+}
@@ -0,0 +1,38 @@
+package storage
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"order-service-wbtech/internal/model"
+)
+
+func TestEventArgs(t *testing.T) {
+	at := time.Now()
+
+	args := eventArgs(context.Background(), "1", model.EventCreated, model.StatusCreated, at)
+	assert.Equal(t, "unknown", args[3])
+	assert.Nil(t, args[4])
+
+	ctx := model.WithEventSource(context.Background(), model.EventSource{Kind: model.SourceAPI, Reference: "req-1"})
+	args = eventArgs(ctx, "1", model.EventCreated, model.StatusCreated, at)
+	assert.Equal(t, model.SourceAPI, args[3])
+	assert.Nil(t, args[6], "offsets are only recorded for kafka")
+	assert.Equal(t, "req-1", args[9])
+
+	ctx = model.WithEventSources(ctx, map[string]model.EventSource{
+		"2": {Kind: model.SourceKafka, Topic: "orders", Partition: 1, Offset: 15, EventID: "shop#42"},
+	})
+	args = eventArgs(ctx, "2", model.EventCreated, model.StatusCreated, at)
+	assert.Equal(t, model.SourceKafka, args[3])
+	assert.Equal(t, "orders", *args[4].(*string))
+	assert.Equal(t, 1, *args[5].(*int))
+	assert.Equal(t, int64(15), *args[6].(*int64))
+	assert.Equal(t, "shop#42", args[8])
+
+	args = eventArgs(ctx, "1", model.EventCreated, model.StatusCreated, at)
+	assert.Equal(t, model.SourceAPI, args[3], "orders missing from the batch map fall back to the single source")
+}
@@ -0,0 +1,78 @@
+package storage
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// SaveLatency returns the average time to save an order since the previous
+// call; false when nothing was saved in between
+func (p *Postgres) SaveLatency() (time.Duration, bool) {
+	return p.saves.take()
+}
+
+// PoolWait returns the average time an acquire waited for a free pool
+// connection since the previous call, from pgxpool.Stat; false when no
+// connection was acquired in between
+func (p *Postgres) PoolWait() (time.Duration, bool) {
+	stat := p.Pool.Stat()
+	return p.poolWait.take(stat.EmptyAcquireWaitTime(), stat.AcquireCount())
+}
+
+// Probe times a ping of the database, including the wait for a pool connection
+func (p *Postgres) Probe(ctx context.Context) (time.Duration, error) {
+	started := time.Now()
+	if err := p.Pool.Ping(ctx); err != nil {
+		return 0, mapError(err)
+	}
+	return time.Since(started), nil
+}
+
+// latencyWindow — sum of save durations since the last take
+type latencyWindow struct {
+	mu    sync.Mutex
+	total time.Duration
+	count int
+}
+
+// observe records the time since started, spent saving n orders
+func (w *latencyWindow) observe(started time.Time, n int) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.total += time.Since(started)
+	w.count += n
+}
+
+func (w *latencyWindow) take() (time.Duration, bool) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.count == 0 {
+		return 0, false
+	}
+	avg := w.total / time.Duration(w.count)
+	w.total, w.count = 0, 0
+	return avg, true
+}
+
+// waitWindow turns the cumulative pool counters into an average over the
+// interval between two takes
+type waitWindow struct {
+	mu       sync.Mutex
+	waited   time.Duration
+	acquires int64
+}
+
+func (w *waitWindow) take(waited time.Duration, acquires int64) (time.Duration, bool) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	dWaited, dAcquires := waited-w.waited, acquires-w.acquires
+	w.waited, w.acquires = waited, acquires
+	if dAcquires <= 0 {
+		return 0, false
+	}
+	return dWaited / time.Duration(dAcquires), true
+}
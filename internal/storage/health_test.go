@@ -0,0 +1,37 @@
+package storage
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLatencyWindow(t *testing.T) {
+	var w latencyWindow
+	_, ok := w.take()
+	assert.False(t, ok, "nothing saved")
+
+	w.observe(time.Now().Add(-30*time.Millisecond), 1)
+	w.observe(time.Now().Add(-90*time.Millisecond), 3)
+	avg, ok := w.take()
+	assert.True(t, ok)
+	assert.GreaterOrEqual(t, avg, 30*time.Millisecond)
+	assert.Less(t, avg, 40*time.Millisecond)
+
+	_, ok = w.take()
+	assert.False(t, ok, "the window is reset by take")
+}
+
+func TestWaitWindow(t *testing.T) {
+	var w waitWindow
+	avg, ok := w.take(time.Second, 10)
+	assert.True(t, ok)
+	assert.Equal(t, 100*time.Millisecond, avg)
+
+	_, ok = w.take(time.Second, 10)
+	assert.False(t, ok, "no acquires since the last take")
+
+	avg, _ = w.take(2*time.Second, 14)
+	assert.Equal(t, 250*time.Millisecond, avg)
+}
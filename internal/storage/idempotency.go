@@ -0,0 +1,73 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+
+	"order-service-wbtech/internal/model"
+)
+
+// abandonedClaimTimeout — a claim without a response older than this is taken
+// over, e.g. after the instance handling it crashed
+const abandonedClaimTimeout = time.Minute
+
+// claimIdempotencySQL takes a new key, an expired one or an abandoned claim
+const claimIdempotencySQL = `INSERT INTO idempotency_keys (idempotency_key, request_hash)
+	VALUES ($1, $2)
+	ON CONFLICT (idempotency_key) DO UPDATE
+	   SET request_hash=EXCLUDED.request_hash, status_code=NULL, response=NULL, created_at=NOW()
+	 WHERE idempotency_keys.created_at < NOW() - $3::interval
+	    OR (idempotency_keys.status_code IS NULL AND idempotency_keys.created_at < NOW() - $4::interval)
+	RETURNING idempotency_key`
+
+// ClaimIdempotencyKey reserves key for a request with requestHash. It returns nil
+// when the caller owns the key and must handle the request, or the record of an
+// earlier request with the same key, completed or still in progress. Keys
+// expire after ttl.
+func (p *Postgres) ClaimIdempotencyKey(ctx context.Context, key, requestHash string, ttl time.Duration) (*model.IdempotencyRecord, error) {
+	var claimed string
+	err := p.Pool.QueryRow(ctx, claimIdempotencySQL, key, requestHash, ttl, abandonedClaimTimeout).Scan(&claimed)
+	if err == nil {
+		return nil, nil
+	}
+	if !errors.Is(err, pgx.ErrNoRows) {
+		return nil, mapError(fmt.Errorf("claim idempotency key: %w", err))
+	}
+
+	rec := model.IdempotencyRecord{Key: key}
+	var status *int
+	err = p.Pool.QueryRow(ctx,
+		`SELECT request_hash, status_code, response FROM idempotency_keys WHERE idempotency_key=$1`,
+		key).Scan(&rec.RequestHash, &status, &rec.Response)
+	if err != nil {
+		return nil, mapError(fmt.Errorf("select idempotency key: %w", err))
+	}
+	if status != nil {
+		rec.Completed, rec.StatusCode = true, *status
+	}
+	return &rec, nil
+}
+
+// CompleteIdempotencyKey stores the response of a claimed key for later retries
+func (p *Postgres) CompleteIdempotencyKey(ctx context.Context, key string, statusCode int, response []byte) error {
+	_, err := p.Pool.Exec(ctx,
+		`UPDATE idempotency_keys SET status_code=$2, response=$3 WHERE idempotency_key=$1`,
+		key, statusCode, response)
+	if err != nil {
+		return fmt.Errorf("complete idempotency key: %w", err)
+	}
+	return nil
+}
+
+// ReleaseIdempotencyKey forgets a claimed key, so a retry handles the request again
+func (p *Postgres) ReleaseIdempotencyKey(ctx context.Context, key string) error {
+	_, err := p.Pool.Exec(ctx, `DELETE FROM idempotency_keys WHERE idempotency_key=$1 AND status_code IS NULL`, key)
+	if err != nil {
+		return fmt.Errorf("release idempotency key: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,88 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+
+	"order-service-wbtech/internal/model"
+)
+
+const insertOutboxSQL = `INSERT INTO order_outbox (event_type, order_uid, payload) VALUES ($1,$2,$3)`
+
+// outboxArgs builds the order_accepted outbox row of a new order
+func outboxArgs(order *model.Order) ([]any, error) {
+	payload, err := json.Marshal(model.NewOrderAccepted(order))
+	if err != nil {
+		return nil, fmt.Errorf("marshal %s event: %w", model.EventOrderAccepted, err)
+	}
+	return []any{model.EventOrderAccepted, order.OrderUID, payload}, nil
+}
+
+// recordOrderAccepted writes the order_accepted event of a new order to the
+// outbox within tx, so the event exists if and only if the order does
+func recordOrderAccepted(ctx context.Context, tx pgx.Tx, order *model.Order) error {
+	args, err := outboxArgs(order)
+	if err != nil {
+		return err
+	}
+	if _, err := tx.Exec(ctx, insertOutboxSQL, args...); err != nil {
+		return fmt.Errorf("insert order_outbox: %w", err)
+	}
+	return nil
+}
+
+// PublishOutbox locks up to limit unsent events in the order they were written,
+// hands them to publish and marks them sent in the same transaction. Nothing is
+// marked if publish fails; if the process dies after publishing but before the
+// commit, the events are published again, so delivery is at least once. Locked
+// rows are skipped, so several relays can run side by side.
+func (p *Postgres) PublishOutbox(ctx context.Context, limit int, publish func(context.Context, []model.OutboxEvent) error) (int, error) {
+	tx, err := p.Pool.Begin(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	rows, err := tx.Query(ctx,
+		`SELECT id, event_id::text, event_type, order_uid, payload, created_at
+		   FROM order_outbox
+		  WHERE sent_at IS NULL
+		  ORDER BY id
+		  LIMIT $1
+		    FOR UPDATE SKIP LOCKED`, limit)
+	if err != nil {
+		return 0, fmt.Errorf("select order_outbox: %w", err)
+	}
+
+	events, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (model.OutboxEvent, error) {
+		var e model.OutboxEvent
+		err := row.Scan(&e.ID, &e.EventID, &e.EventType, &e.OrderUID, &e.Payload, &e.CreatedAt)
+		return e, err
+	})
+	if err != nil {
+		return 0, fmt.Errorf("scan order_outbox: %w", err)
+	}
+	if len(events) == 0 {
+		return 0, nil
+	}
+
+	if err := publish(ctx, events); err != nil {
+		return 0, err
+	}
+
+	ids := make([]int64, len(events))
+	for i, e := range events {
+		ids[i] = e.ID
+	}
+	if _, err := tx.Exec(ctx, `UPDATE order_outbox SET sent_at=NOW() WHERE id = ANY($1)`, ids); err != nil {
+		return 0, fmt.Errorf("mark order_outbox sent: %w", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return 0, fmt.Errorf("commit transaction: %w", err)
+	}
+	return len(events), nil
+}
@@ -0,0 +1,44 @@
+package storage
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"order-service-wbtech/internal/model"
+)
+
+func TestOutboxArgs(t *testing.T) {
+	created := time.Date(2025, 1, 15, 10, 0, 0, 0, time.UTC)
+	order := &model.Order{
+		OrderUID:    "b563feb7b2b84b6test",
+		TrackNumber: "WBILMTESTTRACK",
+		CustomerID:  "test",
+		Status:      model.StatusCreated,
+		DateCreated: created,
+		Payment:     model.Payment{Amount: 1817, Currency: "USD"},
+		Items:       []model.Item{{ChrtID: 1}, {ChrtID: 2}},
+	}
+
+	args, err := outboxArgs(order)
+	require.NoError(t, err)
+	require.Len(t, args, 3)
+	assert.Equal(t, model.EventOrderAccepted, args[0])
+	assert.Equal(t, order.OrderUID, args[1])
+
+	var payload model.OrderAccepted
+	require.NoError(t, json.Unmarshal(args[2].([]byte), &payload))
+	assert.Equal(t, model.OrderAccepted{
+		OrderUID:    order.OrderUID,
+		TrackNumber: "WBILMTESTTRACK",
+		CustomerID:  "test",
+		Status:      model.StatusCreated,
+		Amount:      1817,
+		Currency:    "USD",
+		ItemCount:   2,
+		DateCreated: created,
+	}, payload)
+}
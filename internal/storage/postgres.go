@@ -2,15 +2,25 @@ package storage
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
+	"log"
 	"order-service-wbtech/internal/config"
 	"order-service-wbtech/internal/model"
+	"order-service-wbtech/internal/tracing"
+	"time"
 
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
 type Postgres struct {
-	Pool *pgxpool.Pool
+	Pool           *pgxpool.Pool
+	ConflictPolicy ConflictPolicy
+
+	// saves and poolWait feed SaveLatency and PoolWait
+	saves    latencyWindow
+	poolWait waitWindow
 }
 
 func NewPostgres(cfg *config.Config) (*Postgres, error) {
@@ -23,139 +33,486 @@ func NewPostgres(cfg *config.Config) (*Postgres, error) {
 		cfg.DBcfg.Name,
 	)
 
-	pool, err := pgxpool.New(context.Background(), connStr)
+	policy, err := ParseConflictPolicy(cfg.DBcfg.ConflictPolicy)
+	if err != nil {
+		return nil, err
+	}
+
+	poolCfg, err := pgxpool.ParseConfig(connStr)
+	if err != nil {
+		return nil, err
+	}
+	poolCfg.ConnConfig.Tracer = tracing.QueryTracer{}
+
+	pool, err := pgxpool.NewWithConfig(context.Background(), poolCfg)
 	if err != nil {
 		return nil, err
 	}
 
-	return &Postgres{Pool: pool}, nil
+	return &Postgres{Pool: pool, ConflictPolicy: policy}, nil
 }
 
-func (p *Postgres) SaveOrder(ctx context.Context, order *model.Order) error {
+// SaveOrder stores a new order or resolves a resend by the ConflictPolicy. When
+// the stored order is replaced, order.Status is set to the status it keeps.
+func (p *Postgres) SaveOrder(ctx context.Context, order *model.Order) (err error) {
+	defer p.saves.observe(time.Now(), 1)
+	defer func() { err = mapError(err) }()
+
+	hash, err := orderHash(order)
+	if err != nil {
+		return err
+	}
+
 	tx, err := p.Pool.Begin(ctx)
 	if err != nil {
 		return fmt.Errorf("begin transaction: %w", err)
 	}
 	defer tx.Rollback(ctx)
 
+	if err := checkEventApplied(ctx, tx, order.OrderUID); err != nil {
+		return err
+	}
+
+	tag, err := tx.Exec(ctx, insertOrderSQL+` ON CONFLICT (order_uid) DO NOTHING`, orderArgs(order, hash)...)
+	if err != nil {
+		return fmt.Errorf("insert orders: %w", err)
+	}
+
+	if tag.RowsAffected() == 0 {
+		if err := p.resolveConflict(ctx, tx, order, hash); err != nil {
+			return err
+		}
+	} else {
+		if err := insertOrderDetails(ctx, tx, order); err != nil {
+			return err
+		}
+		if err := recordEvent(ctx, tx, order.OrderUID, model.EventCreated, order.Status, order.DateCreated); err != nil {
+			return err
+		}
+		if err := recordOrderAccepted(ctx, tx, order); err != nil {
+			return err
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("commit transaction: %w", err)
+	}
+	return nil
+}
+
+// resolveConflict handles an insert of an order_uid that already exists.
+// Identical content is reported as model.ErrOrderExists, different content
+// is handled according to the configured ConflictPolicy.
+func (p *Postgres) resolveConflict(ctx context.Context, tx pgx.Tx, order *model.Order, hash string) error {
+	var storedHash string
+	var version int
+	err := tx.QueryRow(ctx,
+		`SELECT COALESCE(content_hash, ''), version FROM orders WHERE order_uid=$1 FOR UPDATE`,
+		order.OrderUID).Scan(&storedHash, &version)
+	if err != nil {
+		return fmt.Errorf("select stored order: %w", err)
+	}
+
+	if storedHash == hash {
+		return fmt.Errorf("order %s: %w", order.OrderUID, model.ErrOrderExists)
+	}
+
+	switch p.ConflictPolicy {
+	case ConflictOverwrite:
+		log.Printf("Order %s conflicts with stored version %d → overwriting", order.OrderUID, version)
+	case ConflictVersion:
+		if err := archiveOrder(ctx, tx, order.OrderUID, version, storedHash); err != nil {
+			return err
+		}
+		version++
+		log.Printf("Order %s conflicts with stored version → stored as version %d", order.OrderUID, version)
+	default:
+		return fmt.Errorf("order %s: %w", order.OrderUID, model.ErrOrderConflict)
+	}
+
+	return replaceOrder(ctx, tx, order, hash, version)
+}
+
+// archiveOrder copies the currently stored order into order_versions
+func archiveOrder(ctx context.Context, tx pgx.Tx, orderUID string, version int, hash string) error {
+	stored, err := getOrder(ctx, tx, orderUID)
+	if err != nil {
+		return fmt.Errorf("load stored order: %w", err)
+	}
+
+	payload, err := json.Marshal(stored)
+	if err != nil {
+		return fmt.Errorf("marshal stored order: %w", err)
+	}
+
 	_, err = tx.Exec(ctx,
-		`INSERT INTO orders (
-			order_uid, track_number, entry, locale, internal_signature,
-			customer_id, delivery_service, shardkey, sm_id, date_created, oof_shard
-		) VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11)`,
+		`INSERT INTO order_versions (order_uid, version, content_hash, payload)
+		 VALUES ($1,$2,NULLIF($3, ''),$4)`,
+		orderUID, version, hash, payload,
+	)
+	if err != nil {
+		return fmt.Errorf("insert order_versions: %w", err)
+	}
+	return nil
+}
+
+// replaceOrder overwrites the stored order content and all of its nested rows.
+// The lifecycle status is kept: it only moves with status events, so the
+// "replaced" history event and order.Status carry the status that stayed.
+func replaceOrder(ctx context.Context, tx pgx.Tx, order *model.Order, hash string, version int) error {
+	for _, table := range []string{"items", "payment", "delivery"} {
+		if _, err := tx.Exec(ctx, `DELETE FROM `+table+` WHERE order_uid=$1`, order.OrderUID); err != nil {
+			return fmt.Errorf("delete %s: %w", table, err)
+		}
+	}
+
+	err := tx.QueryRow(ctx,
+		`UPDATE orders SET
+			track_number=$2, entry=$3, locale=$4, internal_signature=$5,
+			customer_id=$6, delivery_service=$7, shardkey=$8, sm_id=$9,
+			date_created=$10, oof_shard=$11, content_hash=$12, version=$13
+		 WHERE order_uid=$1
+		 RETURNING status -- status and status_updated_at are not replaced`,
 		order.OrderUID, order.TrackNumber, order.Entry, order.Locale,
 		order.InternalSignature, order.CustomerID, order.DeliveryService,
 		order.ShardKey, order.SMID, order.DateCreated, order.OOFShard,
-	)
+		hash, version,
+	).Scan(&order.Status)
 	if err != nil {
-		return fmt.Errorf("insert orders: %w", err)
+		return fmt.Errorf("update orders: %w", err)
 	}
 
-	_, err = tx.Exec(ctx,
-		`INSERT INTO delivery (
+	if err := insertOrderDetails(ctx, tx, order); err != nil {
+		return err
+	}
+	return recordEvent(ctx, tx, order.OrderUID, model.EventReplaced, order.Status, time.Now())
+}
+
+const (
+	insertOrderSQL = `INSERT INTO orders (
+			order_uid, track_number, entry, locale, internal_signature,
+			customer_id, delivery_service, shardkey, sm_id, date_created, oof_shard,
+			content_hash, status
+		) VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11,$12,$13)`
+
+	insertDeliverySQL = `INSERT INTO delivery (
 			order_uid, name, phone, zip, city, address, region, email
-		) VALUES ($1,$2,$3,$4,$5,$6,$7,$8)`,
+		) VALUES ($1,$2,$3,$4,$5,$6,$7,$8)`
+
+	insertPaymentSQL = `INSERT INTO payment (
+			order_uid, transaction, request_id, currency, provider,
+			amount, payment_dt, bank, delivery_cost, goods_total, custom_fee
+		) VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11)`
+
+	insertItemSQL = `INSERT INTO items (
+			order_uid, chrt_id, track_number, price, rid, name, sale, size,
+			total_price, nm_id, brand, status
+		) VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11,$12)`
+)
+
+func orderArgs(order *model.Order, hash string) []any {
+	return []any{
+		order.OrderUID, order.TrackNumber, order.Entry, order.Locale,
+		order.InternalSignature, order.CustomerID, order.DeliveryService,
+		order.ShardKey, order.SMID, order.DateCreated, order.OOFShard,
+		hash, order.Status,
+	}
+}
+
+func deliveryArgs(order *model.Order) []any {
+	return []any{
 		order.OrderUID, order.Delivery.Name, order.Delivery.Phone,
 		order.Delivery.Zip, order.Delivery.City, order.Delivery.Address,
 		order.Delivery.Region, order.Delivery.Email,
-	)
-	if err != nil {
-		return fmt.Errorf("insert delivery: %w", err)
 	}
+}
 
-	_, err = tx.Exec(ctx,
-		`INSERT INTO payment (
-			order_uid, transaction, request_id, currency, provider,
-			amount, payment_dt, bank, delivery_cost, goods_total, custom_fee
-		) VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11)`,
+func paymentArgs(order *model.Order) []any {
+	return []any{
 		order.OrderUID, order.Payment.Transaction, order.Payment.RequestID,
 		order.Payment.Currency, order.Payment.Provider, order.Payment.Amount,
 		order.Payment.PaymentDt, order.Payment.Bank, order.Payment.DeliveryCost,
 		order.Payment.GoodsTotal, order.Payment.CustomFee,
-	)
-	if err != nil {
+	}
+}
+
+func itemArgs(orderUID string, item model.Item) []any {
+	return []any{
+		orderUID, item.ChrtID, item.TrackNumber, item.Price, item.Rid, item.Name,
+		item.Sale, item.Size, item.TotalPrice, item.NmID, item.Brand, item.Status,
+	}
+}
+
+// insertOrderDetails inserts delivery, payment and items of an order
+func insertOrderDetails(ctx context.Context, tx pgx.Tx, order *model.Order) error {
+	if _, err := tx.Exec(ctx, insertDeliverySQL, deliveryArgs(order)...); err != nil {
+		return fmt.Errorf("insert delivery: %w", err)
+	}
+
+	if _, err := tx.Exec(ctx, insertPaymentSQL, paymentArgs(order)...); err != nil {
 		return fmt.Errorf("insert payment: %w", err)
 	}
 
 	for _, item := range order.Items {
-		_, err := tx.Exec(ctx,
-			`INSERT INTO items (
-                order_uid, chrt_id, track_number, price, rid, name, sale, size,
-                total_price, nm_id, brand, status
-            ) VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11,$12)`,
-			order.OrderUID, item.ChrtID, item.TrackNumber, item.Price, item.Rid, item.Name,
-			item.Sale, item.Size, item.TotalPrice, item.NmID, item.Brand, item.Status,
-		)
-		if err != nil {
+		if _, err := tx.Exec(ctx, insertItemSQL, itemArgs(order.OrderUID, item)...); err != nil {
 			return fmt.Errorf("insert item: %w", err)
 		}
 	}
 
+	return nil
+}
+
+// SaveOrders stores many new orders in a single transaction using one pgx batch.
+// Unlike SaveOrder it does not resolve duplicates: any existing order_uid fails
+// the whole batch, and the caller is expected to fall back to SaveOrder.
+func (p *Postgres) SaveOrders(ctx context.Context, orders []*model.Order) (err error) {
+	if len(orders) == 0 {
+		return nil
+	}
+	defer p.saves.observe(time.Now(), len(orders))
+	defer func() { err = mapError(err) }()
+
+	batch := &pgx.Batch{}
+	for _, order := range orders {
+		hash, err := orderHash(order)
+		if err != nil {
+			return err
+		}
+
+		batch.Queue(insertOrderSQL, orderArgs(order, hash)...)
+		batch.Queue(insertDeliverySQL, deliveryArgs(order)...)
+		batch.Queue(insertPaymentSQL, paymentArgs(order)...)
+		for _, item := range order.Items {
+			batch.Queue(insertItemSQL, itemArgs(order.OrderUID, item)...)
+		}
+		batch.Queue(insertEventSQL, eventArgs(ctx, order.OrderUID, model.EventCreated, order.Status, order.DateCreated)...)
+
+		outbox, err := outboxArgs(order)
+		if err != nil {
+			return err
+		}
+		batch.Queue(insertOutboxSQL, outbox...)
+	}
+
+	tx, err := p.Pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	if err := tx.SendBatch(ctx, batch).Close(); err != nil {
+		return fmt.Errorf("insert batch of %d orders: %w", len(orders), err)
+	}
+
 	if err := tx.Commit(ctx); err != nil {
 		return fmt.Errorf("commit transaction: %w", err)
 	}
 	return nil
 }
 
+// UpdateOrderStatus moves an order to a new status if the lifecycle allows it and
+// returns the updated order. Repeating the current status is a no-op; a change
+// older than the current status is model.ErrStaleEvent, so events delivered out
+// of order do not roll the order back.
+func (p *Postgres) UpdateOrderStatus(ctx context.Context, orderUID string, status model.OrderStatus, changedAt time.Time) (_ *model.Order, err error) {
+	defer func() { err = mapError(err) }()
+
+	tx, err := p.Pool.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	if err := checkEventApplied(ctx, tx, orderUID); err != nil {
+		return nil, err
+	}
+
+	// compared in SQL, so changedAt is converted to a timestamp the same way it is stored
+	var current model.OrderStatus
+	var stale bool
+	err = tx.QueryRow(ctx,
+		`SELECT status, COALESCE(status_updated_at > $2, false) FROM orders WHERE order_uid=$1 FOR UPDATE`,
+		orderUID, changedAt).Scan(&current, &stale)
+	if err != nil {
+		return nil, fmt.Errorf("select order %s: %w", orderUID, err)
+	}
+
+	if current != status {
+		if stale {
+			return nil, fmt.Errorf("order %s %s at %s: %w", orderUID, status, changedAt.Format(time.RFC3339), model.ErrStaleEvent)
+		}
+		if !current.CanTransitionTo(status) {
+			return nil, fmt.Errorf("order %s %s → %s: %w", orderUID, current, status, model.ErrInvalidTransition)
+		}
+
+		_, err = tx.Exec(ctx,
+			`UPDATE orders SET status=$2, status_updated_at=$3 WHERE order_uid=$1`,
+			orderUID, status, changedAt)
+		if err != nil {
+			return nil, fmt.Errorf("update order status: %w", err)
+		}
+
+		if err := recordEvent(ctx, tx, orderUID, model.EventStatusChanged, status, changedAt); err != nil {
+			return nil, err
+		}
+	}
+
+	order, err := getOrder(ctx, tx, orderUID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("commit transaction: %w", err)
+	}
+	return order, nil
+}
+
+// GetOrder returns a stored order; model.ErrNotFound if there is none
 func (p *Postgres) GetOrder(ctx context.Context, orderUID string) (*model.Order, error) {
-	order := &model.Order{}
+	order, err := getOrder(ctx, p.Pool, orderUID)
+	if err != nil {
+		return nil, mapError(fmt.Errorf("order %s: %w", orderUID, err))
+	}
+	return order, nil
+}
+
+// querier is implemented by both *pgxpool.Pool and pgx.Tx
+type querier interface {
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+}
+
+// getOrder loads one order; pgx.ErrNoRows if there is none
+func getOrder(ctx context.Context, q querier, orderUID string) (*model.Order, error) {
+	orders, err := loadOrders(ctx, q, []string{orderUID})
+	if err != nil {
+		return nil, err
+	}
+	if len(orders) == 0 {
+		return nil, pgx.ErrNoRows
+	}
+	return orders[0], nil
+}
+
+// loadOrders loads the orders with the given uids in the order of uids, with
+// one query per table whatever their number. Uids without an order are left out;
+// an order without its delivery or payment is pgx.ErrNoRows.
+func loadOrders(ctx context.Context, q querier, uids []string) ([]*model.Order, error) {
+	byUID := make(map[string]*model.Order, len(uids))
 
-	err := p.Pool.QueryRow(ctx,
+	err := queryEach(ctx, q,
 		`SELECT order_uid, track_number, entry, locale, internal_signature,
-		        customer_id, delivery_service, shardkey, sm_id, date_created, oof_shard
-		  FROM orders WHERE order_uid=$1`, orderUID).Scan(
-		&order.OrderUID, &order.TrackNumber, &order.Entry, &order.Locale,
-		&order.InternalSignature, &order.CustomerID, &order.DeliveryService,
-		&order.ShardKey, &order.SMID, &order.DateCreated, &order.OOFShard,
-	)
+		        customer_id, delivery_service, shardkey, sm_id, date_created, oof_shard, status
+		  FROM orders WHERE order_uid = ANY($1)`, uids,
+		func(rows pgx.Rows) error {
+			order := &model.Order{Items: []model.Item{}}
+			if err := rows.Scan(
+				&order.OrderUID, &order.TrackNumber, &order.Entry, &order.Locale,
+				&order.InternalSignature, &order.CustomerID, &order.DeliveryService,
+				&order.ShardKey, &order.SMID, &order.DateCreated, &order.OOFShard, &order.Status,
+			); err != nil {
+				return err
+			}
+			byUID[order.OrderUID] = order
+			return nil
+		})
 	if err != nil {
 		return nil, err
 	}
+	if len(byUID) == 0 {
+		return nil, nil
+	}
 
-	err = p.Pool.QueryRow(ctx,
-		`SELECT name, phone, zip, city, address, region, email FROM delivery WHERE order_uid=$1`,
-		orderUID).Scan(
-		&order.Delivery.Name, &order.Delivery.Phone, &order.Delivery.Zip,
-		&order.Delivery.City, &order.Delivery.Address, &order.Delivery.Region,
-		&order.Delivery.Email,
-	)
+	delivered := make(map[string]bool, len(byUID))
+	err = queryEach(ctx, q,
+		`SELECT order_uid, name, phone, zip, city, address, region, email
+		  FROM delivery WHERE order_uid = ANY($1)`, uids,
+		func(rows pgx.Rows) error {
+			var uid string
+			var d model.Delivery
+			if err := rows.Scan(&uid, &d.Name, &d.Phone, &d.Zip, &d.City, &d.Address, &d.Region, &d.Email); err != nil {
+				return err
+			}
+			if order := byUID[uid]; order != nil {
+				order.Delivery = d
+				delivered[uid] = true
+			}
+			return nil
+		})
 	if err != nil {
 		return nil, err
 	}
 
-	err = p.Pool.QueryRow(ctx,
-		`SELECT transaction, request_id, currency, provider, amount, payment_dt, bank,
+	paid := make(map[string]bool, len(byUID))
+	err = queryEach(ctx, q,
+		`SELECT order_uid, transaction, request_id, currency, provider, amount, payment_dt, bank,
 		        delivery_cost, goods_total, custom_fee
-		  FROM payment WHERE order_uid=$1`,
-		orderUID).Scan(
-		&order.Payment.Transaction, &order.Payment.RequestID, &order.Payment.Currency,
-		&order.Payment.Provider, &order.Payment.Amount, &order.Payment.PaymentDt,
-		&order.Payment.Bank, &order.Payment.DeliveryCost, &order.Payment.GoodsTotal,
-		&order.Payment.CustomFee,
-	)
+		  FROM payment WHERE order_uid = ANY($1)`, uids,
+		func(rows pgx.Rows) error {
+			var uid string
+			var pm model.Payment
+			if err := rows.Scan(&uid, &pm.Transaction, &pm.RequestID, &pm.Currency, &pm.Provider,
+				&pm.Amount, &pm.PaymentDt, &pm.Bank, &pm.DeliveryCost, &pm.GoodsTotal, &pm.CustomFee); err != nil {
+				return err
+			}
+			if order := byUID[uid]; order != nil {
+				order.Payment = pm
+				paid[uid] = true
+			}
+			return nil
+		})
 	if err != nil {
 		return nil, err
 	}
 
-	rows, err := p.Pool.Query(ctx,
-		`SELECT chrt_id, price, rid, name, sale, size, total_price, nm_id, brand, status
-		  FROM items WHERE order_uid=$1`, orderUID)
+	err = queryEach(ctx, q,
+		`SELECT order_uid, chrt_id, track_number, price, rid, name, sale, size, total_price, nm_id, brand, status
+		  FROM items WHERE order_uid = ANY($1) ORDER BY id`, uids,
+		func(rows pgx.Rows) error {
+			var uid string
+			var item model.Item
+			if err := rows.Scan(&uid, &item.ChrtID, &item.TrackNumber, &item.Price, &item.Rid, &item.Name,
+				&item.Sale, &item.Size, &item.TotalPrice, &item.NmID, &item.Brand, &item.Status); err != nil {
+				return err
+			}
+			if order := byUID[uid]; order != nil {
+				order.Items = append(order.Items, item)
+			}
+			return nil
+		})
 	if err != nil {
 		return nil, err
 	}
+
+	orders := make([]*model.Order, 0, len(byUID))
+	for _, uid := range uids {
+		order := byUID[uid]
+		if order == nil {
+			continue
+		}
+		if !delivered[uid] || !paid[uid] {
+			return nil, fmt.Errorf("details of order %s: %w", uid, pgx.ErrNoRows)
+		}
+		orders = append(orders, order)
+	}
+	return orders, nil
+}
+
+// queryEach runs sql with uids as $1 and calls scan for every row
+func queryEach(ctx context.Context, q querier, sql string, uids []string, scan func(rows pgx.Rows) error) error {
+	rows, err := q.Query(ctx, sql, uids)
+	if err != nil {
+		return err
+	}
 	defer rows.Close()
 
-	order.Items = []model.Item{}
 	for rows.Next() {
-		var item model.Item
-		if err := rows.Scan(&item.ChrtID, &item.Price, &item.Rid, &item.Name,
-			&item.Sale, &item.Size, &item.TotalPrice, &item.NmID, &item.Brand, &item.Status); err != nil {
-			return nil, err
+		if err := scan(rows); err != nil {
+			return err
 		}
-		order.Items = append(order.Items, item)
 	}
-
-	return order, nil
+	return rows.Err()
 }
 
 func (p *Postgres) LoadOrders(ctx context.Context) ([]*model.Order, error) {
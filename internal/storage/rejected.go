@@ -0,0 +1,164 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+
+	"order-service-wbtech/internal/model"
+)
+
+// defaultRejectedLimit — page size of ListRejectedMessages without a limit
+const defaultRejectedLimit = 50
+
+const selectRejectedSQL = `SELECT id, topic, kafka_partition, kafka_offset, COALESCE(message_key, ''::bytea),
+		headers, payload, stage, error, field_errors, attempts, received_at,
+		replayed_at, COALESCE(replay_outcome, ''), COALESCE(replay_error, '')
+	   FROM rejected_messages`
+
+// SaveRejectedMessage stores a message that could not be processed and sets its
+// ID. Saving a message with the same topic, partition and offset again is a no-op.
+func (p *Postgres) SaveRejectedMessage(ctx context.Context, msg *model.RejectedMessage) error {
+	headers, err := json.Marshal(nonNil(msg.Headers))
+	if err != nil {
+		return fmt.Errorf("marshal headers: %w", err)
+	}
+	fieldErrors, err := json.Marshal(nonNil(msg.FieldErrors))
+	if err != nil {
+		return fmt.Errorf("marshal field errors: %w", err)
+	}
+
+	// a message is stored once: a retried write returns the ID of the stored row
+	err = p.Pool.QueryRow(ctx,
+		`WITH inserted AS (
+			INSERT INTO rejected_messages (
+				topic, kafka_partition, kafka_offset, message_key, headers, payload,
+				stage, error, field_errors, attempts, received_at
+			) VALUES ($1,$2,$3,NULLIF($4, ''::bytea),$5,$6,$7,$8,$9,$10,$11)
+			ON CONFLICT (topic, kafka_partition, kafka_offset) DO NOTHING
+			RETURNING id
+		)
+		SELECT id FROM inserted
+		UNION ALL
+		SELECT id FROM rejected_messages WHERE topic=$1 AND kafka_partition=$2 AND kafka_offset=$3
+		LIMIT 1`,
+		msg.Topic, msg.Partition, msg.Offset, []byte(msg.Key), headers, msg.Payload,
+		msg.Stage, msg.Error, fieldErrors, msg.Attempts, msg.ReceivedAt,
+	).Scan(&msg.ID)
+	if err != nil {
+		return fmt.Errorf("insert rejected_messages: %w", err)
+	}
+	return nil
+}
+
+// GetRejectedMessage returns a rejected message by ID
+func (p *Postgres) GetRejectedMessage(ctx context.Context, id int64) (*model.RejectedMessage, error) {
+	rows, err := p.Pool.Query(ctx, selectRejectedSQL+` WHERE id=$1`, id)
+	if err != nil {
+		return nil, mapError(err)
+	}
+
+	msg, err := pgx.CollectExactlyOneRow(rows, scanRejected)
+	if err != nil {
+		return nil, mapError(fmt.Errorf("rejected message %d: %w", id, err))
+	}
+	return &msg, nil
+}
+
+// ListRejectedMessages returns rejected messages matching filter, newest first
+func (p *Postgres) ListRejectedMessages(ctx context.Context, filter model.RejectedFilter) ([]model.RejectedMessage, error) {
+	query, args := rejectedQuery(filter)
+	rows, err := p.Pool.Query(ctx, query, args...)
+	if err != nil {
+		return nil, mapError(err)
+	}
+
+	msgs, err := pgx.CollectRows(rows, scanRejected)
+	if err != nil {
+		return nil, mapError(fmt.Errorf("scan rejected_messages: %w", err))
+	}
+	return msgs, nil
+}
+
+// MarkRejectedMessageReplayed records the result of replaying a rejected message
+func (p *Postgres) MarkRejectedMessageReplayed(ctx context.Context, id int64, outcome, replayErr string) error {
+	tag, err := p.Pool.Exec(ctx,
+		`UPDATE rejected_messages
+		    SET replayed_at=NOW(), replay_outcome=$2, replay_error=NULLIF($3, '')
+		  WHERE id=$1`,
+		id, outcome, replayErr)
+	if err != nil {
+		return mapError(fmt.Errorf("update rejected_messages: %w", err))
+	}
+	if tag.RowsAffected() == 0 {
+		return mapError(fmt.Errorf("rejected message %d: %w", id, pgx.ErrNoRows))
+	}
+	return nil
+}
+
+// rejectedQuery builds the listing query of filter
+func rejectedQuery(filter model.RejectedFilter) (string, []any) {
+	var conds []string
+	var args []any
+	add := func(cond string, arg any) {
+		args = append(args, arg)
+		conds = append(conds, strings.Replace(cond, "?", "$"+strconv.Itoa(len(args)), 1))
+	}
+
+	if filter.Topic != "" {
+		add("topic = ?", filter.Topic)
+	}
+	if filter.Stage != "" {
+		add("stage = ?", filter.Stage)
+	}
+	if filter.BeforeID > 0 {
+		add("id < ?", filter.BeforeID)
+	}
+
+	query := selectRejectedSQL
+	if len(conds) > 0 {
+		query += ` WHERE ` + strings.Join(conds, " AND ")
+	}
+
+	limit := filter.Limit
+	if limit <= 0 {
+		limit = defaultRejectedLimit
+	}
+	args = append(args, limit)
+	query += ` ORDER BY id DESC LIMIT $` + strconv.Itoa(len(args))
+
+	return query, args
+}
+
+func scanRejected(row pgx.CollectableRow) (model.RejectedMessage, error) {
+	var msg model.RejectedMessage
+	var key, headers, fieldErrors []byte
+
+	err := row.Scan(&msg.ID, &msg.Topic, &msg.Partition, &msg.Offset, &key,
+		&headers, &msg.Payload, &msg.Stage, &msg.Error, &fieldErrors, &msg.Attempts, &msg.ReceivedAt,
+		&msg.ReplayedAt, &msg.ReplayOutcome, &msg.ReplayError)
+	if err != nil {
+		return msg, err
+	}
+
+	msg.Key = string(key)
+	if err := json.Unmarshal(headers, &msg.Headers); err != nil {
+		return msg, fmt.Errorf("unmarshal headers: %w", err)
+	}
+	if err := json.Unmarshal(fieldErrors, &msg.FieldErrors); err != nil {
+		return msg, fmt.Errorf("unmarshal field errors: %w", err)
+	}
+	return msg, nil
+}
+
+// nonNil turns a nil slice into an empty one, so it is stored as [] rather than null
+func nonNil[T any](s []T) []T {
+	if s == nil {
+		return []T{}
+	}
+	return s
+}
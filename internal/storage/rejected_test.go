@@ -0,0 +1,20 @@
+package storage
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"order-service-wbtech/internal/model"
+)
+
+func TestRejectedQuery(t *testing.T) {
+	query, args := rejectedQuery(model.RejectedFilter{})
+	assert.Contains(t, query, "ORDER BY id DESC LIMIT $1")
+	assert.NotContains(t, query, "WHERE")
+	assert.Equal(t, []any{defaultRejectedLimit}, args)
+
+	query, args = rejectedQuery(model.RejectedFilter{Topic: "orders", Stage: "validate", BeforeID: 120, Limit: 10})
+	assert.Contains(t, query, "WHERE topic = $1 AND stage = $2 AND id < $3 ORDER BY id DESC LIMIT $4")
+	assert.Equal(t, []any{"orders", "validate", int64(120), 10}, args)
+}
@@ -0,0 +1,131 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+
+	"order-service-wbtech/internal/model"
+)
+
+// ordersByTrackSQL finds orders by their own track number or that of an item
+const ordersByTrackSQL = `
+	SELECT o.order_uid
+	  FROM orders o
+	 WHERE o.order_uid IN (
+	           SELECT order_uid FROM orders WHERE track_number = $1
+	           UNION
+	           SELECT order_uid FROM items WHERE track_number = $1)
+	 ORDER BY o.date_created DESC, o.order_uid DESC
+	 LIMIT $2`
+
+// GetOrdersByTrack returns the newest model.MaxTrackOrders orders whose
+// order-level or item-level track number is trackNumber; no orders if there are none
+func (p *Postgres) GetOrdersByTrack(ctx context.Context, trackNumber string) (_ *model.TrackOrders, err error) {
+	defer func() { err = mapError(err) }()
+
+	// one more than the cap tells whether the track has more orders
+	rows, err := p.Pool.Query(ctx, ordersByTrackSQL, trackNumber, model.MaxTrackOrders+1)
+	if err != nil {
+		return nil, fmt.Errorf("search orders by track %s: %w", trackNumber, err)
+	}
+	uids, err := pgx.CollectRows(rows, pgx.RowTo[string])
+	if err != nil {
+		return nil, fmt.Errorf("search orders by track %s: %w", trackNumber, err)
+	}
+
+	truncated := len(uids) > model.MaxTrackOrders
+	if truncated {
+		uids = uids[:model.MaxTrackOrders]
+	}
+	orders, err := loadOrders(ctx, p.Pool, uids)
+	if err != nil {
+		return nil, fmt.Errorf("load orders by track %s: %w", trackNumber, err)
+	}
+	return &model.TrackOrders{Orders: orders, Truncated: truncated}, nil
+}
+
+// ListOrders returns a page of orders matching filter, newest first
+func (p *Postgres) ListOrders(ctx context.Context, filter model.OrderFilter) (_ *model.OrderPage, err error) {
+	defer func() { err = mapError(err) }()
+
+	// the API validates the limit against model.MaxOrderLimit
+	limit := filter.Limit
+	if limit <= 0 {
+		limit = model.DefaultOrderLimit
+	}
+	query, args := ordersQuery(filter, limit+1)
+
+	rows, err := p.Pool.Query(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("search orders: %w", err)
+	}
+	uids, err := pgx.CollectRows(rows, pgx.RowTo[string])
+	if err != nil {
+		return nil, fmt.Errorf("search orders: %w", err)
+	}
+
+	more := len(uids) > limit
+	if more {
+		uids = uids[:limit]
+	}
+	orders, err := loadOrders(ctx, p.Pool, uids)
+	if err != nil {
+		return nil, fmt.Errorf("load orders: %w", err)
+	}
+
+	page := &model.OrderPage{Orders: orders}
+	if more && len(orders) > 0 {
+		page.NextCursor = model.CursorOf(orders[len(orders)-1]).String()
+	}
+	return page, nil
+}
+
+// ordersQuery builds the search of filter, returning at most limit order_uids in
+// keyset order (date_created, order_uid) descending
+func ordersQuery(filter model.OrderFilter, limit int) (string, []any) {
+	var conds []string
+	var args []any
+	arg := func(v any) string {
+		args = append(args, v)
+		return "$" + strconv.Itoa(len(args))
+	}
+	add := func(column string, value string) {
+		if value != "" {
+			conds = append(conds, column+" = "+arg(value))
+		}
+	}
+
+	add("o.customer_id", filter.CustomerID)
+	add("o.track_number", filter.TrackNumber)
+	add("o.delivery_service", filter.DeliveryService)
+	add("o.locale", filter.Locale)
+	add("p.provider", filter.PaymentProvider)
+	add("p.currency", filter.Currency)
+	if !filter.CreatedFrom.IsZero() {
+		conds = append(conds, "o.date_created >= "+arg(filter.CreatedFrom))
+	}
+	if !filter.CreatedTo.IsZero() {
+		conds = append(conds, "o.date_created <= "+arg(filter.CreatedTo))
+	}
+	if filter.Brand != "" {
+		conds = append(conds, "EXISTS (SELECT 1 FROM items i WHERE i.order_uid = o.order_uid AND i.brand = "+arg(filter.Brand)+")")
+	}
+	if filter.After != nil {
+		conds = append(conds, "(o.date_created, o.order_uid) < ("+arg(filter.After.DateCreated)+", "+arg(filter.After.OrderUID)+")")
+	}
+
+	query := `SELECT o.order_uid FROM orders o`
+	if filter.PaymentProvider != "" || filter.Currency != "" {
+		query += ` JOIN payment p ON p.order_uid = o.order_uid`
+	}
+	if len(conds) > 0 {
+		query += ` WHERE ` + strings.Join(conds, " AND ")
+	}
+	query += ` ORDER BY o.date_created DESC, o.order_uid DESC LIMIT ` + arg(limit)
+
+	return query, args
+}
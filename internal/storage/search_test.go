@@ -0,0 +1,53 @@
+package storage
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"order-service-wbtech/internal/model"
+)
+
+func TestOrdersQuery(t *testing.T) {
+	from := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	after := model.OrderCursor{DateCreated: from.Add(time.Hour), OrderUID: "b563"}
+
+	tests := []struct {
+		name     string
+		filter   model.OrderFilter
+		wantSQL  string
+		wantArgs []any
+	}{
+		{
+			name:     "no filter",
+			wantSQL:  `SELECT o.order_uid FROM orders o ORDER BY o.date_created DESC, o.order_uid DESC LIMIT $1`,
+			wantArgs: []any{21},
+		},
+		{
+			name:   "order columns and date range",
+			filter: model.OrderFilter{CustomerID: "test", Locale: "en", CreatedFrom: from},
+			wantSQL: `SELECT o.order_uid FROM orders o WHERE o.customer_id = $1 AND o.locale = $2` +
+				` AND o.date_created >= $3 ORDER BY o.date_created DESC, o.order_uid DESC LIMIT $4`,
+			wantArgs: []any{"test", "en", from, 21},
+		},
+		{
+			name:   "payment, brand and cursor",
+			filter: model.OrderFilter{Currency: "USD", Brand: "Vivienne Sabo", After: &after},
+			wantSQL: `SELECT o.order_uid FROM orders o JOIN payment p ON p.order_uid = o.order_uid` +
+				` WHERE p.currency = $1` +
+				` AND EXISTS (SELECT 1 FROM items i WHERE i.order_uid = o.order_uid AND i.brand = $2)` +
+				` AND (o.date_created, o.order_uid) < ($3, $4)` +
+				` ORDER BY o.date_created DESC, o.order_uid DESC LIMIT $5`,
+			wantArgs: []any{"USD", "Vivienne Sabo", after.DateCreated, "b563", 21},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sql, args := ordersQuery(tt.filter, 21)
+			assert.Equal(t, tt.wantSQL, sql)
+			assert.Equal(t, tt.wantArgs, args)
+		})
+	}
+}
@@ -0,0 +1,43 @@
+package tracing
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestMiddleware_ContinuesIncomingTrace(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+
+	mux := http.NewServeMux()
+	var traceID string
+	mux.HandleFunc("GET /order/{uid}", func(w http.ResponseWriter, r *http.Request) {
+		traceID = TraceID(r.Context())
+		w.WriteHeader(http.StatusNotFound)
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/order/1", nil)
+	req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	Middleware(mux).ServeHTTP(httptest.NewRecorder(), req)
+
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", traceID)
+
+	spans := recorder.Ended()
+	require.Len(t, spans, 1)
+	assert.Equal(t, "GET /order/{uid}", spans[0].Name())
+	assert.Equal(t, "00f067aa0ba902b7", spans[0].Parent().SpanID().String())
+}
+
+func TestSQLOperation(t *testing.T) {
+	assert.Equal(t, "SELECT", sqlOperation("\n\t\tselect status FROM orders"))
+	assert.Equal(t, "query", sqlOperation(""))
+}
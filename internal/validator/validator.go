@@ -1,10 +1,15 @@
 package validator
 
 import (
+	"errors"
+	"reflect"
 	"regexp"
+	"strings"
 	"sync"
 
 	"github.com/go-playground/validator/v10"
+
+	"order-service-wbtech/internal/model"
 )
 
 var (
@@ -16,6 +21,15 @@ var (
 func initValidator() {
 	validate = validator.New()
 
+	// report fields by their JSON names, e.g. payment.currency
+	validate.RegisterTagNameFunc(func(f reflect.StructField) string {
+		name := strings.SplitN(f.Tag.Get("json"), ",", 2)[0]
+		if name == "-" {
+			return ""
+		}
+		return name
+	})
+
 	// UUID validation: must match standard UUID format
 	_ = validate.RegisterValidation("uuid", func(fl validator.FieldLevel) bool {
 		value := fl.Field().String()
@@ -43,3 +57,61 @@ func ValidateOrder(order interface{}) error {
 	once.Do(initValidator)
 	return validate.Struct(order)
 }
+
+// ValidateStatusEvent validates an order status change event
+func ValidateStatusEvent(event interface{}) error {
+	once.Do(initValidator)
+	return validate.Struct(event)
+}
+
+// ValidatePaymentConfirmation validates a payment confirmation event
+func ValidatePaymentConfirmation(event interface{}) error {
+	once.Do(initValidator)
+	return validate.Struct(event)
+}
+
+// FieldErrors lists the fields that failed validation by their JSON path, e.g.
+// payment.currency or items[0].price; nil if err is not a validation error
+func FieldErrors(err error) []model.FieldError {
+	var verrs validator.ValidationErrors
+	if !errors.As(err, &verrs) {
+		return nil
+	}
+
+	out := make([]model.FieldError, len(verrs))
+	for i, fe := range verrs {
+		field := fe.Namespace()
+		// drop the name of the validated struct
+		if _, rest, ok := strings.Cut(field, "."); ok {
+			field = rest
+		}
+		out[i] = model.FieldError{Field: field, Message: fieldMessage(fe)}
+	}
+	return out
+}
+
+// fieldMessage describes a failed validation rule
+func fieldMessage(fe validator.FieldError) string {
+	switch fe.Tag() {
+	case "required":
+		return "is required"
+	case "email":
+		return "must be a valid email address"
+	case "e164":
+		return "must be a phone number in E.164 format"
+	case "iso4217":
+		return "must be an ISO 4217 currency code"
+	case "uuid":
+		return "must be a UUID"
+	case "oneof":
+		return "must be one of: " + fe.Param()
+	case "len":
+		return "must have length " + fe.Param()
+	case "min":
+		return "must have at least " + fe.Param() + " element(s)"
+	case "gte":
+		return "must be greater than or equal to " + fe.Param()
+	default:
+		return "failed the " + fe.Tag() + " rule"
+	}
+}
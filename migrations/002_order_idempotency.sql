@@ -0,0 +1,21 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE orders ADD COLUMN content_hash TEXT;
+ALTER TABLE orders ADD COLUMN version INT NOT NULL DEFAULT 1;
+
+CREATE TABLE order_versions (
+    order_uid TEXT REFERENCES orders(order_uid),
+    version INT,
+    content_hash TEXT,
+    payload JSONB NOT NULL,
+    replaced_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (order_uid, version)
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS order_versions;
+ALTER TABLE orders DROP COLUMN IF EXISTS version;
+ALTER TABLE orders DROP COLUMN IF EXISTS content_hash;
+-- +goose StatementEnd
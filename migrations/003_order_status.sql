@@ -0,0 +1,11 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE orders ADD COLUMN status TEXT NOT NULL DEFAULT 'created';
+ALTER TABLE orders ADD COLUMN status_updated_at TIMESTAMP;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE orders DROP COLUMN IF EXISTS status_updated_at;
+ALTER TABLE orders DROP COLUMN IF EXISTS status;
+-- +goose StatementEnd
@@ -0,0 +1,26 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE order_events (
+    id BIGSERIAL PRIMARY KEY,
+    order_uid TEXT NOT NULL REFERENCES orders(order_uid),
+    event_type TEXT NOT NULL,
+    status TEXT,
+    source TEXT NOT NULL,
+    kafka_topic TEXT,
+    kafka_partition INT,
+    kafka_offset BIGINT,
+    occurred_at TIMESTAMP NOT NULL,
+    recorded_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_order_events_order_uid ON order_events(order_uid, occurred_at);
+
+INSERT INTO order_events (order_uid, event_type, status, source, occurred_at)
+SELECT order_uid, 'created', status, 'backfill', COALESCE(date_created, NOW())
+  FROM orders;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS order_events;
+-- +goose StatementEnd
@@ -0,0 +1,13 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE order_events ADD COLUMN event_id TEXT;
+
+CREATE UNIQUE INDEX order_events_event_id_key ON order_events(event_id) WHERE event_id IS NOT NULL;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS order_events_event_id_key;
+
+ALTER TABLE order_events DROP COLUMN IF EXISTS event_id;
+-- +goose StatementEnd
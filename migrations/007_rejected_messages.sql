@@ -0,0 +1,27 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE rejected_messages (
+    id BIGSERIAL PRIMARY KEY,
+    topic TEXT NOT NULL,
+    kafka_partition INT NOT NULL,
+    kafka_offset BIGINT NOT NULL,
+    message_key BYTEA,
+    headers JSONB NOT NULL DEFAULT '[]',
+    payload BYTEA NOT NULL,
+    stage TEXT NOT NULL,
+    error TEXT NOT NULL,
+    field_errors JSONB NOT NULL DEFAULT '[]',
+    attempts INT NOT NULL DEFAULT 1,
+    received_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    replayed_at TIMESTAMP,
+    replay_outcome TEXT,
+    replay_error TEXT
+);
+
+CREATE INDEX idx_rejected_messages_topic ON rejected_messages(topic, id);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS rejected_messages;
+-- +goose StatementEnd
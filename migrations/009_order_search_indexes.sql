@@ -0,0 +1,15 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE INDEX idx_orders_date_created ON orders(date_created DESC, order_uid DESC);
+CREATE INDEX idx_orders_customer_date ON orders(customer_id, date_created DESC, order_uid DESC);
+CREATE INDEX idx_items_order_uid ON items(order_uid);
+CREATE INDEX idx_items_brand ON items(brand);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS idx_items_brand;
+DROP INDEX IF EXISTS idx_items_order_uid;
+DROP INDEX IF EXISTS idx_orders_customer_date;
+DROP INDEX IF EXISTS idx_orders_date_created;
+-- +goose StatementEnd
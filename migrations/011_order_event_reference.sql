@@ -0,0 +1,9 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE order_events ADD COLUMN reference TEXT;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE order_events DROP COLUMN IF EXISTS reference;
+-- +goose StatementEnd
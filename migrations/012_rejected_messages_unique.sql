@@ -0,0 +1,34 @@
+-- +goose Up
+-- +goose StatementBegin
+-- a dead letter retried after a partial failure is stored once
+DELETE FROM rejected_messages r
+ USING rejected_messages d
+ WHERE r.topic = d.topic AND r.kafka_partition = d.kafka_partition
+   AND r.kafka_offset = d.kafka_offset AND r.id > d.id;
+
+CREATE UNIQUE INDEX idx_rejected_messages_position ON rejected_messages(topic, kafka_partition, kafka_offset);
+
+-- header values are binary: stored as base64 like the rest of []byte in JSON
+UPDATE rejected_messages
+   SET headers = (
+       SELECT COALESCE(jsonb_agg(jsonb_build_object(
+                  'key', h->>'key',
+                  'value', translate(encode(convert_to(h->>'value', 'UTF8'), 'base64'), E'\n', '')
+              ) ORDER BY n), '[]'::jsonb)
+         FROM jsonb_array_elements(headers) WITH ORDINALITY AS t(h, n)
+   );
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+UPDATE rejected_messages
+   SET headers = (
+       SELECT COALESCE(jsonb_agg(jsonb_build_object(
+                  'key', h->>'key',
+                  'value', convert_from(decode(h->>'value', 'base64'), 'UTF8')
+              ) ORDER BY n), '[]'::jsonb)
+         FROM jsonb_array_elements(headers) WITH ORDINALITY AS t(h, n)
+   );
+
+DROP INDEX IF EXISTS idx_rejected_messages_position;
+-- +goose StatementEnd